@@ -0,0 +1,206 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package analyzer reports byte slices read from a bolt transaction that are
+// stored beyond the transaction closure. Those slices point into the mmap of
+// the database and are invalid after the transaction ends, keeping them
+// corrupts data silently. Copy them first, with append([]byte{}, v...) or
+// bytes.Clone.
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "boltretain",
+	Doc:      "report slices read in a bolt transaction retained after it ends",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// methods of the cursors and buckets that return slices into the mmap.
+var unsafeMethods = map[string]bool{
+	"Get":   true,
+	"First": true,
+	"Last":  true,
+	"Next":  true,
+	"Prev":  true,
+	"Seek":  true,
+	"Skip":  true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ins.Preorder([]ast.Node{(*ast.FuncLit)(nil)}, func(n ast.Node) {
+		lit := n.(*ast.FuncLit)
+		if !takesTx(pass, lit) {
+			return
+		}
+		checkClosure(pass, lit)
+	})
+	return nil, nil
+}
+
+// takesTx reports if the function literal has a *bolt.Tx parameter.
+func takesTx(pass *analysis.Pass, lit *ast.FuncLit) bool {
+	for _, field := range lit.Type.Params.List {
+		t := pass.TypesInfo.TypeOf(field.Type)
+		ptr, ok := t.(*types.Pointer)
+		if !ok {
+			continue
+		}
+		named, ok := ptr.Elem().(*types.Named)
+		if !ok {
+			continue
+		}
+		obj := named.Obj()
+		if obj.Name() == "Tx" && obj.Pkg() != nil && isBoltPkg(obj.Pkg().Path()) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBoltPkg(path string) bool {
+	return strings.HasSuffix(path, "boltdb/bolt") || strings.HasSuffix(path, "etcd.io/bbolt")
+}
+
+func isUtilsPkg(path string) bool {
+	return path == "boltdbutils" || strings.HasSuffix(path, "/boltdbutils")
+}
+
+// unsafeCall reports if the expression is a call that returns slices into
+// the mmap.
+func unsafeCall(pass *analysis.Pass, expr ast.Expr) bool {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	case *ast.Ident:
+		id = fun
+	default:
+		return false
+	}
+	fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || !unsafeMethods[fn.Name()] {
+		return false
+	}
+	path := fn.Pkg().Path()
+	return isBoltPkg(path) || isUtilsPkg(path)
+}
+
+// checkClosure looks for assignments inside lit that store a tainted value
+// in a variable declared outside of it.
+func checkClosure(pass *analysis.Pass, lit *ast.FuncLit) {
+	tainted := make(map[types.Object]bool)
+	inside := func(obj types.Object) bool {
+		return obj != nil && obj.Pos() >= lit.Pos() && obj.Pos() < lit.End()
+	}
+	isTainted := func(expr ast.Expr) bool {
+		expr = ast.Unparen(expr)
+		if unsafeCall(pass, expr) {
+			return true
+		}
+		if id, ok := expr.(*ast.Ident); ok {
+			return tainted[pass.TypesInfo.ObjectOf(id)]
+		}
+		// append(dst, v) retains v, append(dst, v...) copies it.
+		if call, ok := expr.(*ast.CallExpr); ok && call.Ellipsis == token.NoPos {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "append" {
+				for _, arg := range call.Args[1:] {
+					if id, ok := ast.Unparen(arg).(*ast.Ident); ok && tainted[pass.TypesInfo.ObjectOf(id)] {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+	escapes := func(lhs ast.Expr) bool {
+		switch l := ast.Unparen(lhs).(type) {
+		case *ast.Ident:
+			if l.Name == "_" {
+				return false
+			}
+			return !inside(pass.TypesInfo.ObjectOf(l))
+		case *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+			root := rootIdent(l)
+			return root == nil || !inside(pass.TypesInfo.ObjectOf(root))
+		}
+		return false
+	}
+
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		if inner, ok := n.(*ast.FuncLit); ok && inner != lit && takesTx(pass, inner) {
+			// Checked on its own.
+			return false
+		}
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		// A call with many results, like k, v := c.First().
+		if len(assign.Rhs) == 1 && len(assign.Lhs) > 1 {
+			if !unsafeCall(pass, assign.Rhs[0]) {
+				return true
+			}
+			for _, lhs := range assign.Lhs {
+				report(pass, assign, lhs, escapes, tainted)
+			}
+			return true
+		}
+		for i, rhs := range assign.Rhs {
+			if i >= len(assign.Lhs) || !isTainted(rhs) {
+				continue
+			}
+			report(pass, assign, assign.Lhs[i], escapes, tainted)
+		}
+		return true
+	})
+}
+
+func report(pass *analysis.Pass, assign *ast.AssignStmt, lhs ast.Expr, escapes func(ast.Expr) bool, tainted map[types.Object]bool) {
+	if escapes(lhs) {
+		pass.Reportf(lhs.Pos(), "slice read in a bolt transaction is retained after the transaction ends, copy it first")
+		return
+	}
+	if id, ok := ast.Unparen(lhs).(*ast.Ident); ok {
+		if obj := pass.TypesInfo.ObjectOf(id); obj != nil {
+			tainted[obj] = true
+		}
+	}
+}
+
+// rootIdent returns the variable at the base of selector, index and star
+// expressions.
+func rootIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch x := expr.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.SelectorExpr:
+			expr = x.X
+		case *ast.IndexExpr:
+			expr = x.X
+		case *ast.StarExpr:
+			expr = x.X
+		case *ast.ParenExpr:
+			expr = x.X
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Command boltretain runs the retained slices analyzer:
+//
+//	go vet -vettool=$(which boltretain) ./...
+package main
+
+import (
+	"github.com/fcavani/boltdbutils/analyzer"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
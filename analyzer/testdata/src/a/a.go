@@ -0,0 +1,48 @@
+package a
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+)
+
+type post struct {
+	body []byte
+}
+
+func retained(db *bolt.DB) {
+	var val []byte
+	var vals [][]byte
+	p := &post{}
+	db.View(func(tx *bolt.Tx) error {
+		val = tx.Bucket([]byte("b")).Get([]byte("k")) // want "slice read in a bolt transaction is retained"
+		v, err := boltdbutils.Get(tx, []byte("b"), nil)
+		if err != nil {
+			return err
+		}
+		p.body = v // want "slice read in a bolt transaction is retained"
+		c := tx.Bucket([]byte("b")).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			vals = append(vals, k) // want "slice read in a bolt transaction is retained"
+		}
+		return nil
+	})
+	_ = val
+	_ = vals
+}
+
+func copied(db *bolt.DB) {
+	var val []byte
+	var vals [][]byte
+	db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte("b")).Get([]byte("k"))
+		val = append([]byte{}, v...)
+		c := &boltdbutils.Cursor{}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			local := v
+			vals = append(vals, append([]byte(nil), local...))
+		}
+		return nil
+	})
+	_ = val
+	_ = vals
+}
@@ -0,0 +1,20 @@
+package bolt
+
+type DB struct{}
+
+func (db *DB) View(fn func(*Tx) error) error   { return nil }
+func (db *DB) Update(fn func(*Tx) error) error { return nil }
+
+type Tx struct{}
+
+func (tx *Tx) Bucket(name []byte) *Bucket { return nil }
+
+type Bucket struct{}
+
+func (b *Bucket) Get(key []byte) []byte { return nil }
+func (b *Bucket) Cursor() *Cursor       { return nil }
+
+type Cursor struct{}
+
+func (c *Cursor) First() ([]byte, []byte) { return nil, nil }
+func (c *Cursor) Next() ([]byte, []byte)  { return nil, nil }
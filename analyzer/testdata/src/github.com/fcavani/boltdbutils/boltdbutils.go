@@ -0,0 +1,10 @@
+package boltdbutils
+
+import "github.com/boltdb/bolt"
+
+func Get(tx *bolt.Tx, bucket []byte, keys [][]byte) ([]byte, error) { return nil, nil }
+
+type Cursor struct{}
+
+func (c *Cursor) First() ([][]byte, []byte) { return nil, nil }
+func (c *Cursor) Next() ([][]byte, []byte)  { return nil, nil }
@@ -0,0 +1,287 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrInvBackup = "invalid backup"
+
+const ErrFileExists = "file already exists"
+
+// backupMagic starts every backup stream, the last byte is the version.
+// The version 1 has the values decoded, the version 2 the values as stored
+// and the version 3 the sequences of the buckets too.
+var backupMagic = []byte("BDBU\x03")
+
+// Backup writes a logical copy of the database: the top buckets with the key
+// paths and values of its leaves. The intermediate buckets aren't written
+// by name, and the ones no longer referenced by any tree are left out. The
+// values are written as stored, so the values of an EncryptedBucket stay
+// encrypted, with the codec and the checksum they were written with. The
+// sequences of the top buckets, like the ones of the changelog and the
+// outbox, are written too.
+//
+// The stream is the magic "BDBU\x03" followed by records, each one the
+// uvarint length of an encoded tuple (bucket, key path..., value), or
+// (bucket, sequence) after the leaves of a bucket with a sequence, the
+// sequence in 8 bytes big endian. A zero length ends the records and is
+// followed by the CRC-32 of everything before it.
+func Backup(db *bolt.DB, w io.Writer) error {
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	err := db.View(func(tx *bolt.Tx) error {
+		_, err := bw.Write(backupMagic)
+		if err != nil {
+			return e.Forward(err)
+		}
+		lenbuf := make([]byte, binary.MaxVarintLen64)
+		write := func(tuple ...[]byte) error {
+			rec := encodeKeys(tuple...)
+			n := binary.PutUvarint(lenbuf, uint64(len(rec)))
+			_, err := bw.Write(lenbuf[:n])
+			if err != nil {
+				return e.Forward(err)
+			}
+			_, err = bw.Write(rec)
+			if err != nil {
+				return e.Forward(err)
+			}
+			return nil
+		}
+		for _, bucket := range topBuckets(tx) {
+			b := tx.Bucket(bucket)
+			err = walkTree(tx, b, nil, func(keys [][]byte, v []byte) error {
+				tuple := make([][]byte, 0, len(keys)+2)
+				tuple = append(tuple, bucket)
+				tuple = append(tuple, keys...)
+				tuple = append(tuple, v)
+				return write(tuple...)
+			})
+			if err != nil {
+				return e.Forward(err)
+			}
+			if seq := b.Sequence(); seq != 0 {
+				buf := make([]byte, 8)
+				binary.BigEndian.PutUint64(buf, seq)
+				err = write(bucket, buf)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = bw.WriteByte(0)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = bw.Flush()
+	if err != nil {
+		return e.Forward(err)
+	}
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc.Sum32())
+	_, err = w.Write(sum)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// topBuckets returns the buckets that aren't intermediate buckets of another
+// tree. The metadata buckets come last so the restore writes the data before
+// the marks, like the frozen subtrees, that apply to it. The bucket counters
+// are left out, they are rebuilt by Put, and the counts of the leaves, that
+// name the intermediate buckets.
+func topBuckets(tx *bolt.Tx) [][]byte {
+	var names [][]byte
+	referenced := make(map[string]bool)
+	tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		names = append(names, append([]byte{}, name...))
+		return b.ForEach(func(k, v []byte) error {
+			if subBucket(tx, v) != nil {
+				referenced[string(v)] = true
+			}
+			return nil
+		})
+	})
+	var top [][]byte
+	for _, name := range names {
+		if referenced[string(name)] || isUUID(name) || isCompactID(name) || string(name) == bucketsBucket || string(name) == countsBucket {
+			continue
+		}
+		top = append(top, name)
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		return !isMetadata(top[i]) && isMetadata(top[j])
+	})
+	return top
+}
+
+func isMetadata(name []byte) bool {
+	return bytes.HasPrefix(name, []byte("__"))
+}
+
+// isUUID reports if name is in the form of the names of the intermediate
+// buckets.
+func isUUID(name []byte) bool {
+	if len(name) != 36 {
+		return false
+	}
+	for i, c := range name {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Restore creates a new database in path with the contents of a stream
+// written by Backup. The leaves are written in transactions of MigrateChunk
+// entries. If the stream is invalid the new file is removed. The values of
+// the streams of the version 1, decoded, are encoded again like PutBatch,
+// the ones of the versions 2 and 3 are restored as they were stored. The
+// sequences of the buckets are restored after their leaves.
+func Restore(r io.Reader, path string) (err error) {
+	_, err = os.Stat(path)
+	if err == nil {
+		return e.New(ErrFileExists)
+	}
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	defer func() {
+		cerr := db.Close()
+		if err == nil && cerr != nil {
+			err = e.Forward(cerr)
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	crc := crc32.NewIEEE()
+	br := bufio.NewReader(r)
+	tr := &hashReader{r: br, h: crc}
+	magic := make([]byte, len(backupMagic))
+	_, err = io.ReadFull(tr, magic)
+	version := magic[len(magic)-1]
+	if err != nil || !bytes.Equal(magic[:len(magic)-1], backupMagic[:len(backupMagic)-1]) || version < 1 || version > 3 {
+		return e.New(ErrInvBackup)
+	}
+
+	var bucket []byte
+	var items []Item
+	flush := func() error {
+		if len(items) == 0 {
+			return nil
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			return putBatch(tx, bucket, items, uuidID, version >= 2)
+		})
+		items = items[:0]
+		return err
+	}
+	for {
+		l, err := binary.ReadUvarint(tr)
+		if err != nil {
+			return e.Push(err, e.New(ErrInvBackup))
+		}
+		if l == 0 {
+			break
+		}
+		rec := make([]byte, l)
+		_, err = io.ReadFull(tr, rec)
+		if err != nil {
+			return e.Push(err, e.New(ErrInvBackup))
+		}
+		tuple, err := decodeKeys(rec)
+		if err == nil && version >= 3 && len(tuple) == 2 && len(tuple[1]) == 8 {
+			err = flush()
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = db.Update(func(tx *bolt.Tx) error {
+				b, err := tx.CreateBucketIfNotExists(tuple[0])
+				if err != nil {
+					return e.Forward(err)
+				}
+				return b.SetSequence(binary.BigEndian.Uint64(tuple[1]))
+			})
+			if err != nil {
+				return e.Forward(err)
+			}
+			continue
+		}
+		if err != nil || len(tuple) < 3 {
+			return e.New(ErrInvBackup)
+		}
+		if !bytes.Equal(tuple[0], bucket) || len(items) >= MigrateChunk {
+			err = flush()
+			if err != nil {
+				return e.Forward(err)
+			}
+			bucket = tuple[0]
+		}
+		items = append(items, Item{
+			Keys: tuple[1 : len(tuple)-1],
+			Data: tuple[len(tuple)-1],
+		})
+	}
+	want := crc.Sum32()
+	sum := make([]byte, 4)
+	_, err = io.ReadFull(br, sum)
+	if err != nil || binary.BigEndian.Uint32(sum) != want {
+		return e.New(ErrInvBackup)
+	}
+	err = flush()
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// hashReader hashes the bytes read.
+type hashReader struct {
+	r *bufio.Reader
+	h hash.Hash32
+}
+
+func (hr *hashReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	hr.h.Write(p[:n])
+	return n, err
+}
+
+func (hr *hashReader) ReadByte() (byte, error) {
+	c, err := hr.r.ReadByte()
+	if err == nil {
+		hr.h.Write([]byte{c})
+	}
+	return c, err
+}
@@ -0,0 +1,277 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestBackupRestore(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte("3")},
+		{[]byte("other"), [][]byte{[]byte("x")}, []byte("4")},
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		// A dead intermediate bucket.
+		id, err := rand.Uuid()
+		if err != nil {
+			return e.Forward(err)
+		}
+		b, err := tx.CreateBucket([]byte(id))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = b.Put([]byte("dead"), []byte("dead"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Freeze(tx, []byte("test_bucket"), [][]byte{[]byte("2014")})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var buf bytes.Buffer
+	err = Backup(db, &buf)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// A corrupted stream is refused and leaves no file.
+	bad := append([]byte{}, buf.Bytes()...)
+	bad[len(bad)-6] ^= 0xff
+	path := filepath.Join(dir, "bad.db")
+	err = Restore(bytes.NewReader(bad), path)
+	if !IsError(err, ErrInvBackup) {
+		t.Fatal("expected ErrInvBackup", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("file of a failed restore not removed")
+	}
+
+	path = filepath.Join(dir, "restored.db")
+	err = Restore(bytes.NewReader(buf.Bytes()), path)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = Restore(bytes.NewReader(buf.Bytes()), path)
+	if !IsError(err, ErrFileExists) {
+		t.Fatal("expected ErrFileExists", err)
+	}
+
+	rdb, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = rdb.View(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			v, err := Get(tx, d.Bucket, d.Keys)
+			if err != nil {
+				return e.Push(err, e.New("Fail to get %v", i))
+			}
+			if !bytes.Equal(v, d.Data) {
+				return e.New("not equal %v", i)
+			}
+		}
+		if !IsFrozen(tx, data[0].Bucket, data[0].Keys) {
+			return e.New("frozen mark not restored")
+		}
+		// test_bucket, other, __frozen, __buckets and 5 intermediate
+		// buckets.
+		n := 0
+		tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			n++
+			return nil
+		})
+		if n != 9 {
+			return e.New("wrong number of buckets %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestBackupEncrypted(t *testing.T) {
+	bucket := []byte("test_bucket")
+	key := bytes.Repeat([]byte{3}, 32)
+	defer EncryptedBucket(bucket, nil)
+	db := openTestDB(t)
+	defer db.Close()
+
+	err := EncryptedBucket(bucket, key)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	secret := []byte("the secret text")
+	keys := [][]byte{[]byte("a"), []byte("b")}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return Put(tx, bucket, keys, secret)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var buf bytes.Buffer
+	err = Backup(db, &buf)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if bytes.Contains(buf.Bytes(), secret) {
+		t.Fatal("value in clear in the backup")
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "restored.db")
+	// The values are restored without the key.
+	EncryptedBucket(bucket, nil)
+	err = Restore(bytes.NewReader(buf.Bytes()), path)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = EncryptedBucket(bucket, key)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	rdb, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer rdb.Close()
+	err = rdb.View(func(tx *bolt.Tx) error {
+		if rawContains(tx, secret) {
+			return e.New("value restored in clear")
+		}
+		v, err := Get(tx, bucket, keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, secret) {
+			return e.New("wrong value: %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestBackupSequences(t *testing.T) {
+	bucket := []byte("test_bucket")
+	db := openTestDB(t)
+	defer db.Close()
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := EnableChangelog(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		for _, k := range []string{"a", "b"} {
+			err = Put(tx, bucket, [][]byte{[]byte(k)}, []byte(k))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		_, err = Enqueue(tx, "topic", []byte("pending"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var buf bytes.Buffer
+	err = Backup(db, &buf)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "restored.db")
+	err = Restore(bytes.NewReader(buf.Bytes()), path)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	rdb, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer rdb.Close()
+
+	// The new records follow the restored ones.
+	err = rdb.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, [][]byte{[]byte("c")}, []byte("c"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		seq, err := Enqueue(tx, "topic", []byte("new"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if seq != 2 {
+			return e.New("wrong outbox sequence %v", seq)
+		}
+		m, err := OutboxMessage(tx, 1)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(m.Payload) != "pending" {
+			return e.New("pending message overwritten: %q", m.Payload)
+		}
+		changes, err := ReadChanges(tx, 0)
+		if err != nil {
+			return e.Forward(err)
+		}
+		var keys []string
+		for _, ch := range changes {
+			keys = append(keys, Path(ch.Keys).String())
+		}
+		if strings.Join(keys, " ") != "/a /b /c" {
+			return e.New("wrong changelog: %v", keys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// Item is one entry written by PutBatch.
+type Item struct {
+	Keys [][]byte
+	Data []byte
+}
+
+// PutBatch writes all items like Put does. The items are sorted by key path
+// so the chain of intermediate buckets is resolved once for each shared
+// prefix instead of once for each item. The items slice is reordered.
+func PutBatch(tx *bolt.Tx, bucket []byte, items []Item) error {
+	return putBatch(tx, bucket, items, uuidID, false)
+}
+
+// putBatch is PutBatch with newID naming the intermediate buckets created.
+// If stored is true the data of the items is already encoded, as read from
+// the database, and is written as it is; the hooks of the leaves get a nil
+// value.
+func putBatch(tx *bolt.Tx, bucket []byte, items []Item, newID func(tx *bolt.Tx) ([]byte, error), stored bool) error {
+	if len(items) == 0 {
+		return nil
+	}
+	root, err := tx.CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	info, err := writeInfo(tx, bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return compareKeys(items[i].Keys, items[j].Keys) < 0
+	})
+
+	// bs[i] is the bucket of the level i of prev.
+	var prev [][]byte
+	bs := []*bolt.Bucket{root}
+	for _, item := range items {
+		keys := item.Keys
+		data, buf, err := prepareItem(tx, bucket, &info, item, stored)
+		if err != nil {
+			return e.Forward(err)
+		}
+		n := commonPrefix(prev, keys[:len(keys)-1])
+		if n > len(bs)-1 {
+			n = len(bs) - 1
+		}
+		bs = bs[:n+1]
+		for i := n; i < len(keys)-1; i++ {
+			b, err := childID(tx, bucket, bs[i], keys[i], newID)
+			if err != nil {
+				return e.Forward(err)
+			}
+			bs = append(bs, b)
+		}
+		err = writeLeaf(tx, bucket, bs[len(keys)-1], keys, data, buf)
+		if err != nil {
+			return e.Forward(err)
+		}
+		prev = keys[:len(keys)-1]
+	}
+	return nil
+}
+
+// prepareItem returns the data given to the hooks of item and the value
+// stored, like prepareLeaf. If stored is true the data of item is the value
+// stored and is only checked and charged to the quota.
+func prepareItem(tx *bolt.Tx, bucket []byte, info *BucketInfo, item Item, stored bool) ([]byte, []byte, error) {
+	if !stored {
+		buf, err := prepareLeaf(tx, bucket, info, item.Keys, item.Data)
+		if err != nil {
+			return nil, nil, e.Forward(err)
+		}
+		return item.Data, buf, nil
+	}
+	err := checkLeaf(tx, bucket, info, item.Keys)
+	if err != nil {
+		return nil, nil, e.Forward(err)
+	}
+	err = quotaPut(tx, bucket, item.Keys, item.Data)
+	if err != nil {
+		return nil, nil, e.Forward(err)
+	}
+	return nil, item.Data, nil
+}
+
+// compareKeys compares two key paths level by level.
+func compareKeys(a, b [][]byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := bytes.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// commonPrefix returns the number of leading keys equal in a and b.
+func commonPrefix(a, b [][]byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && bytes.Equal(a[n], b[n]) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,85 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestPutBatch(t *testing.T) {
+	items := []Item{
+		{[][]byte{[]byte("2015"), []byte("02"), []byte("b")}, []byte("4")},
+		{[][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[][]byte{[]byte("2015"), []byte("01"), []byte("a")}, []byte("3")},
+		{[][]byte{[]byte("2014"), []byte("01"), []byte("b")}, []byte("2")},
+		{[][]byte{[]byte("2016")}, []byte("5")},
+	}
+	want := make([]Item, len(items))
+	copy(want, items)
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, []byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("c")}, []byte("0"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return PutBatch(tx, []byte("test_bucket"), items)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, item := range want {
+			v, err := Get(tx, []byte("test_bucket"), item.Keys)
+			if err != nil {
+				return e.Push(err, e.New("fail to get %v", i))
+			}
+			if !bytes.Equal(v, item.Data) {
+				return e.New("not equal %v %v", i, string(v))
+			}
+		}
+		_, err := Get(tx, []byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("c")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		// 2014, 2014/01, 2014/02, 2015, 2015/01 and 2015/02.
+		if s := treeStats(tx, tx.Bucket([]byte("test_bucket"))); s.Buckets != 6 {
+			return e.New("wrong number of buckets %v", s.Buckets)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return PutBatch(tx, []byte("test_bucket"), []Item{{nil, []byte("x")}})
+	})
+	if !IsError(err, ErrNoKeys) {
+		t.Fatal("expected ErrNoKeys", err)
+	}
+}
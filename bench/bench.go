@@ -0,0 +1,201 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package bench generates synthetic trees and benchmarks the cursors of
+// boltdbutils on them, to evaluate a layout before choosing a schema. The
+// benchmarks of the package run Skip, Seek and Next on trees of 2 to 9
+// levels with about the same number of leaves:
+//
+//	go test -bench . github.com/fcavani/boltdbutils/bench
+//
+// The helpers run the same benchmarks on other layouts:
+//
+//	func BenchmarkMySchema(b *testing.B) {
+//		l := bench.Layout{NumKeys: 5, Fanout: []int{100, 12, 4}, ValueSize: 512}
+//		db, err := bench.NewDB(dir, []byte("b"), l)
+//		...
+//		bench.Seek(b, db, []byte("b"), l)
+//	}
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+// Layout describes a synthetic tree.
+type Layout struct {
+	// NumKeys is the number of levels.
+	NumKeys int
+	// Fanout is the number of keys of each level under each key of the
+	// level above, from the first level. The last one is used for the
+	// levels without one, and 10 if it is empty.
+	Fanout []int
+	// KeySize is the length of the keys, 8 if zero. The keys are decimal
+	// numbers padded with zeros, so they sort like the numbers.
+	KeySize int
+	// ValueSize is the length of the values, 16 if zero.
+	ValueSize int
+	// Seed seeds the values and the keys sought by Seek.
+	Seed int64
+}
+
+// Uniform returns the layout of numKeys levels with the same fanout in all
+// of them, the smallest that has at least leaves leaves.
+func Uniform(numKeys, leaves int) Layout {
+	f := 2
+	for pow(f, numKeys) < leaves {
+		f++
+	}
+	return Layout{NumKeys: numKeys, Fanout: []int{f}}
+}
+
+func pow(f, n int) int {
+	p := 1
+	for i := 0; i < n; i++ {
+		p *= f
+	}
+	return p
+}
+
+// fanout returns the fanout of the level.
+func (l Layout) fanout(level int) int {
+	switch {
+	case len(l.Fanout) == 0:
+		return 10
+	case level < len(l.Fanout):
+		return l.Fanout[level]
+	}
+	return l.Fanout[len(l.Fanout)-1]
+}
+
+// Leaves returns the number of leaves of the tree.
+func (l Layout) Leaves() int {
+	n := 1
+	for i := 0; i < l.NumKeys; i++ {
+		n *= l.fanout(i)
+	}
+	return n
+}
+
+// Keys returns the key path of the leaf i, counting from zero in key order.
+func (l Layout) Keys(i int) [][]byte {
+	size := l.KeySize
+	if size == 0 {
+		size = 8
+	}
+	keys := make([][]byte, l.NumKeys)
+	for level := l.NumKeys - 1; level >= 0; level-- {
+		f := l.fanout(level)
+		keys[level] = []byte(fmt.Sprintf("%0*d", size, i%f))
+		i /= f
+	}
+	return keys
+}
+
+// Fill writes the leaves of l in bucket, with random values.
+func Fill(db *bolt.DB, bucket []byte, l Layout) error {
+	const chunk = 10000
+	size := l.ValueSize
+	if size == 0 {
+		size = 16
+	}
+	r := rand.New(rand.NewSource(l.Seed))
+	n := l.Leaves()
+	for i := 0; i < n; i += chunk {
+		items := make([]boltdbutils.Item, 0, chunk)
+		for j := i; j < n && j < i+chunk; j++ {
+			v := make([]byte, size)
+			r.Read(v)
+			items = append(items, boltdbutils.Item{Keys: l.Keys(j), Data: v})
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			return boltdbutils.PutBatch(tx, bucket, items)
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// NewDB creates the database bench.db in dir with the tree of l in bucket.
+func NewDB(dir string, bucket []byte, l Layout) (*bolt.DB, error) {
+	db, err := bolt.Open(filepath.Join(dir, "bench.db"), 0600, nil)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	db.NoSync = true
+	err = Fill(db, bucket, l)
+	if err != nil {
+		db.Close()
+		return nil, e.Forward(err)
+	}
+	return db, nil
+}
+
+// run runs fn b.N times with a cursor over the tree of l in a read
+// transaction.
+func run(b *testing.B, db *bolt.DB, bucket []byte, l Layout, fn func(c *boltdbutils.Cursor, i int)) {
+	tx, err := db.Begin(false)
+	if err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer tx.Rollback()
+	c := &boltdbutils.Cursor{
+		Tx:      tx,
+		Bucket:  bucket,
+		NumKeys: l.NumKeys,
+	}
+	err = c.Init()
+	if err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(c, i)
+	}
+	b.StopTimer()
+	if err := c.Err(); err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+// Skip benchmarks Cursor.Skip from the first leaf to all the leaves in turn.
+func Skip(b *testing.B, db *bolt.DB, bucket []byte, l Layout) {
+	n := l.Leaves()
+	run(b, db, bucket, l, func(c *boltdbutils.Cursor, i int) {
+		c.Skip(uint64(i % n))
+	})
+}
+
+// Seek benchmarks Cursor.Seek to random leaves.
+func Seek(b *testing.B, db *bolt.DB, bucket []byte, l Layout) {
+	r := rand.New(rand.NewSource(l.Seed))
+	keys := make([][][]byte, 1024)
+	for i := range keys {
+		keys[i] = l.Keys(r.Intn(l.Leaves()))
+	}
+	run(b, db, bucket, l, func(c *boltdbutils.Cursor, i int) {
+		if k, _ := c.Seek(keys[i%len(keys)]...); k == nil {
+			b.Fatal("leaf not found", boltdbutils.Path(keys[i%len(keys)]))
+		}
+	})
+}
+
+// Next benchmarks Cursor.Next over all the leaves, starting again from the
+// first one at the end.
+func Next(b *testing.B, db *bolt.DB, bucket []byte, l Layout) {
+	run(b, db, bucket, l, func(c *boltdbutils.Cursor, i int) {
+		if k, _ := c.Next(); k == nil {
+			c.First()
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+// leaves is the number of leaves of the trees of the benchmarks, about the
+// same in all depths.
+const leaves = 4096
+
+var bucket = []byte("bench")
+
+var (
+	dir string
+	dbs = map[int]*bolt.DB{}
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	dir, err = ioutil.TempDir("", "bench-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	for _, db := range dbs {
+		db.Close()
+	}
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// tree returns the database with the tree of depth levels, shared by the
+// benchmarks.
+func tree(b *testing.B, depth int) (*bolt.DB, Layout) {
+	l := Uniform(depth, leaves)
+	if db, ok := dbs[depth]; ok {
+		return db, l
+	}
+	d := filepath.Join(dir, strconv.Itoa(depth))
+	err := os.Mkdir(d, 0700)
+	if err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+	db, err := NewDB(d, bucket, l)
+	if err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+	dbs[depth] = db
+	return db, l
+}
+
+func depths(b *testing.B, fn func(b *testing.B, db *bolt.DB, bucket []byte, l Layout)) {
+	for depth := 2; depth <= 9; depth++ {
+		b.Run(fmt.Sprintf("depth=%v", depth), func(b *testing.B) {
+			db, l := tree(b, depth)
+			fn(b, db, bucket, l)
+		})
+	}
+}
+
+func BenchmarkSkip(b *testing.B) {
+	depths(b, Skip)
+}
+
+func BenchmarkSeek(b *testing.B) {
+	depths(b, Seek)
+}
+
+func BenchmarkNext(b *testing.B) {
+	depths(b, Next)
+}
+
+func TestLayout(t *testing.T) {
+	l := Layout{NumKeys: 3, Fanout: []int{2, 3}, KeySize: 2, ValueSize: 4}
+	if n := l.Leaves(); n != 18 {
+		t.Fatal("wrong number of leaves", n)
+	}
+	if p := boltdbutils.Path(l.Keys(17)).String(); p != "/01/02/02" {
+		t.Fatal("wrong keys", p)
+	}
+	if u := Uniform(2, leaves); u.Fanout[0] != 64 {
+		t.Fatal("wrong uniform layout", u)
+	}
+	if u := Uniform(9, leaves); u.Fanout[0] != 3 {
+		t.Fatal("wrong uniform layout", u)
+	}
+
+	d, err := ioutil.TempDir("", "bench-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer os.RemoveAll(d)
+	db, err := NewDB(d, bucket, l)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &boltdbutils.Cursor{Tx: tx, Bucket: bucket, NumKeys: l.NumKeys}
+		err := c.Init()
+		if err != nil {
+			return err
+		}
+		i := 0
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if boltdbutils.Path(k).String() != boltdbutils.Path(l.Keys(i)).String() || len(v) != 4 {
+				return e.New("wrong leaf %v: %v", i, boltdbutils.Path(k))
+			}
+			i++
+		}
+		if i != l.Leaves() {
+			return e.New("wrong number of leaves %v", i)
+		}
+		return c.Err()
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
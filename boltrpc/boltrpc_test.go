@@ -0,0 +1,141 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltrpc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func start(t *testing.T) (*bolt.DB, *Client, func()) {
+	dir, err := ioutil.TempDir("", "boltrpc-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(ServerOption())
+	Register(s, NewServer(db))
+	go s.Serve(l)
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	return db, NewClient(cc), func() {
+		cc.Close()
+		s.Stop()
+		db.Close()
+	}
+}
+
+func keys(path string) [][]byte {
+	var ks [][]byte
+	for _, k := range strings.Split(strings.Trim(path, "/"), "/") {
+		ks = append(ks, []byte(k))
+	}
+	return ks
+}
+
+func TestServer(t *testing.T) {
+	_, c, stop := start(t)
+	defer stop()
+	ctx := context.Background()
+	bucket := []byte("posts")
+
+	for _, p := range []string{"/en/2015/a", "/en/2015/b", "/en/2016/c", "/pt/2015/d"} {
+		err := c.Put(ctx, bucket, keys(p), []byte("value "+p))
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	v, err := c.Get(ctx, bucket, keys("/en/2015/b"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if string(v) != "value /en/2015/b" {
+		t.Fatal("wrong value", string(v))
+	}
+	_, err = c.Get(ctx, bucket, keys("/en/2015/x"))
+	if status.Code(err) != codes.NotFound || status.Convert(err).Message() != boltdbutils.ErrKeyNotFound {
+		t.Fatal("wrong error", err)
+	}
+
+	scan := func(req *ScanRequest) string {
+		var paths []string
+		err := c.Scan(ctx, req, func(entry *Entry) error {
+			if !bytes.HasPrefix(entry.Value, []byte("value ")) {
+				t.Fatal("wrong value", string(entry.Value))
+			}
+			paths = append(paths, boltdbutils.Path(entry.Keys).String())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		return strings.Join(paths, " ")
+	}
+	if s := scan(&ScanRequest{Bucket: bucket, NumKeys: 3}); s != "/en/2015/a /en/2015/b /en/2016/c /pt/2015/d" {
+		t.Fatal("wrong scan", s)
+	}
+	if s := scan(&ScanRequest{Bucket: bucket, Prefix: keys("/en"), NumKeys: 3, Limit: 2, Reverse: true}); s != "/en/2016/c /en/2015/b" {
+		t.Fatal("wrong reverse scan", s)
+	}
+	err = c.Scan(ctx, &ScanRequest{Bucket: bucket}, func(*Entry) error { return nil })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatal("scan without NumKeys", err)
+	}
+
+	err = c.Del(ctx, bucket, keys("/en"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if s := scan(&ScanRequest{Bucket: bucket, NumKeys: 3}); s != "/pt/2015/d" {
+		t.Fatal("wrong scan after Del", s)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	req := &ScanRequest{
+		Bucket:  []byte("b"),
+		Prefix:  [][]byte{[]byte("a"), {}},
+		NumKeys: 3,
+		Limit:   300,
+		Reverse: true,
+	}
+	// An unknown field is skipped.
+	b := append(req.marshal(), 0x30, 0x01)
+	var got ScanRequest
+	err := got.unmarshal(b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if string(got.Bucket) != "b" || len(got.Prefix) != 2 || string(got.Prefix[0]) != "a" || got.NumKeys != 3 || got.Limit != 300 || !got.Reverse {
+		t.Fatalf("wrong message %+v", got)
+	}
+	err = got.unmarshal([]byte{0x0a, 0x05, 'x'})
+	if err == nil {
+		t.Fatal("truncated message decoded")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltrpc
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+)
+
+// Client is a client of the service Tree.
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+// NewClient returns a client of the service in cc. The calls set the codec
+// of the package, cc doesn't need an option.
+func NewClient(cc *grpc.ClientConn) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) call(ctx context.Context, method string, req, resp interface{}) error {
+	return c.cc.Invoke(ctx, "/boltdbutils.Tree/"+method, req, resp, grpc.ForceCodec(codec{}))
+}
+
+// Get returns the value of the leaf in keys.
+func (c *Client) Get(ctx context.Context, bucket []byte, keys [][]byte) ([]byte, error) {
+	var resp GetResponse
+	err := c.call(ctx, "Get", &GetRequest{Bucket: bucket, Keys: keys}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// Put writes the value of the leaf in keys.
+func (c *Client) Put(ctx context.Context, bucket []byte, keys [][]byte, value []byte) error {
+	return c.call(ctx, "Put", &PutRequest{Bucket: bucket, Keys: keys, Value: value}, &PutResponse{})
+}
+
+// Del deletes the leaf in keys, or the subtree of a partial key path.
+func (c *Client) Del(ctx context.Context, bucket []byte, keys [][]byte) error {
+	return c.call(ctx, "Del", &DelRequest{Bucket: bucket, Keys: keys}, &DelResponse{})
+}
+
+// Scan calls fn with the leaves of the scan, until the end or an error of
+// fn. Cancel ctx to stop the stream on the server too.
+func (c *Client) Scan(ctx context.Context, req *ScanRequest, fn func(e *Entry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], "/boltdbutils.Tree/Scan", grpc.ForceCodec(codec{}))
+	if err != nil {
+		return err
+	}
+	err = stream.SendMsg(req)
+	if err != nil {
+		return err
+	}
+	err = stream.CloseSend()
+	if err != nil {
+		return err
+	}
+	for {
+		entry := new(Entry)
+		err := stream.RecvMsg(entry)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+}
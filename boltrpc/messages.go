@@ -0,0 +1,237 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltrpc
+
+import (
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// The messages of boltdbutils.proto, encoded by hand with protowire so the
+// package doesn't need generated code. The unknown fields are skipped.
+
+type GetRequest struct {
+	Bucket []byte
+	Keys   [][]byte
+}
+
+type GetResponse struct {
+	Value []byte
+}
+
+type PutRequest struct {
+	Bucket []byte
+	Keys   [][]byte
+	Value  []byte
+}
+
+type PutResponse struct{}
+
+type DelRequest struct {
+	Bucket []byte
+	Keys   [][]byte
+}
+
+type DelResponse struct{}
+
+type ScanRequest struct {
+	Bucket []byte
+	Prefix [][]byte
+	// NumKeys is the number of levels of the tree, from the registry if
+	// zero.
+	NumKeys uint32
+	// Limit is the maximum number of entries, zero for all.
+	Limit   uint64
+	Reverse bool
+}
+
+type Entry struct {
+	Keys  [][]byte
+	Value []byte
+}
+
+// message is implemented by the messages of the service.
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// field is a field of a message for the decoder.
+type field struct {
+	num protowire.Number
+	// bytes, repeated or varint is set, by the type of the field.
+	bytes    *[]byte
+	repeated *[][]byte
+	varint   func(v uint64)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendRepeated(b []byte, num protowire.Number, vs [][]byte) []byte {
+	for _, v := range vs {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, v)
+	}
+	return b
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// decode reads the fields of b into fields.
+func decode(b []byte, fields ...field) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var f *field
+		for i := range fields {
+			if fields[i].num == num {
+				f = &fields[i]
+			}
+		}
+		switch {
+		case f != nil && typ == protowire.BytesType && (f.bytes != nil || f.repeated != nil):
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			v = append([]byte{}, v...)
+			if f.bytes != nil {
+				*f.bytes = v
+			} else {
+				*f.repeated = append(*f.repeated, v)
+			}
+			b = b[n:]
+		case f != nil && typ == protowire.VarintType && f.varint != nil:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			f.varint(v)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+func (m *GetRequest) marshal() []byte {
+	return appendRepeated(appendBytes(nil, 1, m.Bucket), 2, m.Keys)
+}
+
+func (m *GetRequest) unmarshal(b []byte) error {
+	return decode(b, field{num: 1, bytes: &m.Bucket}, field{num: 2, repeated: &m.Keys})
+}
+
+func (m *GetResponse) marshal() []byte {
+	return appendBytes(nil, 1, m.Value)
+}
+
+func (m *GetResponse) unmarshal(b []byte) error {
+	return decode(b, field{num: 1, bytes: &m.Value})
+}
+
+func (m *PutRequest) marshal() []byte {
+	b := appendRepeated(appendBytes(nil, 1, m.Bucket), 2, m.Keys)
+	return appendBytes(b, 3, m.Value)
+}
+
+func (m *PutRequest) unmarshal(b []byte) error {
+	return decode(b, field{num: 1, bytes: &m.Bucket}, field{num: 2, repeated: &m.Keys}, field{num: 3, bytes: &m.Value})
+}
+
+func (m *PutResponse) marshal() []byte {
+	return nil
+}
+
+func (m *PutResponse) unmarshal(b []byte) error {
+	return decode(b)
+}
+
+func (m *DelRequest) marshal() []byte {
+	return appendRepeated(appendBytes(nil, 1, m.Bucket), 2, m.Keys)
+}
+
+func (m *DelRequest) unmarshal(b []byte) error {
+	return decode(b, field{num: 1, bytes: &m.Bucket}, field{num: 2, repeated: &m.Keys})
+}
+
+func (m *DelResponse) marshal() []byte {
+	return nil
+}
+
+func (m *DelResponse) unmarshal(b []byte) error {
+	return decode(b)
+}
+
+func (m *ScanRequest) marshal() []byte {
+	b := appendRepeated(appendBytes(nil, 1, m.Bucket), 2, m.Prefix)
+	b = appendVarint(b, 3, uint64(m.NumKeys))
+	b = appendVarint(b, 4, m.Limit)
+	return appendVarint(b, 5, protowire.EncodeBool(m.Reverse))
+}
+
+func (m *ScanRequest) unmarshal(b []byte) error {
+	return decode(b,
+		field{num: 1, bytes: &m.Bucket},
+		field{num: 2, repeated: &m.Prefix},
+		field{num: 3, varint: func(v uint64) { m.NumKeys = uint32(v) }},
+		field{num: 4, varint: func(v uint64) { m.Limit = v }},
+		field{num: 5, varint: func(v uint64) { m.Reverse = protowire.DecodeBool(v) }},
+	)
+}
+
+func (m *Entry) marshal() []byte {
+	return appendBytes(appendRepeated(nil, 1, m.Keys), 2, m.Value)
+}
+
+func (m *Entry) unmarshal(b []byte) error {
+	return decode(b, field{num: 1, repeated: &m.Keys}, field{num: 2, bytes: &m.Value})
+}
+
+// codec encodes the messages of the service and leaves the generated
+// messages of the other services to the proto codec, so it can be the codec
+// of a server shared with them.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(message); ok {
+		return m.marshal(), nil
+	}
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(message); ok {
+		return m.unmarshal(data)
+	}
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+var _ encoding.Codec = codec{}
@@ -0,0 +1,227 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package boltrpc serves the trees of a database over gRPC, with the service
+// Tree of boltdbutils.proto, and has its client.
+//
+// The messages are encoded by hand, the server and the client use the codec
+// of the package instead of the one of grpc:
+//
+//	s := grpc.NewServer(boltrpc.ServerOption())
+//	boltrpc.Register(s, boltrpc.NewServer(db))
+//
+// The codec encodes the generated messages too, so the server can serve
+// other services.
+package boltrpc
+
+import (
+	"context"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const ErrReadOnly = "read only server"
+
+// Server implements the service Tree with the trees of a database.
+type Server struct {
+	db *bolt.DB
+	// ReadOnly rejects Put and Del.
+	ReadOnly bool
+}
+
+// NewServer returns a server of the trees of db.
+func NewServer(db *bolt.DB) *Server {
+	return &Server{db: db}
+}
+
+// ServerOption returns the option that sets the codec of the server.
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// Register registers srv in s.
+func Register(s *grpc.Server, srv *Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	var resp GetResponse
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v, err := boltdbutils.Get(tx, req.Bucket, req.Keys)
+		if err != nil {
+			return err
+		}
+		resp.Value = append([]byte{}, v...)
+		return nil
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &resp, nil
+}
+
+func (s *Server) Put(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+	if s.ReadOnly {
+		return nil, status.Error(codes.PermissionDenied, ErrReadOnly)
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltdbutils.Put(tx, req.Bucket, req.Keys, req.Value)
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &PutResponse{}, nil
+}
+
+func (s *Server) Del(ctx context.Context, req *DelRequest) (*DelResponse, error) {
+	if s.ReadOnly {
+		return nil, status.Error(codes.PermissionDenied, ErrReadOnly)
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltdbutils.Del(tx, req.Bucket, req.Keys)
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &DelResponse{}, nil
+}
+
+// Scan sends the leaves in one read transaction, a slow client holds it
+// open until the end of the stream or the limit.
+func (s *Server) Scan(req *ScanRequest, stream grpc.ServerStream) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		numKeys := int(req.NumKeys)
+		if numKeys == 0 {
+			info, err := boltdbutils.Lookup(tx, req.Bucket)
+			if err != nil || info.NumKeys == 0 {
+				return e.New(boltdbutils.ErrNotRegistered)
+			}
+			numKeys = info.NumKeys
+		}
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  req.Bucket,
+			NumKeys: numKeys,
+			Reverse: req.Reverse,
+		}
+		err := c.Init(req.Prefix...)
+		if err != nil {
+			return err
+		}
+		var n uint64
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if req.Limit > 0 && n >= req.Limit {
+				return nil
+			}
+			err := stream.SendMsg(&Entry{Keys: k, Value: v})
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		return c.Err()
+	})
+	if err != nil {
+		return rpcError(err)
+	}
+	return nil
+}
+
+// codeOf maps the errors of boltdbutils to the status codes.
+var codeOf = []struct {
+	kind string
+	code codes.Code
+}{
+	{boltdbutils.ErrKeyNotFound, codes.NotFound},
+	{boltdbutils.ErrInvBucket, codes.NotFound},
+	{boltdbutils.ErrExpired, codes.NotFound},
+	{boltdbutils.ErrNoKeys, codes.InvalidArgument},
+	{boltdbutils.ErrArity, codes.InvalidArgument},
+	{boltdbutils.ErrNotRegistered, codes.InvalidArgument},
+	{boltdbutils.ErrFrozen, codes.FailedPrecondition},
+	{boltdbutils.ErrQuotaExceeded, codes.ResourceExhausted},
+}
+
+// rpcError returns the status of err. The message is the Err constant of
+// boltdbutils, the errors of the stream are returned as they are.
+func rpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, c := range codeOf {
+		if boltdbutils.IsError(err, c.kind) {
+			return status.Error(c.code, c.kind)
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "boltdbutils.Tree",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Get", Handler: getHandler},
+		{MethodName: "Put", Handler: putHandler},
+		{MethodName: "Del", Handler: delHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Scan", Handler: scanHandler, ServerStreams: true},
+	},
+	Metadata: "boltdbutils.proto",
+}
+
+func getHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(GetRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).Get(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/boltdbutils.Tree/Get"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).Get(ctx, req.(*GetRequest))
+	})
+}
+
+func putHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(PutRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).Put(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/boltdbutils.Tree/Put"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).Put(ctx, req.(*PutRequest))
+	})
+}
+
+func delHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(DelRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).Del(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/boltdbutils.Tree/Del"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).Del(ctx, req.(*DelRequest))
+	})
+}
+
+func scanHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(ScanRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(*Server).Scan(req, stream)
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const bucketsBucket = "__buckets"
+
+// BucketCounters shows how many intermediate buckets a top bucket has. A
+// layout that creates a bucket for almost every leaf shows a Live close to
+// the number of entries.
+type BucketCounters struct {
+	// Live is the number of intermediate buckets in the tree. It is stored in
+	// the database and updated in the same transaction that creates or
+	// deletes the buckets.
+	Live uint64
+	// Created is the number of intermediate buckets created since the process
+	// started.
+	Created uint64
+	// Deleted is the number of intermediate buckets deleted since the process
+	// started.
+	Deleted uint64
+}
+
+type bucketCounter struct {
+	created uint64
+	deleted uint64
+}
+
+var bucketCounters sync.Map
+
+func counterFor(bucket []byte) *bucketCounter {
+	c, ok := bucketCounters.Load(string(bucket))
+	if !ok {
+		c, _ = bucketCounters.LoadOrStore(string(bucket), new(bucketCounter))
+	}
+	return c.(*bucketCounter)
+}
+
+// countBuckets adds delta to the number of intermediate buckets of bucket.
+// The process counters change only if the transaction commits.
+func countBuckets(tx *bolt.Tx, bucket []byte, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	live := int64(liveBuckets(tx.Bucket([]byte(bucketsBucket)), bucket)) + delta
+	if live < 0 {
+		live = 0
+	}
+	err := setLiveBuckets(tx, bucket, uint64(live))
+	if err != nil {
+		return e.Forward(err)
+	}
+	c := counterFor(bucket)
+	tx.OnCommit(func() {
+		if delta > 0 {
+			atomic.AddUint64(&c.created, uint64(delta))
+		} else {
+			atomic.AddUint64(&c.deleted, uint64(-delta))
+		}
+	})
+	return nil
+}
+
+// setLiveBuckets stores the gauge of bucket. A zero gauge isn't stored, so
+// a database without intermediate buckets has no metadata either.
+func setLiveBuckets(tx *bolt.Tx, bucket []byte, live uint64) error {
+	if live == 0 {
+		return forgetBuckets(tx, bucket)
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(bucketsBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, live)
+	err = b.Put(bucket, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// forgetBuckets removes the gauge of a top bucket that was deleted.
+func forgetBuckets(tx *bolt.Tx, bucket []byte) error {
+	b := tx.Bucket([]byte(bucketsBucket))
+	if b == nil {
+		return nil
+	}
+	err := b.Delete(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(b) {
+		err = tx.DeleteBucket([]byte(bucketsBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+func liveBuckets(b *bolt.Bucket, bucket []byte) uint64 {
+	if b == nil {
+		return 0
+	}
+	buf := b.Get(bucket)
+	if len(buf) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+// BucketMetrics returns the counters of every top bucket seen by the database
+// or by this process.
+func BucketMetrics(tx *bolt.Tx) map[string]BucketCounters {
+	m := make(map[string]BucketCounters)
+	b := tx.Bucket([]byte(bucketsBucket))
+	if b != nil {
+		b.ForEach(func(k, v []byte) error {
+			m[string(k)] = BucketCounters{Live: liveBuckets(b, k)}
+			return nil
+		})
+	}
+	bucketCounters.Range(func(k, v interface{}) bool {
+		c := v.(*bucketCounter)
+		bc := m[k.(string)]
+		bc.Created = atomic.LoadUint64(&c.created)
+		bc.Deleted = atomic.LoadUint64(&c.deleted)
+		m[k.(string)] = bc
+		return true
+	})
+	return m
+}
+
+// RecountBuckets walks the tree of bucket and stores the number of
+// intermediate buckets. Use it on databases written before the counters
+// existed.
+func RecountBuckets(tx *bolt.Tx, bucket []byte) error {
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return newKeyError(ErrInvBucket, bucket, -1, nil)
+	}
+	err := setLiveBuckets(tx, bucket, treeStats(tx, b).Buckets)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestBucketMetrics(t *testing.T) {
+	data := []testData{
+		{[]byte("test_metrics"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_metrics"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_metrics"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	check := func(bucket string, want BucketCounters) {
+		err := db.View(func(tx *bolt.Tx) error {
+			got := BucketMetrics(tx)[bucket]
+			if got != want {
+				return e.New("wrong counters for %v: %#v", bucket, got)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// 2014, 2014/01, 2014/02, 2015 and 2015/01.
+	check("test_metrics", BucketCounters{Live: 5, Created: 5})
+
+	// A rolled back transaction doesn't change the counters.
+	db.Update(func(tx *bolt.Tx) error {
+		Put(tx, []byte("test_metrics"), [][]byte{[]byte("2016"), []byte("01"), []byte("d")}, []byte("4"))
+		return e.New("rollback")
+	})
+	check("test_metrics", BucketCounters{Live: 5, Created: 5})
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return Del(tx, data[2].Bucket, data[2].Keys)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("test_metrics", BucketCounters{Live: 3, Created: 5, Deleted: 2})
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return CloneBucket(tx, []byte("test_metrics"), []byte("test_metrics_clone"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("test_metrics_clone", BucketCounters{Live: 3, Created: 3})
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(bucketsBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return RecountBuckets(tx, []byte("test_metrics"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("test_metrics", BucketCounters{Live: 3, Created: 5, Deleted: 2})
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return DropBucket(tx, []byte("test_metrics"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("test_metrics", BucketCounters{Created: 5, Deleted: 5})
+}
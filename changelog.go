@@ -0,0 +1,236 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const (
+	// changelogBucket has the changes keyed by their sequence number.
+	changelogBucket = "__changelog"
+	// changelogOnBucket has the buckets with the changelog enabled.
+	changelogOnBucket = "__changelog_on"
+)
+
+const ErrInvChange = "invalid change record"
+
+// ChangesChunk is the maximum number of changes returned by ReadChanges.
+var ChangesChunk = 1000
+
+// Change is a record of the changelog.
+type Change struct {
+	Seq    uint64
+	Op     EventOp
+	Bucket []byte
+	// Keys is nil for EventDrop.
+	Keys  [][]byte
+	Value []byte
+	Time  time.Time
+}
+
+// EnableChangelog makes the writes in bucket append a Change to the
+// changelog. Put and PutBatch record EventPut, Del records EventDel and
+// DropBucket, Wipe and PromoteBucket record EventDrop. The subtrees moved or
+// copied into bucket and the trees promoted are recorded as one EventPut per
+// leaf, so replaying the changelog rebuilds the bucket.
+func EnableChangelog(tx *bolt.Tx, bucket []byte) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(changelogOnBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(bucket, []byte{})
+	if err != nil {
+		return e.Forward(err)
+	}
+	_, err = tx.CreateBucketIfNotExists([]byte(changelogBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// DisableChangelog stops recording the changes of bucket. The changelog is
+// kept until TruncateChanges.
+func DisableChangelog(tx *bolt.Tx, bucket []byte) error {
+	b := tx.Bucket([]byte(changelogOnBucket))
+	if b == nil {
+		return nil
+	}
+	err := b.Delete(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(b) {
+		err = tx.DeleteBucket([]byte(changelogOnBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return removeEmptyChangelog(tx)
+}
+
+// removeEmptyChangelog deletes the empty changelog if no bucket uses it. The
+// sequence restarts, so it is kept while a bucket may append to it.
+func removeEmptyChangelog(tx *bolt.Tx) error {
+	b := tx.Bucket([]byte(changelogBucket))
+	if b == nil || !empty(b) || tx.Bucket([]byte(changelogOnBucket)) != nil {
+		return nil
+	}
+	err := tx.DeleteBucket([]byte(changelogBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// ChangelogSeq returns the sequence number of the last change recorded.
+func ChangelogSeq(tx *bolt.Tx) uint64 {
+	b := tx.Bucket([]byte(changelogBucket))
+	if b == nil {
+		return 0
+	}
+	return b.Sequence()
+}
+
+// ReadChanges returns up to ChangesChunk changes recorded after sinceSeq, in
+// order. Call it again with the Seq of the last change to read the next. The
+// values are decoded, the ones of an EncryptedBucket in clear.
+func ReadChanges(tx *bolt.Tx, sinceSeq uint64) ([]Change, error) {
+	b := tx.Bucket([]byte(changelogBucket))
+	if b == nil {
+		return nil, nil
+	}
+	var changes []Change
+	c := b.Cursor()
+	for k, v := c.Seek(seqKey(sinceSeq + 1)); k != nil && len(changes) < ChangesChunk; k, v = c.Next() {
+		ch, err := decodeChange(k, v)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		changes = append(changes, ch)
+	}
+	return changes, nil
+}
+
+// TruncateChanges removes the changes up to upToSeq and returns how many
+// were removed.
+func TruncateChanges(tx *bolt.Tx, upToSeq uint64) (int, error) {
+	b := tx.Bucket([]byte(changelogBucket))
+	if b == nil {
+		return 0, nil
+	}
+	var del [][]byte
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= upToSeq; k, _ = c.Next() {
+		del = append(del, append([]byte{}, k...))
+	}
+	for _, k := range del {
+		err := b.Delete(k)
+		if err != nil {
+			return 0, e.Forward(err)
+		}
+	}
+	err := removeEmptyChangelog(tx)
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	return len(del), nil
+}
+
+func changelogOn(tx *bolt.Tx, bucket []byte) bool {
+	b := tx.Bucket([]byte(changelogOnBucket))
+	return b != nil && b.Get(bucket) != nil
+}
+
+// logChange appends the change to the changelog if it is enabled in bucket.
+// value is the value of the leaf as stored, so it is also encrypted.
+func logChange(tx *bolt.Tx, op EventOp, bucket []byte, keys [][]byte, value []byte) error {
+	if !changelogOn(tx, bucket) {
+		return nil
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(changelogBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(seqKey(seq), encodeChange(&Change{
+		Op:     op,
+		Bucket: bucket,
+		Keys:   keys,
+		Value:  value,
+		Time:   time.Now(),
+	}))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// encodeChange serializes all fields of ch but Seq.
+func encodeChange(ch *Change) []byte {
+	path := encodeKeys(append([][]byte{ch.Bucket}, ch.Keys...)...)
+	buf := make([]byte, 9+binary.MaxVarintLen64, 9+binary.MaxVarintLen64+len(path)+len(ch.Value))
+	buf[0] = byte(ch.Op)
+	binary.BigEndian.PutUint64(buf[1:], uint64(ch.Time.UnixNano()))
+	n := 9 + binary.PutUvarint(buf[9:], uint64(len(path)))
+	return append(append(buf[:n], path...), ch.Value...)
+}
+
+// logTree records an EventPut for each leaf under prefix.
+func logTree(tx *bolt.Tx, bucket []byte, prefix [][]byte) error {
+	if !changelogOn(tx, bucket) {
+		return nil
+	}
+	b, err := prefixBucket(tx, bucket, prefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	keys := make([][]byte, len(prefix), len(prefix)+8)
+	copy(keys, prefix)
+	err = walkTree(tx, b, keys, func(keys [][]byte, v []byte) error {
+		return logChange(tx, EventPut, bucket, keys, v)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+func decodeChange(k, v []byte) (Change, error) {
+	if len(k) != 8 || len(v) < 10 {
+		return Change{}, e.New(ErrInvChange)
+	}
+	ch := Change{
+		Seq:  binary.BigEndian.Uint64(k),
+		Op:   EventOp(v[0]),
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(v[1:]))),
+	}
+	l, n := binary.Uvarint(v[9:])
+	if n <= 0 || uint64(len(v)-9-n) < l {
+		return Change{}, e.New(ErrInvChange)
+	}
+	keys, err := decodeKeys(v[9+n : 9+n+int(l)])
+	if err != nil || len(keys) == 0 {
+		return Change{}, e.New(ErrInvChange)
+	}
+	ch.Bucket = keys[0]
+	if len(keys) > 1 {
+		ch.Keys = keys[1:]
+	}
+	value, err := decodeValue(ch.Bucket, ch.Keys, v[9+n+int(l):])
+	if err != nil {
+		return Change{}, e.Forward(err)
+	}
+	ch.Value = append([]byte{}, value...)
+	return ch, nil
+}
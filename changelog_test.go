@@ -0,0 +1,149 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestChangelog(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_changelog")
+	a := [][]byte{[]byte("en"), []byte("a")}
+	b := [][]byte{[]byte("en"), []byte("b")}
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Not recorded, the changelog is off.
+		err := Put(tx, bucket, a, []byte("0"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = EnableChangelog(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, bucket, a, []byte("1"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = PutBatch(tx, bucket, []Item{{Keys: b, Data: []byte("2")}})
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Del(tx, bucket, a)
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Other buckets aren't recorded.
+		return Put(tx, []byte("test_other"), a, []byte("x"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		changes, err := ReadChanges(tx, 0)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(changes) != 3 {
+			return e.New("wrong number of changes %v", len(changes))
+		}
+		want := []struct {
+			op    EventOp
+			path  string
+			value string
+		}{
+			{EventPut, "/en/a", "1"},
+			{EventPut, "/en/b", "2"},
+			{EventDel, "/en/a", ""},
+		}
+		for i, w := range want {
+			ch := changes[i]
+			if ch.Seq != uint64(i+1) || ch.Op != w.op || string(ch.Bucket) != string(bucket) ||
+				Path(ch.Keys).String() != w.path || string(ch.Value) != w.value || ch.Time.IsZero() {
+				return e.New("wrong change %v %#v", i, ch)
+			}
+		}
+
+		old := ChangesChunk
+		ChangesChunk = 1
+		changes, err = ReadChanges(tx, 1)
+		ChangesChunk = old
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(changes) != 1 || changes[0].Seq != 2 {
+			return e.New("wrong chunk %#v", changes)
+		}
+
+		n, err := TruncateChanges(tx, 2)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 2 {
+			return e.New("wrong number of changes removed %v", n)
+		}
+		err = DropBucket(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		changes, err = ReadChanges(tx, 0)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(changes) != 2 || changes[1].Op != EventDrop || changes[1].Keys != nil {
+			return e.New("wrong changes after the drop %#v", changes)
+		}
+
+		// The sequence survives an empty changelog.
+		_, err = TruncateChanges(tx, ChangelogSeq(tx))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, bucket, a, []byte("3"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if ChangelogSeq(tx) != 5 {
+			return e.New("sequence restarted %v", ChangelogSeq(tx))
+		}
+
+		err = DisableChangelog(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, err = TruncateChanges(tx, ChangelogSeq(tx))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket([]byte(changelogBucket)) != nil || tx.Bucket([]byte(changelogOnBucket)) != nil {
+			return e.New("changelog buckets not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// The kinds of Problem found by CheckIndex.
+const (
+	// ProblemDangling is a key with the name of an intermediate bucket that
+	// doesn't exist.
+	ProblemDangling = "dangling intermediate key"
+	// ProblemEmpty is an intermediate bucket without keys.
+	ProblemEmpty = "empty intermediate bucket"
+	// ProblemCollision is a leaf value in the last level that names an
+	// intermediate bucket, so it is read as an intermediate key.
+	ProblemCollision = "leaf value names a bucket"
+	// ProblemDepth is a leaf in a level other than the one of the others.
+	ProblemDepth = "leaf in the wrong level"
+	// ProblemShared is an intermediate bucket referenced by more than one
+	// key.
+	ProblemShared = "intermediate bucket shared"
+)
+
+// Problem is a violation of the invariants of the tree found by CheckIndex.
+type Problem struct {
+	// Kind is one of the Problem constants.
+	Kind string
+	// Keys is the key path of the key with the problem.
+	Keys [][]byte
+	// Value is the value of the key, the name of the bucket for the
+	// problems of the intermediate buckets.
+	Value []byte
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%v at %v", p.Kind, Path(p.Keys))
+}
+
+// CheckIndex walks the tree of bucket and returns the violations of its
+// invariants. The depth of the leaves is the NumKeys of the registered
+// BucketInfo, or the depth of the first leaf. Without the registry a leaf
+// value that names an intermediate bucket can't be told from an intermediate
+// key of a deeper subtree, so it is reported as ProblemDepth. The values
+// that aren't named like intermediate buckets are leaves, also if they name
+// another bucket.
+func CheckIndex(tx *bolt.Tx, bucket []byte) ([]Problem, error) {
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return nil, newKeyError(ErrInvBucket, bucket, -1, nil)
+	}
+	ck := &checker{
+		tx:   tx,
+		seen: make(map[string]bool),
+	}
+	if info, err := Lookup(tx, bucket); err == nil && info.NumKeys > 0 {
+		ck.numKeys = info.NumKeys
+		ck.registered = true
+	}
+	ck.check(b, nil)
+	return ck.problems, nil
+}
+
+type checker struct {
+	tx *bolt.Tx
+	// numKeys is the depth of the leaves, zero until the first leaf if the
+	// bucket isn't registered.
+	numKeys    int
+	registered bool
+	seen       map[string]bool
+	problems   []Problem
+}
+
+func (ck *checker) report(kind string, keys [][]byte, v []byte) {
+	ck.problems = append(ck.problems, Problem{
+		Kind:  kind,
+		Keys:  copyKeys(keys),
+		Value: append([]byte{}, v...),
+	})
+}
+
+func (ck *checker) check(b *bolt.Bucket, keys [][]byte) {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		path := append(keys[:len(keys):len(keys)], k)
+		sub := subBucket(ck.tx, v)
+		if ck.numKeys > 0 && len(path) == ck.numKeys {
+			if sub != nil && ck.registered {
+				ck.report(ProblemCollision, path, v)
+			} else if sub != nil {
+				ck.report(ProblemDepth, path, v)
+			}
+			continue
+		}
+		if sub == nil {
+			if isUUID(v) || isCompactID(v) {
+				ck.report(ProblemDangling, path, v)
+				continue
+			}
+			if ck.numKeys == 0 {
+				ck.numKeys = len(path)
+			} else {
+				ck.report(ProblemDepth, path, v)
+			}
+			continue
+		}
+		if ck.seen[string(v)] {
+			ck.report(ProblemShared, path, v)
+			continue
+		}
+		ck.seen[string(v)] = true
+		if empty(sub) {
+			ck.report(ProblemEmpty, path, v)
+			continue
+		}
+		ck.check(sub, path)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// breakIndex writes in the two levels tree of bucket one violation of each
+// kind, under the first level keys named after them. The leaf of collision
+// names a bucket that isn't an intermediate bucket, so it is only a leaf in
+// the wrong level.
+func breakIndex(tx *bolt.Tx, bucket []byte) error {
+	for _, k := range []string{"a", "b", "shared"} {
+		err := Put(tx, bucket, [][]byte{[]byte(k), []byte("1")}, []byte("v"))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	_, err := tx.CreateBucketIfNotExists([]byte("test_other"))
+	if err != nil {
+		return e.Forward(err)
+	}
+	_, err = tx.CreateBucket([]byte("00000000-0000-0000-0000-000000000001"))
+	if err != nil {
+		return e.Forward(err)
+	}
+	root := tx.Bucket(bucket)
+	puts := [][2]string{
+		{"dangling", "00000000-0000-0000-0000-000000000000"},
+		{"empty", "00000000-0000-0000-0000-000000000001"},
+		{"collision", "test_other"},
+		{"shallow", "leaf"},
+		{"shared2", string(root.Get([]byte("shared")))},
+	}
+	for _, p := range puts {
+		err = root.Put([]byte(p[0]), []byte(p[1]))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	b, err := prefixBucket(tx, bucket, [][]byte{[]byte("a")})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return b.Put([]byte("2"), root.Get([]byte("b")))
+}
+
+func problemsString(problems []Problem) string {
+	var s []string
+	for _, p := range problems {
+		s = append(s, p.String())
+	}
+	sort.Strings(s)
+	return strings.Join(s, "; ")
+}
+
+func TestCheckIndex(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, k := range []string{"a", "b"} {
+			err := Put(tx, bucket, [][]byte{[]byte(k), []byte("1")}, []byte("v"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		problems, err := CheckIndex(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(problems) != 0 {
+			return e.New("problems in a sound tree: %v", problemsString(problems))
+		}
+		_, err = CheckIndex(tx, []byte("test_none"))
+		if !IsError(err, ErrInvBucket) {
+			return e.New("missing bucket checked: %v", err)
+		}
+
+		err = breakIndex(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Without the registry the leaf of the last level that names a
+		// bucket looks like a deeper subtree.
+		problems, err = CheckIndex(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		want := "dangling intermediate key at /dangling; " +
+			"empty intermediate bucket at /empty; " +
+			"intermediate bucket shared at /shared2; " +
+			"leaf in the wrong level at /a/2; " +
+			"leaf in the wrong level at /collision; " +
+			"leaf in the wrong level at /shallow"
+		if got := problemsString(problems); got != want {
+			return e.New("wrong problems without registry:\n%v\nwant\n%v", got, want)
+		}
+
+		err = Register(tx, bucket, BucketInfo{NumKeys: 2})
+		if err != nil {
+			return e.Forward(err)
+		}
+		problems, err = CheckIndex(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		want = strings.Replace(want, "leaf in the wrong level at /a/2", "leaf value names a bucket at /a/2", 1)
+		if got := problemsString(problems); got != strings.Join(sortedParts(want), "; ") {
+			return e.New("wrong problems:\n%v\nwant\n%v", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func sortedParts(s string) []string {
+	parts := strings.Split(s, "; ")
+	sort.Strings(parts)
+	return parts
+}
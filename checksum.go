@@ -0,0 +1,92 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrChecksum = "value checksum mismatch"
+
+// crcLen is the length of the checksum trailer.
+const crcLen = 4
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SetChecksum turns on or off the CRC32C trailer of the values of bucket
+// written after. The setting is recorded in the Codecs of the description of
+// bucket, like SetValueCodec. The values with the trailer are verified when
+// they are read, and the ones that don't match fail with ErrChecksum. The
+// values without it, written before, still read.
+func SetChecksum(tx *bolt.Tx, bucket []byte, on bool) error {
+	err := setCodecs(tx, bucket, func(names []string) []string {
+		if len(names) > 0 && names[len(names)-1] == checksumCodec {
+			names = names[:len(names)-1]
+		}
+		if on {
+			names = append(names, checksumCodec)
+		}
+		return names
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// appendChecksum wraps buf with the header of crcID and the trailer with the
+// checksum of the header and buf.
+func appendChecksum(buf []byte) []byte {
+	out := make([]byte, len(valueMagic)+1+len(buf), len(valueMagic)+1+len(buf)+crcLen)
+	n := copy(out, valueMagic)
+	out[n] = crcID
+	copy(out[n+1:], buf)
+	var sum [crcLen]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(out, crcTable))
+	return append(out, sum[:]...)
+}
+
+// verifyChecksum checks the trailer of v, a value made by appendChecksum.
+func verifyChecksum(v []byte) error {
+	if len(v) < len(valueMagic)+1+crcLen {
+		return e.New(ErrChecksum)
+	}
+	n := len(v) - crcLen
+	if crc32.Checksum(v[:n], crcTable) != binary.BigEndian.Uint32(v[n:]) {
+		return e.New(ErrChecksum)
+	}
+	return nil
+}
+
+// ScrubBucket reads every leaf of bucket and returns the ones that can't be
+// decoded, with the kind ErrChecksum if the checksum doesn't match or
+// ErrDecode for the other failures, like a missing key of EncryptedBucket.
+func ScrubBucket(tx *bolt.Tx, bucket []byte) ([]*KeyError, error) {
+	b, err := prefixBucket(tx, bucket, nil)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	var bad []*KeyError
+	err = walkTree(tx, b, make([][]byte, 0, 8), func(keys [][]byte, v []byte) error {
+		_, err := decodeValue(bucket, keys, v)
+		if err == nil {
+			return nil
+		}
+		kind := ErrDecode
+		if e.Contains(err, ErrChecksum) {
+			kind = ErrChecksum
+		}
+		bad = append(bad, newKeyError(kind, bucket, len(keys)-1, keys))
+		return nil
+	})
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return bad, nil
+}
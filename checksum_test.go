@@ -0,0 +1,100 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestChecksum(t *testing.T) {
+	bucket := []byte("test_bucket")
+	defer EncryptedBucket(bucket, nil)
+
+	// Corrupts a byte in the middle of the value of keys.
+	corrupt := func(tx *bolt.Tx, keys [][]byte) error {
+		b, err := prefixBucket(tx, bucket, keys[:len(keys)-1])
+		if err != nil {
+			return e.Forward(err)
+		}
+		v := append([]byte{}, b.Get(keys[len(keys)-1])...)
+		v[len(v)/2] ^= 0x10
+		return b.Put(keys[len(keys)-1], v)
+	}
+
+	text := bytes.Repeat([]byte("checksummed "), 20)
+	for _, encrypt := range []bool{false, true} {
+		db := openTestDB(t)
+		if encrypt {
+			err := EncryptedBucket(bucket, bytes.Repeat([]byte{3}, 32))
+			if err != nil {
+				t.Fatal(e.Trace(e.Forward(err)))
+			}
+		}
+		// A value written before the checksum is on.
+		setCodec(t, db, bucket, Snappy, false)
+		err := db.Update(func(tx *bolt.Tx) error {
+			return Put(tx, bucket, [][]byte{[]byte("old"), []byte("0")}, text)
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		setCodec(t, db, bucket, Snappy, true)
+		err = db.Update(func(tx *bolt.Tx) error {
+			for i := 0; i < 5; i++ {
+				err := Put(tx, bucket, [][]byte{[]byte("new"), []byte(strconv.Itoa(i))}, text)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return corrupt(tx, [][]byte{[]byte("new"), []byte("3")})
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		err = db.View(func(tx *bolt.Tx) error {
+			for _, keys := range [][][]byte{{[]byte("old"), []byte("0")}, {[]byte("new"), []byte("2")}} {
+				v, err := Get(tx, bucket, keys)
+				if err != nil {
+					return e.Forward(err)
+				}
+				if !bytes.Equal(v, text) {
+					return e.New("wrong value at %v", Path(keys))
+				}
+			}
+			_, err := Get(tx, bucket, [][]byte{[]byte("new"), []byte("3")})
+			if !IsError(err, ErrChecksum) {
+				return e.New("corruption not detected: %v", err)
+			}
+
+			cur := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+			err = cur.Init([]byte("new"))
+			if err != nil {
+				return e.Forward(err)
+			}
+			k, _ := cur.Seek([]byte("new"), []byte("3"))
+			if k == nil || !IsError(cur.Err(), ErrChecksum) {
+				return e.New("cursor didn't detect the corruption")
+			}
+
+			bad, err := ScrubBucket(tx, bucket)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if len(bad) != 1 || bad[0].Kind() != ErrChecksum || compareKeys(bad[0].Path(), [][]byte{[]byte("new"), []byte("3")}) != 0 {
+				return e.New("wrong scrub: %v", bad)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("encrypt %v: %v", encrypt, e.Trace(e.Forward(err)))
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+// subBucket returns the intermediate bucket pointed by the value v or nil if
+// v is a leaf. Only the values named like intermediate buckets, by an UUID or
+// by Compact, are looked up, so a leaf whose value is the name of another
+// bucket, or of its own, is still a leaf.
+func subBucket(tx *bolt.Tx, v []byte) *bolt.Bucket {
+	if !isUUID(v) && !isCompactID(v) {
+		return nil
+	}
+	return tx.Bucket(v)
+}
+
+// CloneBucket makes a deep copy of the bucket src into the new bucket dst.
+// Every intermediate bucket is copied too, so changes in the clone don't
+// touch the original tree. The encrypted values are encrypted again for dst,
+// with its key if it is an EncryptedBucket. The leaves are copied as stored,
+// without the checks and the records of Put: the arity and the quota of dst
+// aren't checked, and the expiration times, the ETags and the changelog of
+// src aren't copied.
+func CloneBucket(tx *bolt.Tx, src, dst []byte) error {
+	s := tx.Bucket(src)
+	if s == nil {
+		return e.New(ErrInvBucket)
+	}
+	err := checkFrozenTree(tx, dst, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	d, err := tx.CreateBucket(dst)
+	if err != nil {
+		return e.Forward(err)
+	}
+	var n int64
+	err = cloneTree(tx, s, tx, d, &n)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = resealTree(tx, src, nil, dst, nil, d, cipherOf(dst))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = countBuckets(tx, dst, n)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// cloneTree copies the contents of s into d allocating new intermediate
+// buckets in dtx. n is incremented for each bucket created.
+func cloneTree(stx *bolt.Tx, s *bolt.Bucket, dtx *bolt.Tx, d *bolt.Bucket, n *int64) error {
+	c := s.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		sub := subBucket(stx, v)
+		if sub == nil {
+			err := d.Put(k, v)
+			if err != nil {
+				return e.Forward(err)
+			}
+			continue
+		}
+		id, err := rand.Uuid()
+		if err != nil {
+			return e.Forward(err)
+		}
+		nb, err := dtx.CreateBucket([]byte(id))
+		if err != nil {
+			return e.Forward(err)
+		}
+		*n++
+		err = cloneTree(stx, sub, dtx, nb, n)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = d.Put(k, []byte(id))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// DropBucket deletes the bucket and all intermediate buckets under it.
+func DropBucket(tx *bolt.Tx, bucket []byte) error {
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return e.New(ErrInvBucket)
+	}
+	var n int64
+	err := dropTree(tx, b, &n)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = tx.DeleteBucket(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = countBuckets(tx, bucket, -n)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = dropped(tx, bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// dropped updates the metadata of bucket after its tree was deleted, by
+// DropBucket or Wipe, and records the drop in the changelog and the
+// watchers.
+func dropped(tx *bolt.Tx, bucket []byte) error {
+	err := forgetBuckets(tx, bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = moveTTLs(tx, bucket, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = dropETags(tx, bucket, true)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = resetCounts(tx, bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = recountQuota(tx, bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = logChange(tx, EventDrop, bucket, nil, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	notify(tx, EventDrop, bucket, nil, nil)
+	return nil
+}
+
+// dropTree deletes all intermediate buckets referenced by b. b itself is
+// left untouched. n is incremented for each bucket deleted.
+func dropTree(tx *bolt.Tx, b *bolt.Bucket, n *int64) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		sub := subBucket(tx, v)
+		if sub == nil {
+			continue
+		}
+		err := dropTree(tx, sub, n)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = tx.DeleteBucket(v)
+		if err != nil {
+			return e.Forward(err)
+		}
+		*n++
+	}
+	return nil
+}
+
+// PromoteBucket replaces the tree of dst with the tree of branch, a bucket
+// made by CloneBucket. The branch bucket ceases to exist. The encrypted
+// values are encrypted again for dst, like CloneBucket, and the expiration
+// times of the leaves of branch replace the ones of dst.
+func PromoteBucket(tx *bolt.Tx, branch, dst []byte) error {
+	br := tx.Bucket(branch)
+	if br == nil {
+		return e.New(ErrInvBucket)
+	}
+	err := checkFrozenTree(tx, dst, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = checkFrozenTree(tx, branch, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if tx.Bucket(dst) != nil {
+		err = DropBucket(tx, dst)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	d, err := tx.CreateBucket(dst)
+	if err != nil {
+		return e.Forward(err)
+	}
+	// Only the first level is copied, the intermediate buckets are shared.
+	err = br.ForEach(func(k, v []byte) error {
+		return d.Put(k, v)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = resealTree(tx, branch, nil, dst, nil, d, cipherOf(dst))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = tx.DeleteBucket(branch)
+	if err != nil {
+		return e.Forward(err)
+	}
+	// The intermediate buckets move from branch to dst.
+	err = setLiveBuckets(tx, dst, liveBuckets(tx.Bucket([]byte(bucketsBucket)), branch))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = forgetBuckets(tx, branch)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = moveTTLs(tx, dst, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = moveTTLs(tx, branch, dst)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = DisableETag(tx, branch)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if etagsOf(tx, dst) != nil {
+		err = EnableETag(tx, dst)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	err = RemoveQuota(tx, branch)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = DisableCounts(tx, branch)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if countsOf(tx, dst) != nil {
+		err = EnableCounts(tx, dst)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	err = recountQuota(tx, dst)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = logChange(tx, EventDrop, dst, nil, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = logTree(tx, dst, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	notify(tx, EventDrop, dst, nil, nil)
+	return nil
+}
+
+// CopySubtree makes a deep copy of the subtree of bucket under prefix, read
+// in srcTx, into the same key path of dstBucket in dstTx. The transactions
+// may be of different databases. The leaves are written like PutBatch, so
+// they are encoded for dstBucket and charged to its quota, and the
+// expiration times of the leaves are kept. It fails with ErrDuplicateKey if
+// the key path already exists in dstBucket.
+func CopySubtree(srcTx, dstTx *bolt.Tx, bucket []byte, prefix [][]byte, dstBucket []byte) error {
+	if len(prefix) == 0 {
+		return newKeyError(ErrNoKeys, bucket, -1, nil)
+	}
+	_, err := prefixBucket(srcTx, bucket, prefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if d, err := prefixBucket(dstTx, dstBucket, prefix[:len(prefix)-1]); err == nil && d.Get(prefix[len(prefix)-1]) != nil {
+		return newKeyError(ErrDuplicateKey, dstBucket, len(prefix)-1, prefix)
+	}
+
+	var items []Item
+	var exps []time.Time
+	flush := func() error {
+		// putBatch sorts the items, they are already in key order.
+		err := putBatch(dstTx, dstBucket, items, uuidID, false)
+		if err != nil {
+			return e.Forward(err)
+		}
+		for i, exp := range exps {
+			if exp.IsZero() {
+				continue
+			}
+			err = setTTL(dstTx, dstBucket, items[i].Keys, exp)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		items, exps = items[:0], exps[:0]
+		return nil
+	}
+	err = walk(srcTx, bucket, prefix, func(keys [][]byte, v []byte) error {
+		exp, _ := expiresAt(srcTx, bucket, keys)
+		items = append(items, Item{Keys: copyKeys(keys), Data: append([]byte{}, v...)})
+		exps = append(exps, exp)
+		if len(items) < MigrateChunk {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = flush()
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
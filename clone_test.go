@@ -0,0 +1,406 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCloneBucket(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key1"), []byte("key1")}, []byte("111")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key2"), []byte("key1")}, []byte("121")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key2"), []byte("key1"), []byte("key1")}, []byte("211")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		err := CloneBucket(tx, []byte("test_bucket"), []byte("branch"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, []byte("branch"), data[0].Keys, []byte("changed"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Del(tx, []byte("branch"), data[2].Keys)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			v, err := Get(tx, d.Bucket, d.Keys)
+			if err != nil {
+				return e.Push(err, e.New("Fail to get %v", i))
+			}
+			if !bytes.Equal(v, d.Data) {
+				return e.New("original changed %v %v", i, string(v))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return PromoteBucket(tx, []byte("branch"), []byte("test_bucket"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("branch")) != nil {
+			return e.New("branch still exists")
+		}
+		v, err := Get(tx, data[0].Bucket, data[0].Keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, []byte("changed")) {
+			return e.New("not promoted %v", string(v))
+		}
+		_, err = Get(tx, data[2].Bucket, data[2].Keys)
+		if err == nil {
+			return e.New("deleted entry is still there")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return DropBucket(tx, []byte("test_bucket"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = DbEmpty(db, []string{})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestCopySubtree(t *testing.T) {
+	data := []testData{
+		{[]byte("test_posts"), [][]byte{[]byte("en"), []byte("2015"), []byte("a")}, []byte("a")},
+		{[]byte("test_posts"), [][]byte{[]byte("pt-br"), []byte("2014"), []byte("b")}, []byte("b")},
+		{[]byte("test_posts"), [][]byte{[]byte("pt-br"), []byte("2015"), []byte("c")}, []byte("c")},
+	}
+
+	var dbs [2]*bolt.DB
+	for i := range dbs {
+		filename, err := rand.FileName("blog-", "db", 10)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		dir, err := ioutil.TempDir("", "blog-")
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		dbs[i], err = bolt.Open(filepath.Join(dir, filename), 0600, nil)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	src, dst := dbs[0], dbs[1]
+
+	future := time.Now().Add(time.Hour)
+	err := src.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return PutTTL(tx, data[2].Bucket, data[2].Keys, data[2].Data, future)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	prefix := [][]byte{[]byte("pt-br")}
+	err = src.View(func(stx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			err := CopySubtree(stx, dtx, []byte("test_posts"), prefix, []byte("staging"))
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = CopySubtree(stx, dtx, []byte("test_posts"), prefix, []byte("staging"))
+			if !IsError(err, ErrDuplicateKey) {
+				return e.New("expected ErrDuplicateKey %v", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = dst.Update(func(tx *bolt.Tx) error {
+		for _, d := range data[1:] {
+			v, err := Get(tx, []byte("staging"), d.Keys)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if !bytes.Equal(v, d.Data) {
+				return e.New("wrong value %s", v)
+			}
+		}
+		_, err := Get(tx, []byte("staging"), data[0].Keys)
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("copied outside the prefix %v", err)
+		}
+		if _, ok := expiresAt(tx, []byte("staging"), data[2].Keys); !ok {
+			return e.New("TTL not copied")
+		}
+		// The copy doesn't share buckets with the source.
+		return Put(tx, []byte("staging"), data[1].Keys, []byte("changed"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = src.View(func(tx *bolt.Tx) error {
+		v, err := Get(tx, data[1].Bucket, data[1].Keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, data[1].Data) {
+			return e.New("source changed %s", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestDropBucketLeafNames(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	users := []byte("users")
+	notes := []byte("notes")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, users, [][]byte{[]byte("alice"), []byte("email")}, []byte("alice@example.com"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Leaves whose values are the names of buckets.
+		err = Put(tx, notes, [][]byte{[]byte("owner")}, users)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, users, [][]byte{[]byte("self")}, users)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = CloneBucket(tx, users, []byte("users_branch"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = DropBucket(tx, notes)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket(users) == nil {
+			return e.New("DropBucket deleted the bucket named by a leaf")
+		}
+		v, err := Get(tx, []byte("users_branch"), [][]byte{[]byte("self")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, users) {
+			return e.New("wrong clone of the leaf %q", v)
+		}
+		err = PromoteBucket(tx, []byte("users_branch"), users)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return DropBucket(tx, users)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestCopySubtreeEncoded(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	src := []byte("test_src")
+	dst := []byte("test_dst")
+	defer EncryptedBucket(src, nil)
+	defer EncryptedBucket(dst, nil)
+
+	text := bytes.Repeat([]byte("compressed "), 20)
+	setCodec(t, db, src, Snappy, false)
+	setCodec(t, db, dst, Snappy, false)
+	err := EncryptedBucket(src, bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = EncryptedBucket(dst, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, k := range []string{"a", "b", "c"} {
+			err := Put(tx, src, [][]byte{[]byte("p"), []byte(k)}, text)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		err := SetQuota(tx, dst, Quota{MaxBytes: 1 << 20})
+		if err != nil {
+			return e.Forward(err)
+		}
+		return CopySubtree(tx, tx, src, [][]byte{[]byte("p")}, dst)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The copy is read with the key of dst only.
+	err = EncryptedBucket(src, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		v, err := Get(tx, dst, [][]byte{[]byte("p"), []byte("b")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, text) {
+			return e.New("wrong value %q", v)
+		}
+		// The usage kept is the one counted from the stored values.
+		_, kept, err := QuotaUsage(tx, dst)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = SetQuota(tx, dst, Quota{MaxBytes: 1 << 20})
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, counted, err := QuotaUsage(tx, dst)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if kept != counted || kept.Entries != 3 {
+			return e.New("usage %+v, counted %+v", kept, counted)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestLeafNamesLookups(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	users := []byte("users")
+	alice := [][]byte{[]byte("alice"), []byte("email")}
+	self := [][]byte{[]byte("self")}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, users, alice, []byte("alice@example.com"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, users, self, users)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, []byte("notes"), [][]byte{[]byte("owner")}, users)
+		if err != nil {
+			return e.Forward(err)
+		}
+		// The leaf isn't counted as the tree of users.
+		return EnableCounts(tx, users)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// The path through the leaf doesn't reach the tree of users.
+		_, err := Get(tx, users, [][]byte{[]byte("self"), []byte("alice")})
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("get under a leaf: %v", err)
+		}
+		err = Del(tx, users, [][]byte{[]byte("self"), []byte("alice")})
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("del under a leaf: %v", err)
+		}
+		_, err = Get(tx, users, alice)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, users, [][]byte{[]byte("alice"), []byte("phone")}, []byte("555"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  users,
+			NumKeys: 2,
+		}
+		err = c.Init([]byte("alice"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		n, err := c.Count()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 2 {
+			return e.New("wrong count %v", n)
+		}
+		problems, err := CheckIndex(tx, []byte("notes"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(problems) != 0 {
+			return e.New("leaf named like a bucket reported: %v", problemsString(problems))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Command boltdbutils inspects and edits the trees of a database:
+//
+//	boltdbutils -db blog.db ls                    # the top buckets
+//	boltdbutils -db blog.db ls posts /en          # the tree under /en
+//	boltdbutils -db blog.db get posts /en/2015/hello
+//	boltdbutils -db blog.db put posts /en/2015/hello 'Hello!'
+//	boltdbutils -db blog.db put posts /en/2015/hello - < hello.txt
+//	boltdbutils -db blog.db del posts /en/2015/hello
+//	boltdbutils -db blog.db dump posts > posts.json
+//	boltdbutils -db blog.db load < posts.json
+//	boltdbutils -db blog.db stats posts /en
+//
+// The key paths are slash separated keys, escaped like the paths of an URL.
+// The read only commands open the database in read only mode, so they can run
+// beside the application if it doesn't hold the file lock for too long.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+const usage = `usage: boltdbutils [flags] command [arguments]
+
+commands:
+  ls [bucket [path]]      tree of the leaves under path, or the top buckets
+  get bucket path         value of the leaf
+  put bucket path value   writes the leaf, a value - is read from stdin
+  del bucket path         deletes the leaf or the subtree
+  dump bucket             JSON document with every leaf
+  load                    puts the leaves of a dump read from stdin
+  stats bucket [path]     leaves, intermediate buckets and bytes under path
+
+flags:
+`
+
+var (
+	dbPath  = flag.String("db", "bolt.db", "database file")
+	timeout = flag.Duration("timeout", time.Second, "wait for the file lock")
+	limit   = flag.Int("limit", 0, "maximum number of leaves listed by ls, 0 for all")
+	width   = flag.Int("width", 64, "maximum length of the values listed by ls, 0 for all")
+	depth   = flag.Int("depth", 0, "levels listed by ls, 0 for all")
+	keys    = flag.String("keys", "", "formats of the keys listed by ls by level after the path, string, hex or varint, comma separated")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	err := run(flag.Arg(0), flag.Args()[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "boltdbutils:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command cmd with its arguments. The values and the dumps are
+// read from stdin and the results are written to stdout.
+func run(cmd string, args []string, stdin io.Reader, stdout io.Writer) error {
+	switch cmd {
+	case "ls":
+		return view(func(tx *bolt.Tx) error {
+			if len(args) == 0 {
+				return topBuckets(tx, stdout)
+			}
+			bucket, path, err := bucketPath(args, 1, 2)
+			if err != nil {
+				return err
+			}
+			return tree(tx, stdout, bucket, path)
+		})
+	case "get":
+		bucket, path, err := bucketPath(args, 2, 2)
+		if err != nil {
+			return err
+		}
+		return view(func(tx *bolt.Tx) error {
+			v, err := boltdbutils.Get(tx, bucket, path)
+			if err != nil {
+				return err
+			}
+			_, err = stdout.Write(v)
+			return err
+		})
+	case "put":
+		if len(args) != 3 {
+			return e.New("put needs the bucket, the path and the value")
+		}
+		bucket, path, err := bucketPath(args[:2], 2, 2)
+		if err != nil {
+			return err
+		}
+		value := []byte(args[2])
+		if args[2] == "-" {
+			value, err = ioutil.ReadAll(stdin)
+			if err != nil {
+				return err
+			}
+		}
+		return update(func(tx *bolt.Tx) error {
+			return boltdbutils.Put(tx, bucket, path, value)
+		})
+	case "del":
+		bucket, path, err := bucketPath(args, 2, 2)
+		if err != nil {
+			return err
+		}
+		return update(func(tx *bolt.Tx) error {
+			return boltdbutils.Del(tx, bucket, path)
+		})
+	case "dump":
+		if len(args) != 1 {
+			return e.New("dump needs the bucket")
+		}
+		return view(func(tx *bolt.Tx) error {
+			return boltdbutils.Export(tx, []byte(args[0]), stdout)
+		})
+	case "load":
+		if len(args) != 0 {
+			return e.New("load reads the dump from stdin")
+		}
+		return update(func(tx *bolt.Tx) error {
+			bucket, err := boltdbutils.Import(tx, stdin)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "loaded %v\n", string(bucket))
+			return nil
+		})
+	case "stats":
+		bucket, path, err := bucketPath(args, 1, 2)
+		if err != nil {
+			return err
+		}
+		return view(func(tx *bolt.Tx) error {
+			s, err := boltdbutils.SubtreeStats(tx, bucket, path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "entries %v\nbuckets %v\nbytes %v\n", s.Entries, s.Buckets, s.Bytes)
+			return nil
+		})
+	}
+	return e.New("unknown command %v", cmd)
+}
+
+// bucketPath parses the bucket and the optional key path of args, that has
+// between min and max arguments.
+func bucketPath(args []string, min, max int) ([]byte, boltdbutils.Path, error) {
+	if len(args) < min || len(args) > max {
+		return nil, nil, e.New("wrong number of arguments")
+	}
+	if len(args) == 1 {
+		return []byte(args[0]), nil, nil
+	}
+	path, err := boltdbutils.ParsePath(args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(args[0]), path, nil
+}
+
+// open opens the database. The read only commands don't create it.
+func open(readOnly bool) (*bolt.DB, error) {
+	if _, err := os.Stat(*dbPath); err != nil && readOnly {
+		return nil, err
+	}
+	return bolt.Open(*dbPath, 0600, &bolt.Options{
+		Timeout:  *timeout,
+		ReadOnly: readOnly,
+	})
+}
+
+func view(fn func(tx *bolt.Tx) error) error {
+	db, err := open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func update(fn func(tx *bolt.Tx) error) error {
+	db, err := open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// topBuckets lists the buckets that aren't intermediate buckets, with the
+// metadata buckets last.
+func topBuckets(tx *bolt.Tx, w io.Writer) error {
+	var meta []string
+	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		switch {
+		case intermediate(name):
+		case strings.HasPrefix(string(name), "__"):
+			meta = append(meta, string(name))
+		default:
+			fmt.Fprintln(w, boltdbutils.KeyString(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range meta {
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
+
+// intermediate reports if name looks like the name of an intermediate
+// bucket, an UUID or a compact ID.
+func intermediate(name []byte) bool {
+	if len(name) > 0 && name[0] == 0 {
+		return true
+	}
+	if len(name) != 36 {
+		return false
+	}
+	for i, c := range name {
+		if (i == 8 || i == 13 || i == 18 || i == 23) != (c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// tree dumps the tree under prefix with the flags of ls.
+func tree(tx *bolt.Tx, w io.Writer, bucket []byte, prefix boltdbutils.Path) error {
+	opts := boltdbutils.DumpOptions{
+		Prefix:    prefix,
+		MaxDepth:  *depth,
+		MaxValue:  *width,
+		MaxLeaves: *limit,
+	}
+	if *keys != "" {
+		for i, name := range strings.Split(*keys, ",") {
+			dec, ok := keyDecoders[name]
+			if !ok {
+				return e.New("unknown key format %v", name)
+			}
+			for len(opts.KeyDecoders) <= len(prefix)+i {
+				opts.KeyDecoders = append(opts.KeyDecoders, nil)
+			}
+			opts.KeyDecoders[len(prefix)+i] = dec
+		}
+	}
+	return boltdbutils.DumpTree(tx, bucket, w, opts)
+}
+
+var keyDecoders = map[string]boltdbutils.KeyDecoder{
+	"string": boltdbutils.KeyString,
+	"hex":    boltdbutils.KeyHex,
+	"varint": boltdbutils.KeyVarint,
+}
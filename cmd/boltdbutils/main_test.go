@@ -0,0 +1,129 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+func TestBucketPath(t *testing.T) {
+	tests := []struct {
+		args     []string
+		min, max int
+		bucket   string
+		path     string
+		fail     bool
+	}{
+		{[]string{"posts"}, 1, 2, "posts", "", false},
+		{[]string{"posts", "/en/2015/a%2Fb"}, 1, 2, "posts", "/en/2015/a%2Fb", false},
+		{[]string{"posts"}, 2, 2, "", "", true},
+		{[]string{"posts", "/en", "x"}, 1, 2, "", "", true},
+		{[]string{"posts", "/en/%zz"}, 2, 2, "", "", true},
+	}
+	for i, test := range tests {
+		bucket, path, err := bucketPath(test.args, test.min, test.max)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%v: %v parsed", i, test.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", i, err)
+			continue
+		}
+		if string(bucket) != test.bucket || (len(path) > 0 && path.String() != test.path) {
+			t.Errorf("%v: wrong bucket %q or path %v", i, bucket, path)
+		}
+	}
+}
+
+func TestIntermediate(t *testing.T) {
+	for name, want := range map[string]bool{
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8": true,
+		"\x00\xb0\x1d\x01":                     true,
+		"posts":                                false,
+		"6ba7b810x9dad-11d1-80b4-00c04fd430c8": false,
+	} {
+		if got := intermediate([]byte(name)); got != want {
+			t.Errorf("%q: got %v", name, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltdbutils-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	*dbPath = filepath.Join(dir, "test.db")
+
+	// The read only commands don't create the database.
+	err = run("ls", nil, nil, ioutil.Discard)
+	if err == nil {
+		t.Fatal("ls of a missing database")
+	}
+
+	exec := func(in string, cmd string, args ...string) string {
+		var out bytes.Buffer
+		err := run(cmd, args, strings.NewReader(in), &out)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		return out.String()
+	}
+	exec("", "put", "posts", "/en/2015/hello", "Hello!")
+	exec("Bye!", "put", "posts", "/en/2015/bye", "-")
+
+	if got := exec("", "get", "posts", "/en/2015/hello"); got != "Hello!" {
+		t.Fatalf("wrong value %q", got)
+	}
+	if got := exec("", "get", "posts", "/en/2015/bye"); got != "Bye!" {
+		t.Fatalf("wrong value from stdin %q", got)
+	}
+	if got := exec("", "ls"); got != "posts\n__buckets\n" {
+		t.Fatalf("wrong top buckets %q", got)
+	}
+	if got := exec("", "ls", "posts", "/en"); !strings.Contains(got, "hello") || !strings.Contains(got, "Bye!") {
+		t.Fatalf("wrong tree %q", got)
+	}
+	if got := exec("", "stats", "posts"); !strings.HasPrefix(got, "entries 2\n") {
+		t.Fatalf("wrong stats %q", got)
+	}
+
+	dump := exec("", "dump", "posts")
+	exec("", "del", "posts", "/en/2015/hello")
+	var out bytes.Buffer
+	err = run("get", []string{"posts", "/en/2015/hello"}, nil, &out)
+	if !boltdbutils.IsError(err, boltdbutils.ErrKeyNotFound) {
+		t.Fatalf("get of a deleted leaf: %v", err)
+	}
+
+	exec("", "del", "posts", "/en")
+	exec(dump, "load")
+	if got := exec("", "get", "posts", "/en/2015/hello"); got != "Hello!" {
+		t.Fatalf("wrong value after load %q", got)
+	}
+
+	for _, args := range [][]string{
+		{"nocmd"},
+		{"put", "posts", "/en"},
+		{"dump"},
+		{"load", "posts"},
+		{"ls", "posts", "/en", "x"},
+	} {
+		err := run(args[0], args[1:], nil, ioutil.Discard)
+		if err == nil {
+			t.Fatalf("%v didn't fail", args)
+		}
+	}
+}
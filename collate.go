@@ -0,0 +1,85 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"sync"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// KeyTransform converts a key before it is written or looked up.
+type KeyTransform func(key []byte) []byte
+
+// Transforms has one KeyTransform per level. A nil KeyTransform, or a level
+// beyond the end of Transforms, leaves the key untouched.
+type Transforms []KeyTransform
+
+// Apply returns a new key path with the transforms applied.
+func (t Transforms) Apply(keys [][]byte) [][]byte {
+	if len(t) == 0 {
+		return keys
+	}
+	out := make([][]byte, len(keys))
+	for i, key := range keys {
+		if i < len(t) && t[i] != nil {
+			out[i] = t[i](key)
+			continue
+		}
+		out[i] = key
+	}
+	return out
+}
+
+func (t Transforms) Put(tx *bolt.Tx, bucket []byte, keys [][]byte, data []byte) error {
+	err := Put(tx, bucket, t.Apply(keys), data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+func (t Transforms) Get(tx *bolt.Tx, bucket []byte, keys [][]byte) ([]byte, error) {
+	buf, err := Get(tx, bucket, t.Apply(keys))
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+func (t Transforms) Del(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
+	err := Del(tx, bucket, t.Apply(keys))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Fold is a case-insensitive KeyTransform.
+func Fold(key []byte) []byte {
+	return cases.Fold().Bytes(key)
+}
+
+// Collation returns a KeyTransform that replaces the key by its collation key
+// for the language, so the keys sort in human order. The original key can't
+// be recovered from the collation key.
+func Collation(tag language.Tag, opts ...collate.Option) KeyTransform {
+	var lck sync.Mutex
+	var buf collate.Buffer
+	col := collate.New(tag, opts...)
+	return func(key []byte) []byte {
+		lck.Lock()
+		defer lck.Unlock()
+		k := col.Key(&buf, key)
+		out := make([]byte, len(k))
+		copy(out, k)
+		buf.Reset()
+		return out
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+func TestCollation(t *testing.T) {
+	titles := []string{"zebra", "Árvore", "abacaxi", "Banana"}
+	order := []string{"abacaxi", "Árvore", "Banana", "zebra"}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	tr := Transforms{Fold, Collation(language.BrazilianPortuguese, collate.IgnoreCase)}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, title := range titles {
+			err := tr.Put(tx, []byte("test_bucket"), [][]byte{[]byte("PT-BR"), []byte(title)}, []byte(title))
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		v, err := tr.Get(tx, []byte("test_bucket"), [][]byte{[]byte("pt-br"), []byte("BANANA")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "Banana" {
+			return e.New("wrong value %v", string(v))
+		}
+
+		c := &Cursor{
+			Tx:         tx,
+			Bucket:     []byte("test_bucket"),
+			NumKeys:    2,
+			Transforms: tr,
+		}
+		err = c.Init([]byte("Pt-Br"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		i := 0
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if !bytes.Equal(v, []byte(order[i])) {
+				return e.New("wrong order %v %v", i, string(v))
+			}
+			i++
+		}
+		if i != len(order) {
+			return e.New("wrong number of entries %v", i)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// idsBucket holds the sequence of the compact bucket names.
+const idsBucket = "__ids"
+
+// compactPrefix starts the compact names of the intermediate buckets. It is
+// unlikely to start a leaf value, so they aren't taken as bucket names.
+var compactPrefix = []byte{0, 0xb0, 0x1d}
+
+// sequentialID names the intermediate buckets with compactPrefix followed by
+// a sequence number, a few bytes instead of the 36 of an UUID.
+func sequentialID(tx *bolt.Tx) ([]byte, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(idsBucket))
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	id := make([]byte, len(compactPrefix)+binary.MaxVarintLen64)
+	n := copy(id, compactPrefix)
+	n += binary.PutUvarint(id[n:], seq)
+	return id[:n], nil
+}
+
+// Compact copies the trees of buckets from src to dst. The intermediate
+// buckets in dst get short sequential names instead of UUIDs and are packed,
+// without the free pages and the dead buckets of src. src is read in one
+// transaction, so it is a consistent snapshot, and dst is written in
+// transactions of MigrateChunk leaves. The buckets must not exist in dst.
+func Compact(src, dst *bolt.DB, buckets [][]byte) error {
+	err := src.View(func(stx *bolt.Tx) error {
+		for _, bucket := range buckets {
+			err := dst.Update(func(tx *bolt.Tx) error {
+				_, err := tx.CreateBucket(bucket)
+				return err
+			})
+			if err != nil {
+				return e.Push(err, e.New("fail to create the bucket %v", string(bucket)))
+			}
+			var after [][]byte
+			for done := false; !done; {
+				items, err := nextLeaves(stx, bucket, after, MigrateChunk)
+				if err != nil {
+					return e.Forward(err)
+				}
+				if len(items) < MigrateChunk {
+					done = true
+				}
+				if len(items) == 0 {
+					break
+				}
+				after = items[len(items)-1].Keys
+				err = dst.Update(func(tx *bolt.Tx) error {
+					return putBatch(tx, bucket, items, sequentialID, false)
+				})
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// isCompactID reports if name was made by sequentialID.
+func isCompactID(name []byte) bool {
+	return bytes.HasPrefix(name, compactPrefix)
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	open := func() *bolt.DB {
+		filename, err := rand.FileName("blog-", "db", 10)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		return db
+	}
+
+	src := open()
+	var data []testData
+	for y := 2010; y < 2016; y++ {
+		for m := 1; m <= 12; m++ {
+			data = append(data, testData{
+				Bucket: []byte("test_bucket"),
+				Keys:   [][]byte{[]byte(strconv.Itoa(y)), []byte(strconv.Itoa(m)), []byte("post")},
+				Data:   []byte(strconv.Itoa(y * m)),
+			})
+		}
+	}
+	data = append(data, testData{[]byte("empty"), nil, nil})
+	err = src.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			if d.Keys == nil {
+				_, err := tx.CreateBucket(d.Bucket)
+				if err != nil {
+					return e.Forward(err)
+				}
+				continue
+			}
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	old := MigrateChunk
+	MigrateChunk = 7
+	defer func() {
+		MigrateChunk = old
+	}()
+
+	dst := open()
+	err = Compact(src, dst, [][]byte{[]byte("test_bucket"), []byte("empty")})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = dst.View(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			if d.Keys == nil {
+				if tx.Bucket(d.Bucket) == nil {
+					return e.New("bucket %v not created", string(d.Bucket))
+				}
+				continue
+			}
+			v, err := Get(tx, d.Bucket, d.Keys)
+			if err != nil {
+				return e.Push(err, e.New("Fail to get %v", i))
+			}
+			if !bytes.Equal(v, d.Data) {
+				return e.New("not equal %v", i)
+			}
+		}
+		// 6 years and 72 months.
+		n := 0
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if isUUID(name) {
+				return e.New("bucket with an UUID name")
+			}
+			if isCompactID(name) {
+				n++
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 78 {
+			return e.New("wrong number of intermediate buckets %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = Compact(src, dst, [][]byte{[]byte("empty")})
+	if err == nil {
+		t.Fatal("compacted over an existing bucket")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const (
+	// The synthetic tree has wideRoots keys in the first level with
+	// wideLeaves leaves each.
+	wideRoots  = 10000
+	wideLeaves = 10
+)
+
+var wideBucket = []byte("test_wide")
+
+func wideKeys(i, j int) [][]byte {
+	return [][]byte{[]byte(fmt.Sprintf("%05d", i)), []byte(fmt.Sprintf("%02d", j))}
+}
+
+// wideDB makes a database with the synthetic tree.
+func wideDB(tb testing.TB) *bolt.DB {
+	db := openTestDB(tb)
+	for i := 0; i < wideRoots; i += 1000 {
+		items := make([]Item, 0, 1000*wideLeaves)
+		for ii := i; ii < i+1000; ii++ {
+			for j := 0; j < wideLeaves; j++ {
+				items = append(items, Item{Keys: wideKeys(ii, j), Data: []byte("data")})
+			}
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			return PutBatch(tx, wideBucket, items)
+		})
+		if err != nil {
+			tb.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	return db
+}
+
+// TestCursorComplexity guards the number of entries read by the cursor
+// operations, so a change in the traversal can't make them quadratic.
+func TestCursorComplexity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large tree")
+	}
+	db := wideDB(t)
+	defer db.Close()
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  wideBucket,
+			NumKeys: 2,
+			Debug:   true,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+
+		for _, count := range []uint64{0, 1, 999, 50000, wideRoots*wideLeaves - 1} {
+			c.ResetReport()
+			_, v := c.Skip(count)
+			if v == nil {
+				return e.New("skip %v returned nothing", count)
+			}
+			// The leaves skipped plus one entry of the first level for
+			// each subtree crossed.
+			max := count + 1 + count/wideLeaves + 2
+			if r := c.Report(); r.EntriesVisited > max {
+				return e.New("Skip(%v) read %v entries, more than %v", count, r.EntriesVisited, max)
+			}
+		}
+
+		c.ResetReport()
+		for i := 0; i < wideRoots; i += 997 {
+			k, _ := c.Seek(wideKeys(i, 5)...)
+			if k == nil {
+				return e.New("seek %v not found", i)
+			}
+		}
+		if r := c.Report(); r.EntriesVisited > uint64(2*(wideRoots/997+1)) {
+			return e.New("Seek read %v entries", r.EntriesVisited)
+		}
+
+		c.First()
+		c.ResetReport()
+		n := uint64(0)
+		for k, _ := c.Next(); k != nil; k, _ = c.Next() {
+			n++
+		}
+		if r := c.Report(); r.EntriesVisited > n+wideRoots+1 {
+			return e.New("Next read %v entries for %v leaves", r.EntriesVisited, n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func benchmarkCursor(b *testing.B, fn func(c *Cursor, i int)) {
+	db := wideDB(b)
+	defer db.Close()
+	tx, err := db.Begin(false)
+	if err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer tx.Rollback()
+	c := &Cursor{
+		Tx:      tx,
+		Bucket:  wideBucket,
+		NumKeys: 2,
+	}
+	err = c.Init()
+	if err != nil {
+		b.Fatal(e.Trace(e.Forward(err)))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(c, i)
+	}
+}
+
+func BenchmarkSkip(b *testing.B) {
+	benchmarkCursor(b, func(c *Cursor, i int) {
+		c.Skip(uint64(i%wideRoots) * wideLeaves)
+	})
+}
+
+func BenchmarkSeek(b *testing.B) {
+	benchmarkCursor(b, func(c *Cursor, i int) {
+		c.Seek(wideKeys(i%wideRoots, i%wideLeaves)...)
+	})
+}
+
+func BenchmarkNext(b *testing.B) {
+	benchmarkCursor(b, func(c *Cursor, i int) {
+		if k, _ := c.Next(); k == nil {
+			c.First()
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// Count returns the number of leaves under the prefix given to Init or
+// SeekPrefix. It reads every intermediate bucket of the subtree, or only its
+// count if the tree has counts, see EnableCounts.
+func (c *Cursor) Count() (uint64, error) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	b, err := c.prefixBucket()
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	if counts := countsOf(c.Tx, c.Bucket); counts != nil && c.ls > 0 {
+		id := c.cursors[c.ls-1].Bucket().Get(c.skip[c.ls-1])
+		return leavesOf(c.Tx, counts, id), nil
+	}
+	return countLeaves(c.Tx, b, c.NumKeys-c.ls), nil
+}
+
+// EstimatedCount returns an approximation of Count. It multiplies the number
+// of keys of each level, taken from the bucket statistics of the first and
+// last branches, so it reads only a few buckets. Bucket statistics don't see
+// the changes not yet committed by the transaction.
+func (c *Cursor) EstimatedCount() (uint64, error) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	b, err := c.prefixBucket()
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	return estimateLeaves(c.Tx, b, c.NumKeys-c.ls), nil
+}
+
+// prefixBucket returns the bucket where the prefix of the cursor ends.
+func (c *Cursor) prefixBucket() (*bolt.Bucket, error) {
+	if c.ls >= len(c.cursors) || c.cursors[c.ls] == nil {
+		return nil, newKeyError(ErrInvBucket, c.Bucket, -1, c.skip)
+	}
+	return c.cursors[c.ls].Bucket(), nil
+}
+
+// countLeaves counts the leaves of the levels levels tree under b.
+func countLeaves(tx *bolt.Tx, b *bolt.Bucket, levels int) uint64 {
+	var n uint64
+	cur := b.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if levels <= 1 {
+			n++
+			continue
+		}
+		if sub := subBucket(tx, v); sub != nil {
+			n += countLeaves(tx, sub, levels-1)
+		}
+	}
+	return n
+}
+
+func estimateLeaves(tx *bolt.Tx, b *bolt.Bucket, levels int) uint64 {
+	n := uint64(b.Stats().KeyN)
+	if levels <= 1 || n == 0 {
+		return n
+	}
+	cur := b.Cursor()
+	var sum, samples uint64
+	_, first := cur.First()
+	_, last := cur.Last()
+	for i, v := range [][]byte{first, last} {
+		if i == 1 && n == 1 {
+			break
+		}
+		if sub := subBucket(tx, v); sub != nil {
+			sum += estimateLeaves(tx, sub, levels-1)
+			samples++
+		}
+	}
+	if samples == 0 {
+		return 0
+	}
+	return n * sum / samples
+}
@@ -0,0 +1,119 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorCount(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// 3 years with 12 months with 10 posts.
+	err = db.Update(func(tx *bolt.Tx) error {
+		for y := 2013; y < 2016; y++ {
+			for m := 1; m <= 12; m++ {
+				for p := 0; p < 10; p++ {
+					keys := [][]byte{
+						[]byte(strconv.Itoa(y)),
+						EncodeInt64(int64(m)),
+						EncodeInt64(int64(p)),
+					}
+					err := Put(tx, []byte("test_bucket"), keys, []byte("post"))
+					if err != nil {
+						return e.Forward(err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_bucket"),
+			NumKeys: 3,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		n, err := c.Count()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 360 {
+			return e.New("wrong count %v", n)
+		}
+		n, err = c.EstimatedCount()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 360 {
+			return e.New("wrong estimated count %v", n)
+		}
+
+		c.SeekPrefix([]byte("2014"), EncodeInt64(3))
+		n, err = c.Count()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 10 {
+			return e.New("wrong count %v", n)
+		}
+		n, err = c.EstimatedCount()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 10 {
+			return e.New("wrong estimated count %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("no_bucket"),
+			NumKeys: 3,
+		}
+		c.Init()
+		_, err := c.Count()
+		if !IsError(err, ErrInvBucket) {
+			return e.New("expected ErrInvBucket: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// countsBucket has a bucket for each top bucket with counts, that maps the
+// names of its intermediate buckets to the number of leaves under them.
+const countsBucket = "__counts"
+
+// EnableCounts counts the leaves under each intermediate bucket of the tree of
+// bucket. The writes of the package keep the counts, so Cursor.Skip and
+// Cursor.Page jump the subtrees with fewer leaves than the ones left to skip
+// instead of reading them, and Cursor.Count pinned to a prefix reads only
+// one number. The intermediate buckets without a count, like the ones
+// written directly with bolt, are counted by reading them. Backup doesn't
+// copy the counts, call EnableCounts after Restore.
+func EnableCounts(tx *bolt.Tx, bucket []byte) error {
+	root, err := tx.CreateBucketIfNotExists([]byte(countsBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	if root.Bucket(bucket) != nil {
+		err = root.DeleteBucket(bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	counts, err := root.CreateBucket(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if b := tx.Bucket(bucket); b != nil {
+		_, err = recount(tx, counts, b)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// DisableCounts removes the counts of bucket.
+func DisableCounts(tx *bolt.Tx, bucket []byte) error {
+	root := tx.Bucket([]byte(countsBucket))
+	if root == nil || root.Bucket(bucket) == nil {
+		return nil
+	}
+	err := root.DeleteBucket(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(root) {
+		err = tx.DeleteBucket([]byte(countsBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// resetCounts removes the counts of a tree that was deleted, keeping them
+// enabled.
+func resetCounts(tx *bolt.Tx, bucket []byte) error {
+	root := tx.Bucket([]byte(countsBucket))
+	if root == nil || root.Bucket(bucket) == nil {
+		return nil
+	}
+	err := root.DeleteBucket(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	_, err = root.CreateBucket(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// forgetCounts removes the count of the intermediate bucket id, deleted by
+// GC. The subtrees deleted by Del keep their counts until then.
+func forgetCounts(tx *bolt.Tx, id []byte) error {
+	root := tx.Bucket([]byte(countsBucket))
+	if root == nil {
+		return nil
+	}
+	return root.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		err := root.Bucket(k).Delete(id)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return nil
+	})
+}
+
+// countsOf returns the counts of bucket, nil if it has none.
+func countsOf(tx *bolt.Tx, bucket []byte) *bolt.Bucket {
+	root := tx.Bucket([]byte(countsBucket))
+	if root == nil {
+		return nil
+	}
+	return root.Bucket(bucket)
+}
+
+// recount stores the counts of the intermediate buckets under b and returns
+// the number of leaves of b.
+func recount(tx *bolt.Tx, counts, b *bolt.Bucket) (uint64, error) {
+	var n uint64
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		sub := subBucket(tx, v)
+		if sub == nil {
+			n++
+			continue
+		}
+		m, err := recount(tx, counts, sub)
+		if err != nil {
+			return 0, e.Forward(err)
+		}
+		err = setCount(counts, v, m)
+		if err != nil {
+			return 0, e.Forward(err)
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// leavesOf returns the number of leaves of the value v of a key: zero if it
+// is nil, the count of the intermediate bucket or one for a leaf. The
+// intermediate buckets without a count, linked by a write that doesn't keep
+// the counts, are counted now, and the ones deleted have no leaves.
+func leavesOf(tx *bolt.Tx, counts *bolt.Bucket, v []byte) uint64 {
+	if v == nil {
+		return 0
+	}
+	if buf := counts.Get(v); len(buf) == 8 {
+		return binary.BigEndian.Uint64(buf)
+	}
+	if !isUUID(v) && !isCompactID(v) {
+		return 1
+	}
+	if sub := tx.Bucket(v); sub != nil {
+		return treeStats(tx, sub).Entries
+	}
+	return 0
+}
+
+// setCount stores the count of the intermediate bucket id. Zero isn't
+// stored, the empty buckets are deleted. id is copied, it is usually read
+// from a bucket that is changed in the same transaction.
+func setCount(counts *bolt.Bucket, id []byte, n uint64) error {
+	var err error
+	if n == 0 {
+		err = counts.Delete(id)
+	} else {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, n)
+		err = counts.Put(append([]byte{}, id...), buf)
+	}
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// addCounts adds delta to the counts of the intermediate buckets of the
+// partial key path prefix.
+func addCounts(tx *bolt.Tx, counts *bolt.Bucket, bucket []byte, prefix [][]byte, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	b := tx.Bucket(bucket)
+	for _, key := range prefix {
+		if b == nil {
+			return nil
+		}
+		id := b.Get(key)
+		if id == nil {
+			return nil
+		}
+		err := setCount(counts, id, addDelta(leavesOf(tx, counts, id), delta))
+		if err != nil {
+			return e.Forward(err)
+		}
+		b = subBucket(tx, id)
+	}
+	return nil
+}
+
+// countPut counts the write of a leaf in the key path. b is the bucket of
+// the last key, before the write.
+func countPut(tx *bolt.Tx, bucket []byte, keys [][]byte, b *bolt.Bucket) error {
+	counts := countsOf(tx, bucket)
+	if counts == nil {
+		return nil
+	}
+	old := leavesOf(tx, counts, b.Get(keys[len(keys)-1]))
+	return addCounts(tx, counts, bucket, keys[:len(keys)-1], 1-int64(old))
+}
+
+// countDel counts the delete of the key path, whose value is v, before the
+// delete.
+func countDel(tx *bolt.Tx, bucket []byte, keys [][]byte, v []byte) error {
+	counts := countsOf(tx, bucket)
+	if counts == nil {
+		return nil
+	}
+	return addCounts(tx, counts, bucket, keys[:len(keys)-1], -int64(leavesOf(tx, counts, v)))
+}
+
+// countMove counts the intermediate bucket id linked in the partial key path
+// prefix. It is called before the link, the intermediate buckets of prefix
+// without a count are counted without id.
+func countMove(tx *bolt.Tx, bucket []byte, prefix [][]byte, id []byte) error {
+	counts := countsOf(tx, bucket)
+	if counts == nil {
+		return nil
+	}
+	return addCounts(tx, counts, bucket, prefix[:len(prefix)-1], int64(leavesOf(tx, counts, id)))
+}
+
+// skipCounted is skipN for the trees with counts. It moves the cursor to the
+// first leaf and then count leaves after it, going down only into the
+// subtrees with the leaf sought.
+func (c *Cursor) skipCounted(counts *bolt.Bucket, count uint64) ([][]byte, []byte) {
+	c.pending = 0
+	if c.cursors[c.ls] == nil {
+		return nil, nil
+	}
+	for i := c.ls; i < c.NumKeys; i++ {
+		k, v := c.firstRev(i)
+		for ; k != nil; k, v = c.nextRev(i) {
+			n := uint64(1)
+			if i < c.NumKeys-1 {
+				n = leavesOf(c.Tx, counts, v)
+			}
+			if count < n {
+				break
+			}
+			count -= n
+		}
+		if k == nil {
+			return nil, nil
+		}
+		c.ks[i] = k
+		if i == c.NumKeys-1 {
+			return c.ks, v
+		}
+		c.cursors[i+1] = c.bucketCursor(v)
+	}
+	return nil, nil
+}
@@ -0,0 +1,350 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// checkCounts compares the leaves found by Skip, with the counts of bucket,
+// to the leaves found by Next.
+func checkCounts(tx *bolt.Tx, bucket []byte, prefix ...[]byte) error {
+	for _, reverse := range []bool{false, true} {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  bucket,
+			NumKeys: 3,
+			Reverse: reverse,
+		}
+		err := c.Init(prefix...)
+		if err != nil {
+			return e.Forward(err)
+		}
+		var want []string
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			want = append(want, Path(k).String())
+		}
+		var got []string
+		for i := uint64(0); ; i++ {
+			k, _ := c.Skip(i)
+			if k == nil {
+				break
+			}
+			got = append(got, Path(k).String())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return e.New("reverse %v %v: skip %v, next %v", reverse, Path(prefix), got, want)
+		}
+	}
+	return nil
+}
+
+func TestCounts(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_counts")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 6; i++ {
+			for j := 0; j <= i; j++ {
+				for k := 0; k < 3; k++ {
+					keys := [][]byte{EncodeInt64(int64(i)), EncodeInt64(int64(j)), EncodeInt64(int64(k))}
+					err := Put(tx, bucket, keys, []byte("v"))
+					if err != nil {
+						return e.Forward(err)
+					}
+				}
+			}
+		}
+		return EnableCounts(tx, bucket)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	key := func(ns ...int) [][]byte {
+		keys := make([][]byte, len(ns))
+		for i, n := range ns {
+			keys[i] = EncodeInt64(int64(n))
+		}
+		return keys
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := checkCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = checkCounts(tx, bucket, key(4)...)
+		if err != nil {
+			return e.Forward(err)
+		}
+
+		// Writes that keep the counts.
+		err = Put(tx, bucket, key(1, 0, 9), []byte("new"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, bucket, key(1, 0, 0), []byte("overwritten"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = PutBatch(tx, bucket, []Item{{Keys: key(7, 0, 0)}, {Keys: key(7, 1, 0)}, {Keys: key(2, 2, 5)}})
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Del(tx, bucket, key(3, 1, 2))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Del(tx, bucket, key(5, 2))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Del(tx, bucket, key(0))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = MoveSubtree(tx, bucket, key(4, 4), key(8, 0))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = checkCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = checkCounts(tx, bucket, key(8)...)
+		if err != nil {
+			return e.Forward(err)
+		}
+
+		// The counts are the ones counted again, and the ones of the
+		// subtrees deleted.
+		before := map[string]string{}
+		countsOf(tx, bucket).ForEach(func(k, v []byte) error {
+			before[string(k)] = string(v)
+			return nil
+		})
+		err = EnableCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		after := map[string]string{}
+		countsOf(tx, bucket).ForEach(func(k, v []byte) error {
+			after[string(k)] = string(v)
+			return nil
+		})
+		for k, v := range after {
+			if before[k] != v {
+				return e.New("counts kept differ from the recount")
+			}
+		}
+		if len(before) == len(after) {
+			return e.New("no counts of the subtrees deleted")
+		}
+
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 3}
+		err = c.Init(key(2)...)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n, err := c.Count(); err != nil || n != 10 {
+			return e.New("wrong count %v %v", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestCountsSkipCost(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_counts")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		items := make([]Item, 0, 20*20*20)
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 20; j++ {
+				for k := 0; k < 20; k++ {
+					items = append(items, Item{Keys: [][]byte{EncodeInt64(int64(i)), EncodeInt64(int64(j)), EncodeInt64(int64(k))}})
+				}
+			}
+		}
+		err := PutBatch(tx, bucket, items)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return EnableCounts(tx, bucket)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 3, Debug: true}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		k, _ := c.Skip(7999)
+		if Path(k).String() != Path([][]byte{EncodeInt64(19), EncodeInt64(19), EncodeInt64(19)}).String() {
+			return e.New("wrong leaf %v", Path(k))
+		}
+		// At most the keys of one bucket by level.
+		if r := c.Report(); r.EntriesVisited > 3*20 {
+			return e.New("Skip read %v entries", r.EntriesVisited)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestCountsGC(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_counts")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := EnableCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		for _, p := range []string{"/a/b/c", "/a/d/e", "/f/g/h"} {
+			keys, _ := ParsePath(p)
+			err = Put(tx, bucket, keys, []byte("v"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return Del(tx, bucket, [][]byte{[]byte("a")})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	n, err := GC(db, [][]byte{bucket})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 3 {
+		t.Fatal("wrong number of orphans", n)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if n := countsOf(tx, bucket).Stats().KeyN; n != 2 {
+			return e.New("wrong number of counts %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestCountsMissing(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_counts")
+	src := []byte("test_src")
+
+	key := func(s ...string) [][]byte {
+		keys := make([][]byte, len(s))
+		for i, k := range s {
+			keys[i] = []byte(k)
+		}
+		return keys
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, p := range []string{"/a/b/c", "/a/d/e", "/f/g/h"} {
+			keys, _ := ParsePath(p)
+			err := Put(tx, bucket, keys, []byte("v"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		// A leaf whose value is the name of its bucket.
+		err := Put(tx, bucket, key("s", "e", "lf"), bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = EnableCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		for _, p := range []string{"/x/1/1", "/x/1/2", "/x/2/1"} {
+			keys, _ := ParsePath(p)
+			err = Put(tx, src, keys, []byte("v"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		err = CopySubtree(tx, tx, src, key("x"), bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = CopySubtree(tx, tx, src, key("x", "1"), bucket)
+		if !IsError(err, ErrDuplicateKey) {
+			return e.New("expected ErrDuplicateKey: %v", err)
+		}
+		err = checkCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+
+		// The intermediate buckets without a count are read.
+		counts := countsOf(tx, bucket)
+		for _, keys := range [][][]byte{key("a"), key("x"), key("x", "1")} {
+			b, err := prefixBucket(tx, bucket, keys[:len(keys)-1])
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = counts.Delete(b.Get(keys[len(keys)-1]))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		err = checkCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = checkCounts(tx, bucket, key("x")...)
+		if err != nil {
+			return e.Forward(err)
+		}
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 3}
+		err = c.Init(key("x")...)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n, err := c.Count(); err != nil || n != 3 {
+			return e.New("wrong count %v %v", n, err)
+		}
+
+		// The writes store the counts read.
+		err = Put(tx, bucket, key("x", "3", "1"), []byte("v"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = MoveSubtree(tx, bucket, key("a", "d"), key("x", "4"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = checkCounts(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n := leavesOf(tx, counts, tx.Bucket(bucket).Get([]byte("x"))); n != 5 {
+			return e.New("wrong count of x %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
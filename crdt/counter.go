@@ -0,0 +1,59 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"encoding/json"
+
+	"github.com/fcavani/e"
+)
+
+// GCounter is a grow-only counter. Each node increments only its own count
+// and the merge keeps the largest count of each node.
+type GCounter struct {
+	Counts map[string]uint64
+}
+
+// Inc adds n to the count of node.
+func (g *GCounter) Inc(node string, n uint64) {
+	if g.Counts == nil {
+		g.Counts = make(map[string]uint64)
+	}
+	g.Counts[node] += n
+}
+
+// Value returns the sum of the counts of all nodes.
+func (g *GCounter) Value() uint64 {
+	var sum uint64
+	for _, n := range g.Counts {
+		sum += n
+	}
+	return sum
+}
+
+func (g *GCounter) Encode() ([]byte, error) {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return b, nil
+}
+
+func (g *GCounter) Merge(b []byte) error {
+	var o GCounter
+	err := json.Unmarshal(b, &o)
+	if err != nil {
+		return e.Forward(err)
+	}
+	for node, n := range o.Counts {
+		if g.Counts == nil {
+			g.Counts = make(map[string]uint64)
+		}
+		if n > g.Counts[node] {
+			g.Counts[node] = n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package crdt has values that two replicas can change independently and
+// merge later without conflicts: a last-writer-wins register, a grow-only
+// counter and an observed-remove set. The merge is commutative, associative
+// and idempotent, so the order and the repetition of the merges don't matter.
+package crdt
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+// Value is a value that merges with the encoded state of a replica.
+type Value interface {
+	// Encode returns the state of the value.
+	Encode() ([]byte, error)
+	// Merge decodes the state b, returned by Encode of the same type, and
+	// merges it into the value.
+	Merge(b []byte) error
+}
+
+// Put merges v with the value in the key path, if any, and stores the
+// result. v is left with the merged state. A replica applies the state
+// received from another replica with Put.
+func Put(tx *bolt.Tx, bucket []byte, keys [][]byte, v Value) error {
+	old, err := boltdbutils.Get(tx, bucket, keys)
+	if err == nil {
+		err = v.Merge(old)
+		if err != nil {
+			return e.Forward(err)
+		}
+	} else if !boltdbutils.IsError(err, boltdbutils.ErrKeyNotFound) && !boltdbutils.IsError(err, boltdbutils.ErrInvBucket) {
+		return e.Forward(err)
+	}
+	data, err := v.Encode()
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = boltdbutils.Put(tx, bucket, keys, data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Get merges the value in the key path into v.
+func Get(tx *bolt.Tx, bucket []byte, keys [][]byte, v Value) error {
+	data, err := boltdbutils.Get(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = v.Merge(data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
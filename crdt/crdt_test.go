@@ -0,0 +1,158 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+// sync merges the states of a and b in both directions.
+func sync(a, b Value) error {
+	sa, err := a.Encode()
+	if err != nil {
+		return e.Forward(err)
+	}
+	sb, err := b.Encode()
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = a.Merge(sb)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return b.Merge(sa)
+}
+
+func TestLWW(t *testing.T) {
+	now := time.Now()
+	a, b := &LWW{}, &LWW{}
+	a.Set([]byte("a"), now, "a")
+	b.Set([]byte("b"), now.Add(time.Second), "b")
+	err := sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if string(a.Value) != "b" || string(b.Value) != "b" {
+		t.Fatalf("latest write lost %s %s", a.Value, b.Value)
+	}
+	// Tie broken by the node.
+	a.Set([]byte("x"), now.Add(time.Minute), "x")
+	b.Set([]byte("y"), now.Add(time.Minute), "y")
+	err = sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if string(a.Value) != "y" || string(b.Value) != "y" {
+		t.Fatalf("replicas diverged %s %s", a.Value, b.Value)
+	}
+}
+
+func TestGCounter(t *testing.T) {
+	a, b := &GCounter{}, &GCounter{}
+	a.Inc("a", 2)
+	b.Inc("b", 3)
+	err := sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// Merging again changes nothing.
+	err = sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if a.Value() != 5 || b.Value() != 5 {
+		t.Fatal("wrong counts", a.Value(), b.Value())
+	}
+}
+
+func TestORSet(t *testing.T) {
+	a, b := &ORSet{}, &ORSet{}
+	err := a.Add("x")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// a removes x while b adds it again, the add wins.
+	a.Remove("x")
+	err = b.Add("x")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = a.Add("y")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	for _, s := range []*ORSet{a, b} {
+		elems := s.Elements()
+		if len(elems) != 2 || elems[0] != "x" || elems[1] != "y" {
+			t.Fatal("wrong elements", elems)
+		}
+	}
+	b.Remove("x")
+	err = sync(a, b)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if a.Contains("x") || b.Contains("x") {
+		t.Fatal("x not removed")
+	}
+}
+
+func TestPut(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_crdt")
+	keys := [][]byte{[]byte("views"), []byte("post")}
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Two replicas store their counts in the same key path.
+		for _, node := range []string{"a", "b", "a"} {
+			g := &GCounter{}
+			g.Inc(node, 1)
+			err := Put(tx, bucket, keys, g)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		g := &GCounter{}
+		err := Get(tx, bucket, keys, g)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if g.Value() != 2 {
+			return e.New("wrong count %v", g.Value())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
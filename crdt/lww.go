@@ -0,0 +1,60 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/fcavani/e"
+)
+
+// LWW is a last-writer-wins register. The write with the latest time wins,
+// the node breaks the ties, so the clocks of the replicas must be roughly
+// synchronized.
+type LWW struct {
+	Value []byte
+	Time  time.Time
+	Node  string
+}
+
+// Set writes v in the register if t is later than the last write.
+func (r *LWW) Set(v []byte, t time.Time, node string) {
+	r.join(&LWW{Value: v, Time: t, Node: node})
+}
+
+func (r *LWW) join(o *LWW) {
+	if o.Time.Before(r.Time) {
+		return
+	}
+	if o.Time.Equal(r.Time) {
+		c := bytes.Compare([]byte(o.Node), []byte(r.Node))
+		if c < 0 || c == 0 && bytes.Compare(o.Value, r.Value) <= 0 {
+			return
+		}
+	}
+	r.Value = append([]byte{}, o.Value...)
+	r.Time = o.Time
+	r.Node = o.Node
+}
+
+func (r *LWW) Encode() ([]byte, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return b, nil
+}
+
+func (r *LWW) Merge(b []byte) error {
+	var o LWW
+	err := json.Unmarshal(b, &o)
+	if err != nil {
+		return e.Forward(err)
+	}
+	r.join(&o)
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+// ORSet is an observed-remove set of strings. Each Add tags the element with
+// a unique id and Remove removes only the tags it has seen, so an Add
+// concurrent with a Remove wins.
+type ORSet struct {
+	// Adds has the tags of each element.
+	Adds map[string]map[string]bool
+	// Removed has the removed tags.
+	Removed map[string]bool
+}
+
+// Add adds elem to the set.
+func (s *ORSet) Add(elem string) error {
+	tag, err := rand.Uuid()
+	if err != nil {
+		return e.Forward(err)
+	}
+	if s.Adds == nil {
+		s.Adds = make(map[string]map[string]bool)
+	}
+	if s.Adds[elem] == nil {
+		s.Adds[elem] = make(map[string]bool)
+	}
+	s.Adds[elem][tag] = true
+	return nil
+}
+
+// Remove removes elem from the set.
+func (s *ORSet) Remove(elem string) {
+	for tag := range s.Adds[elem] {
+		if s.Removed == nil {
+			s.Removed = make(map[string]bool)
+		}
+		s.Removed[tag] = true
+	}
+}
+
+// Contains reports if elem is in the set.
+func (s *ORSet) Contains(elem string) bool {
+	for tag := range s.Adds[elem] {
+		if !s.Removed[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// Elements returns the elements of the set in order.
+func (s *ORSet) Elements() []string {
+	var elems []string
+	for elem := range s.Adds {
+		if s.Contains(elem) {
+			elems = append(elems, elem)
+		}
+	}
+	sort.Strings(elems)
+	return elems
+}
+
+func (s *ORSet) Encode() ([]byte, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return b, nil
+}
+
+func (s *ORSet) Merge(b []byte) error {
+	var o ORSet
+	err := json.Unmarshal(b, &o)
+	if err != nil {
+		return e.Forward(err)
+	}
+	for elem, tags := range o.Adds {
+		if s.Adds == nil {
+			s.Adds = make(map[string]map[string]bool)
+		}
+		if s.Adds[elem] == nil {
+			s.Adds[elem] = make(map[string]bool)
+		}
+		for tag := range tags {
+			s.Adds[elem][tag] = true
+		}
+	}
+	for tag := range o.Removed {
+		if s.Removed == nil {
+			s.Removed = make(map[string]bool)
+		}
+		s.Removed[tag] = true
+	}
+	return nil
+}
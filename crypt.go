@@ -0,0 +1,328 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrNoKey = "no key to decrypt the value"
+const ErrDecrypt = "fail to decrypt the value"
+
+// keyIDLen is the length of the key ID stored in the encrypted values.
+const keyIDLen = 4
+
+// aeadKey is a key of EncryptedBucket.
+type aeadKey struct {
+	id   [keyIDLen]byte
+	aead cipher.AEAD
+}
+
+var (
+	cryptLck sync.RWMutex
+	// bucketKeys has the keys that encrypt the new values of the buckets.
+	bucketKeys = map[string]*aeadKey{}
+	// keyring has the keys that decrypt, by ID. It has the keys of
+	// bucketKeys and the old keys of the Rekey in progress.
+	keyring = map[[keyIDLen]byte]*aeadKey{}
+	// rekeying counts the Rekey in progress using each key.
+	rekeying = map[[keyIDLen]byte]int{}
+)
+
+func newAEADKey(key []byte) (*aeadKey, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	k := &aeadKey{aead: aead}
+	sum := sha256.Sum256(key)
+	copy(k.id[:], sum[:])
+	return k, nil
+}
+
+// EncryptedBucket encrypts with AES-GCM, using key, the values written in
+// bucket after the call. key has 16, 24 or 32 bytes, for AES-128, AES-192 or
+// AES-256. The values are compressed by the codec before they are encrypted
+// and each one has its own random nonce. The key isn't stored, it must be set
+// each time the database is opened, before the bucket is used. A nil key stops
+// the encryption of bucket. The values encrypted with a key are read while
+// the key is set for some bucket, so the changelog is read too. The keys,
+// intermediate buckets and metadata aren't encrypted.
+//
+// Each value is bound to its bucket and key path by the additional data of
+// AES-GCM, so a value moved to other leaf fails to decrypt. CloneBucket,
+// PromoteBucket and MoveSubtree encrypt again the values they move, the
+// leaves linked by RepairIndex under LostFoundKey can't be decrypted.
+//
+// Backup copies the values encrypted. Get, the cursors, Export, ReadChanges
+// and the changes of the replication return them decrypted, and their
+// outputs hold the values in clear.
+func EncryptedBucket(bucket, key []byte) error {
+	var k *aeadKey
+	if key != nil {
+		var err error
+		k, err = newAEADKey(key)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	cryptLck.Lock()
+	defer cryptLck.Unlock()
+	if k == nil {
+		delete(bucketKeys, string(bucket))
+	} else {
+		bucketKeys[string(bucket)] = k
+	}
+	rebuildKeyring()
+	return nil
+}
+
+// rebuildKeyring updates keyring after a change of the keys. cryptLck must be
+// held.
+func rebuildKeyring() {
+	ring := make(map[[keyIDLen]byte]*aeadKey, len(bucketKeys))
+	for _, k := range bucketKeys {
+		ring[k.id] = k
+	}
+	for id := range rekeying {
+		if _, ok := ring[id]; !ok && keyring[id] != nil {
+			ring[id] = keyring[id]
+		}
+	}
+	keyring = ring
+}
+
+// cipherOf returns the key of bucket or nil if it isn't encrypted. The
+// temporary bucket of migrate uses the key of the bucket migrated.
+func cipherOf(bucket []byte) *aeadKey {
+	bucket = bytes.TrimPrefix(bucket, []byte(migratePrefix))
+	cryptLck.RLock()
+	defer cryptLck.RUnlock()
+	return bucketKeys[string(bucket)]
+}
+
+// additionalData binds an encrypted value to the leaf keys of bucket. The
+// temporary bucket of migrate uses the name of the bucket migrated.
+func additionalData(bucket []byte, keys [][]byte) []byte {
+	bucket = bytes.TrimPrefix(bucket, []byte(migratePrefix))
+	return encodeKeys(append([][]byte{bucket}, keys...)...)
+}
+
+// seal encrypts buf in a value with the header of aesgcmADID, the key ID and
+// the nonce. ad is the additional data of the leaf, see additionalData.
+func (k *aeadKey) seal(buf, ad []byte) []byte {
+	ns := k.aead.NonceSize()
+	out := make([]byte, len(valueMagic)+1+keyIDLen+ns, len(valueMagic)+1+keyIDLen+ns+len(buf)+k.aead.Overhead())
+	n := copy(out, valueMagic)
+	out[n] = aesgcmADID
+	n++
+	n += copy(out[n:], k.id[:])
+	nonce := out[n:]
+	// crypto/rand doesn't fail.
+	rand.Read(nonce)
+	return k.aead.Seal(out, nonce, buf, ad)
+}
+
+// open decrypts the payload of a value sealed by seal with the additional
+// data ad, nil for the values of aesgcmID. It returns the key of the value
+// too.
+func open(buf, ad []byte) ([]byte, *aeadKey, error) {
+	if len(buf) < keyIDLen {
+		return nil, nil, e.New(ErrDecrypt)
+	}
+	var id [keyIDLen]byte
+	copy(id[:], buf)
+	cryptLck.RLock()
+	k := keyring[id]
+	cryptLck.RUnlock()
+	if k == nil {
+		return nil, nil, e.New(ErrNoKey)
+	}
+	buf = buf[keyIDLen:]
+	ns := k.aead.NonceSize()
+	if len(buf) < ns {
+		return nil, nil, e.New(ErrDecrypt)
+	}
+	out, err := k.aead.Open(nil, buf[:ns], buf[ns:], ad)
+	if err != nil {
+		return nil, nil, e.Push(err, e.New(ErrDecrypt))
+	}
+	return out, k, nil
+}
+
+// reseal encrypts again the value v, bound to the additional data from, for
+// the additional data to. It uses k, or the key of v if k is nil. The
+// checksum is kept and the payload isn't decompressed. The values that
+// aren't encrypted are returned as they are, with false.
+func reseal(v, from, to []byte, k *aeadKey) ([]byte, bool, error) {
+	id, buf, ok := splitValue(v)
+	crc := ok && id == crcID
+	if crc {
+		err := verifyChecksum(v)
+		if err != nil {
+			return nil, false, e.Forward(err)
+		}
+		id, buf, ok = splitValue(buf[:len(buf)-crcLen])
+	}
+	if !ok || id != aesgcmID && id != aesgcmADID {
+		return v, false, nil
+	}
+	if id == aesgcmID {
+		from = nil
+	}
+	payload, old, err := open(buf, from)
+	if err != nil {
+		return nil, false, e.Forward(err)
+	}
+	if k == nil {
+		k = old
+	}
+	out := k.seal(payload, to)
+	if crc {
+		out = appendChecksum(out)
+	}
+	return out, true, nil
+}
+
+// resealTree encrypts again the leaves of the subtree b, whose key path is
+// prefix in bucket, moved from the key path from of the bucket src. k is
+// given to reseal.
+func resealTree(tx *bolt.Tx, src []byte, from [][]byte, bucket []byte, prefix [][]byte, b *bolt.Bucket, k *aeadKey) error {
+	if k == nil && bytes.Equal(additionalData(src, from), additionalData(bucket, prefix)) {
+		return nil
+	}
+	var items []Item
+	keys := make([][]byte, len(prefix), len(prefix)+8)
+	copy(keys, prefix)
+	err := walkTree(tx, b, keys, func(keys [][]byte, v []byte) error {
+		old := append(append([][]byte{}, from...), keys[len(prefix):]...)
+		out, ok, err := reseal(v, additionalData(src, old), additionalData(bucket, keys), k)
+		if err != nil {
+			return e.Push(err, e.New("%v at %v%v", ErrDecode, string(src), Path(old)))
+		}
+		if ok {
+			items = append(items, Item{Keys: copyKeys(keys), Data: out})
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	// The sizes don't change, the quota is the same.
+	for _, item := range items {
+		p, err := prefixBucket(tx, bucket, item.Keys[:len(item.Keys)-1])
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = p.Put(item.Keys[len(item.Keys)-1], item.Data)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// Rekey encrypts again the values of bucket with newKey, in transactions of
+// MigrateChunk leaves. oldKey is the key of the values written before, nil if
+// they aren't encrypted, and newKey may be nil to decrypt them. The bucket is
+// set to newKey at the start, so the values written during the Rekey use it,
+// and oldKey still reads the values not yet encrypted again until the end. If
+// Rekey fails it can be called again. The leaves are rewritten in place,
+// without ETag, changelog or notification changes.
+func Rekey(db *bolt.DB, bucket, oldKey, newKey []byte) error {
+	var old *aeadKey
+	if oldKey != nil {
+		var err error
+		old, err = newAEADKey(oldKey)
+		if err != nil {
+			return e.Forward(err)
+		}
+		cryptLck.Lock()
+		rekeying[old.id]++
+		if keyring[old.id] == nil {
+			keyring[old.id] = old
+		}
+		cryptLck.Unlock()
+		defer func() {
+			cryptLck.Lock()
+			rekeying[old.id]--
+			if rekeying[old.id] == 0 {
+				delete(rekeying, old.id)
+			}
+			rebuildKeyring()
+			cryptLck.Unlock()
+		}()
+	}
+	err := EncryptedBucket(bucket, newKey)
+	if err != nil {
+		return e.Forward(err)
+	}
+
+	var after [][]byte
+	for done := false; !done; {
+		err = db.Update(func(tx *bolt.Tx) error {
+			items, err := nextLeaves(tx, bucket, after, MigrateChunk)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if len(items) < MigrateChunk {
+				done = true
+			}
+			if len(items) == 0 {
+				return nil
+			}
+			after = items[len(items)-1].Keys
+			info, err := writeInfo(tx, bucket)
+			if err != nil {
+				return e.Forward(err)
+			}
+			for _, item := range items {
+				err = reencode(tx, bucket, &info, item)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// reencode writes the leaf item, with its value decoded, encoded again. info
+// is the description of bucket read by writeInfo.
+func reencode(tx *bolt.Tx, bucket []byte, info *BucketInfo, item Item) error {
+	buf, err := encodeValue(bucket, info, item.Keys, item.Data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = quotaPut(tx, bucket, item.Keys, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	b, err := prefixBucket(tx, bucket, item.Keys[:len(item.Keys)-1])
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(item.Keys[len(item.Keys)-1], buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
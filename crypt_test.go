@@ -0,0 +1,347 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// rawContains reports if some value of the database has secret in clear.
+func rawContains(tx *bolt.Tx, secret []byte) bool {
+	found := false
+	tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			if bytes.Contains(v, secret) {
+				found = true
+			}
+			return nil
+		})
+	})
+	return found
+}
+
+func TestEncryptedBucket(t *testing.T) {
+	bucket := []byte("test_bucket")
+	key := bytes.Repeat([]byte{7}, 32)
+	defer EncryptedBucket(bucket, nil)
+
+	if err := EncryptedBucket(bucket, []byte("short")); err == nil {
+		t.Fatal("invalid key accepted")
+	}
+
+	secret := []byte("the secret text")
+	for _, c := range []Codec{nil, Snappy} {
+		db := openTestDB(t)
+		setCodec(t, db, bucket, c, false)
+		err := EncryptedBucket(bucket, key)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			err := EnableChangelog(tx, bucket)
+			if err != nil {
+				return e.Forward(err)
+			}
+			for i := 0; i < 5; i++ {
+				err = Put(tx, bucket, [][]byte{[]byte("a"), []byte(strconv.Itoa(i))}, secret)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		err = db.View(func(tx *bolt.Tx) error {
+			if rawContains(tx, secret) {
+				return e.New("value stored in clear")
+			}
+			v, err := Get(tx, bucket, [][]byte{[]byte("a"), []byte("2")})
+			if err != nil {
+				return e.Forward(err)
+			}
+			if !bytes.Equal(v, secret) {
+				return e.New("wrong value from Get: %q", v)
+			}
+			cur := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+			err = cur.Init()
+			if err != nil {
+				return e.Forward(err)
+			}
+			for k, v := cur.First(); k != nil; k, v = cur.Next() {
+				if !bytes.Equal(v, secret) {
+					return e.New("wrong value from the cursor at %v", Path(k))
+				}
+			}
+			if err := cur.Err(); err != nil {
+				return e.Forward(err)
+			}
+			changes, err := ReadChanges(tx, 0)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if len(changes) != 5 || !bytes.Equal(changes[0].Value, secret) {
+				return e.New("wrong changelog: %v", changes)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%T: %v", c, e.Trace(e.Forward(err)))
+		}
+
+		// Without the key the values don't read.
+		EncryptedBucket(bucket, nil)
+		err = db.View(func(tx *bolt.Tx) error {
+			_, err := Get(tx, bucket, [][]byte{[]byte("a"), []byte("2")})
+			if !e.Contains(err, ErrNoKey) {
+				return e.New("read without the key: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		db.Close()
+	}
+}
+
+func TestEncryptedBucketTampered(t *testing.T) {
+	bucket := []byte("test_bucket")
+	defer EncryptedBucket(bucket, nil)
+	db := openTestDB(t)
+	defer db.Close()
+
+	err := EncryptedBucket(bucket, bytes.Repeat([]byte{1}, 16))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, [][]byte{[]byte("k")}, []byte("value"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		b := tx.Bucket(bucket)
+		v := append([]byte{}, b.Get([]byte("k"))...)
+		v[len(v)-1] ^= 1
+		return b.Put([]byte("k"), v)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		_, err := Get(tx, bucket, [][]byte{[]byte("k")})
+		if !e.Contains(err, ErrDecrypt) {
+			return e.New("tampered value read: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestEncryptedBucketKeyPath(t *testing.T) {
+	bucket := []byte("test_bucket")
+	branch := []byte("test_branch")
+	defer EncryptedBucket(bucket, nil)
+	defer EncryptedBucket(branch, nil)
+	db := openTestDB(t)
+	defer db.Close()
+
+	err := EncryptedBucket(bucket, bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = EncryptedBucket(branch, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	data := map[string]string{"/a/1": "one", "/a/2": "two", "/b/x": "bx"}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for p, v := range data {
+			keys, _ := ParsePath(p)
+			err := Put(tx, bucket, keys, []byte(v))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// A value moved to other leaf doesn't decrypt.
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := prefixBucket(tx, bucket, [][]byte{[]byte("a")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		one := append([]byte{}, b.Get([]byte("1"))...)
+		err = b.Put([]byte("2"), one)
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, err = Get(tx, bucket, [][]byte{[]byte("a"), []byte("2")})
+		if !e.Contains(err, ErrDecrypt) {
+			return e.New("value of other leaf read: %v", err)
+		}
+		return Put(tx, bucket, [][]byte{[]byte("a"), []byte("2")}, []byte("two"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The moves of the package encrypt again the values.
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := MoveSubtree(tx, bucket, [][]byte{[]byte("a")}, [][]byte{[]byte("c"), []byte("a")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = CloneBucket(tx, bucket, branch)
+		if err != nil {
+			return e.Forward(err)
+		}
+		v, err := Get(tx, branch, [][]byte{[]byte("c"), []byte("a"), []byte("1")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "one" {
+			return e.New("wrong value in the clone: %q", v)
+		}
+		// The clone is encrypted with the key of branch.
+		EncryptedBucket(bucket, nil)
+		_, err = Get(tx, branch, [][]byte{[]byte("b"), []byte("x")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = EncryptedBucket(bucket, bytes.Repeat([]byte{1}, 32))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return PromoteBucket(tx, branch, bucket)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		for p, want := range map[string]string{"/c/a/1": "one", "/c/a/2": "two", "/b/x": "bx"} {
+			keys, _ := ParsePath(p)
+			v, err := Get(tx, bucket, keys)
+			if err != nil {
+				return e.Push(err, e.New("fail to get %v", p))
+			}
+			if string(v) != want {
+				return e.New("wrong value of %v: %q", p, v)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestRekey(t *testing.T) {
+	bucket := []byte("test_bucket")
+	defer EncryptedBucket(bucket, nil)
+	old := MigrateChunk
+	MigrateChunk = 3
+	defer func() {
+		MigrateChunk = old
+	}()
+	db := openTestDB(t)
+	defer db.Close()
+
+	value := func(i int) []byte {
+		return []byte("value " + strconv.Itoa(i))
+	}
+	check := func(step string) {
+		err := db.View(func(tx *bolt.Tx) error {
+			for i := 0; i < 10; i++ {
+				v, err := Get(tx, bucket, [][]byte{[]byte("a"), []byte(strconv.Itoa(i))})
+				if err != nil {
+					return e.Forward(err)
+				}
+				if !bytes.Equal(v, value(i)) {
+					return e.New("wrong value %v: %q", i, v)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", step, e.Trace(e.Forward(err)))
+		}
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 10; i++ {
+			err := Put(tx, bucket, [][]byte{[]byte("a"), []byte(strconv.Itoa(i))}, value(i))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+	err = Rekey(db, bucket, nil, key1)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("encrypt")
+	err = Rekey(db, bucket, key1, key2)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("rekey")
+
+	// The old key is forgotten.
+	k1, err := newAEADKey(key1)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if rawContains(tx, value(3)) {
+			return e.New("value stored in clear")
+		}
+		if rawContains(tx, k1.id[:]) {
+			return e.New("value with the old key")
+		}
+		_, _, err := open(append(append([]byte{}, k1.id[:]...), make([]byte, 32)...), nil)
+		if !e.Contains(err, ErrNoKey) {
+			return e.New("old key still set: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = Rekey(db, bucket, key2, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check("decrypt")
+	err = db.View(func(tx *bolt.Tx) error {
+		if !rawContains(tx, value(3)) {
+			return e.New("value still encrypted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
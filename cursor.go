@@ -12,11 +12,16 @@ import (
 	"github.com/fcavani/e"
 )
 
+// Cursor iterates over the leaves of a tree of NumKeys levels, returning the
+// key path of each leaf. A plain bucket, written by Put with one key, is a
+// tree of one level.
 type Cursor struct {
 	Tx          *bolt.Tx
 	Bucket      []byte
 	NumKeys     int
 	Reverse     bool
+	Debug       bool
+	Transforms  Transforms
 	lck         sync.Mutex
 	err         error
 	cursors     []*bolt.Cursor
@@ -29,7 +34,29 @@ type Cursor struct {
 	// skip cursor to this keys
 	skip [][]byte
 	// len of the skip keys
-	ls int
+	ls     int
+	report Report
+	// filters has the predicates of the levels, see Filter.
+	filters []func(key []byte) bool
+	decoder Decoder
+	// scanned is the number of entries read, for the slow log.
+	scanned uint64
+	// metrics receives the operations, see Instrument.
+	metrics Metrics
+	// limit and stopAt are the options of Limit and StopAt, returned is the
+	// number of leaves returned since the cursor was positioned.
+	limit    uint64
+	returned uint64
+	stopAt   [][]byte
+	// keysOnly is the option of KeysOnly.
+	keysOnly bool
+	// pending is set by Delete to 1 or -1 if the cursor is in the leaf after
+	// or before the deleted one, in the order of the keys. The next move in
+	// that direction returns the leaf, with the value pendingV, instead of
+	// leaving it.
+	pending     int
+	pendingV    []byte
+	pendingSave int
 }
 
 func (c *Cursor) Init(keys ...[]byte) error {
@@ -42,195 +69,121 @@ func (c *Cursor) Init(keys ...[]byte) error {
 		c.cursorsSave[i] = new(bolt.Cursor)
 	}
 
+	c.report = Report{}
+
+	return c.pin(c.Transforms.Apply(keys))
+}
+
+// pin fixes the first levels of the cursor in keys. The cursor is changed
+// only if all keys are found.
+func (c *Cursor) pin(keys [][]byte) error {
 	b := c.Tx.Bucket(c.Bucket)
 	if b == nil {
-		return e.New(ErrInvBucket)
+		return newKeyError(ErrInvBucket, c.Bucket, -1, keys)
+	}
+	cursors := make([]*bolt.Cursor, c.NumKeys)
+	ks := make([][]byte, c.NumKeys)
+	cursors[0] = b.Cursor()
+	if c.Debug {
+		c.report.BucketsOpened++
 	}
-	c.cursors[0] = b.Cursor()
 
 	if len(keys) > c.NumKeys-1 {
-		return e.New("invalid number of keys")
+		return newKeyError(ErrArity, c.Bucket, -1, keys)
 	}
 
 	for i, key := range keys {
-		c.ks[i] = key
-		k, v := c.cursors[i].Seek(key)
-		if k == nil {
-			return e.New("key not found")
-		}
-		if !bytes.Equal(k, key) {
-			return e.New("key not found")
+		ks[i] = key
+		k, v := c.at(i).visit(cursors[i].Seek(key))
+		if k == nil || !bytes.Equal(k, key) {
+			return newKeyError(ErrKeyNotFound, c.Bucket, i, keys)
 		}
 		if i+1 < c.NumKeys {
-			c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+			cursors[i+1] = c.bucketCursor(v)
+			if cursors[i+1] == nil {
+				return newKeyError(ErrKeyNotFound, c.Bucket, i, keys)
+			}
 		}
 	}
+	c.cursors = cursors
+	c.ks = ks
 	c.skip = keys
 	c.ls = len(keys)
+	c.pending = 0
 	return nil
 }
 
+// SeekPrefix pins the cursor to a new prefix, like Init, and moves it to the
+// first entry under the prefix. Next and Prev don't leave the prefix. If the
+// prefix doesn't exist it returns nil and the cursor keeps the old prefix.
+func (c *Cursor) SeekPrefix(keys ...[]byte) (kout [][]byte, vout []byte) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("SeekPrefix")()
+
+	err := c.pin(c.Transforms.Apply(keys))
+	if err != nil {
+		if !IsError(err, ErrKeyNotFound) {
+			c.err = err
+		}
+		return nil, nil
+	}
+
+	c.saveState()
+	defer func() {
+		vout = c.finish(kout, vout)
+	}()
+
+	kout, vout = c.start(c.first())
+	return
+}
+
 func (c *Cursor) GetTx() *bolt.Tx {
 	return c.Tx
 }
 
 const ErrInvBucket = "invalid bucket"
 
+// Skip moves the cursor to the entry count positions after the first one,
+// in the order given by Reverse, and returns it.
 func (c *Cursor) Skip(count uint64) (k [][]byte, v []byte) {
 	c.lck.Lock()
 	defer c.lck.Unlock()
+	defer c.slow("Skip")()
 
 	c.saveState()
 	defer func() {
-		if k == nil {
-			c.restoreState()
-		}
+		v = c.finish(k, v)
 	}()
 
-	if c.Reverse {
-		k, v = c.skipBackward(count)
-		return
-	}
-	k, v = c.skipForward(count)
+	k, v = c.start(c.skipN(count))
 	return
 }
 
-func (c *Cursor) skipBackward(count uint64) ([][]byte, []byte) {
-	var i uint64
-	// Start a vector with all cursor set to start.
-	for i := 1 + c.ls; i < c.NumKeys; i++ {
-		k, v := c.cursors[i-1].Last()
-		if v == nil {
-			return nil, nil
-		}
-		c.cursors[i] = c.Tx.Bucket(v).Cursor()
-		c.ks[i-1] = k
-	}
-
-	// Pick the last cursor to start counting.
-	level := len(c.cursors) - 1
-	p := c.cursors[level]
-F:
-	for {
-		// Do counting.
-		for k, v := p.Last(); k != nil; k, v = p.Prev() {
-			if i == count {
-				c.ks[level] = k
-				return c.ks, v
-			}
-			i++
-		}
-	G:
-		for i := level - 1; i >= c.ls; i-- {
-			// Next in the prev level.
-			k, v := c.cursors[i].Prev()
-			if v == nil {
-				if i == 0 {
-					//no more entries in the last leval, stop the loop.
-					break F
-				}
-				// No more entries in this level go prev.
-				continue G
-			}
-			c.ks[i] = k
-
-			// Update all c.cursors (cursors) from i + 1 to the end.
-			for j := i + 1; j < c.NumKeys; j++ {
-				// Update c.cursors with the new cursor.
-				c.cursors[j] = c.Tx.Bucket(v).Cursor()
-				// If not  the last catch the next and iterate
-				if j < c.NumKeys-1 {
-					k, v := c.cursors[j].Prev()
-					if v == nil {
-						c.err = e.Push(e.New("during the iteration found a entry that wasn't deleted"), e.New("error iterating over the data"))
-						return nil, nil
-					}
-					c.ks[j] = k
-				}
-			}
-
-			p = c.Tx.Bucket(v).Cursor()
-
-			break
-		}
-	}
-	return nil, nil
-}
-
-func (c *Cursor) skipForward(count uint64) ([][]byte, []byte) {
-	var i uint64
-	// Start a vector with all cursor set to start.
-	for i := 1 + c.ls; i < c.NumKeys; i++ {
-		k, v := c.cursors[i-1].First()
-		if v == nil {
-			return nil, nil
+func (c *Cursor) skipN(count uint64) ([][]byte, []byte) {
+	if count > 0 && c.filters == nil {
+		if counts := countsOf(c.Tx, c.Bucket); counts != nil {
+			return c.skipCounted(counts, count)
 		}
-		c.cursors[i] = c.Tx.Bucket(v).Cursor()
-		c.ks[i-1] = k
 	}
-
-	// Pick the last cursor to start counting.
-	level := len(c.cursors) - 1
-	p := c.cursors[level]
-F:
-	for {
-		// Do counting.
-		for k, v := p.First(); k != nil; k, v = p.Next() {
-			if i == count {
-				c.ks[level] = k
-				return c.ks, v
-			}
-			i++
-		}
-	G:
-		for i := level - 1; i >= c.ls; i-- {
-			// Next in the prev level.
-			k, v := c.cursors[i].Next()
-			if v == nil {
-				if i == 0 {
-					//no more entries in the last leval, stop the loop.
-					break F
-				}
-				// No more entries in this level go prev.
-				continue G
-			}
-			c.ks[i] = k
-
-			// Update all c.cursors (cursors) from i + 1 to the end.
-			for j := i + 1; j < c.NumKeys; j++ {
-				// Update c.cursors with the new cursor.
-				c.cursors[j] = c.Tx.Bucket(v).Cursor()
-				// If not  the last catch the next and iterate
-				if j < c.NumKeys-1 {
-					k, v := c.cursors[j].Next()
-					if v == nil {
-						c.err = e.Push(e.New("during the iteration found a entry that wasn't deleted"), e.New("error iterating over the data"))
-						return nil, nil
-					}
-					c.ks[j] = k
-				}
-			}
-
-			p = c.Tx.Bucket(v).Cursor()
-
-			break
-		}
+	k, v := c.first()
+	for i := uint64(0); i < count && k != nil; i++ {
+		k, v = c.next()
 	}
-	return nil, nil
+	return k, v
 }
 
 func (c *Cursor) Seek(keys ...[]byte) (kout [][]byte, vout []byte) {
 	c.lck.Lock()
 	defer c.lck.Unlock()
+	defer c.slow("Seek")()
 
 	c.saveState()
 	defer func() {
-		if kout == nil {
-			c.restoreState()
-		}
+		vout = c.finish(kout, vout)
 	}()
 
-	kout, vout = c.seek(keys...)
+	kout, vout = c.start(c.seek(c.Transforms.Apply(keys)...))
 	return
 }
 
@@ -239,6 +192,10 @@ func (c *Cursor) seek(keys ...[]byte) ([][]byte, []byte) {
 		c.err = e.New("wrong number of keys")
 		return nil, nil
 	}
+	c.pending = 0
+	if c.cursors[c.ls] == nil {
+		return nil, nil
+	}
 
 	// TODO: check the semantics of Seek. This must return nil in some
 	// point.
@@ -249,7 +206,10 @@ func (c *Cursor) seek(keys ...[]byte) ([][]byte, []byte) {
 
 	var k, v []byte
 	for i := c.ls; i < c.NumKeys; i++ {
-		k, v = c.cursors[i].Seek(keys[i])
+		k, v = c.at(i).visit(c.cursors[i].Seek(keys[i]))
+		if k != nil && !c.wanted(i, k, v) {
+			k, v = c.filter(i, c.cursors[i].Next, c.cursors[i].Next)
+		}
 		if k == nil {
 			if i-1 < 0 {
 				return nil, nil
@@ -258,13 +218,13 @@ func (c *Cursor) seek(keys ...[]byte) ([][]byte, []byte) {
 				if len(c.skip) > 0 && bytes.Compare(keys[i], c.ks[i]) == 1 {
 					return c.next()
 				}
-				k, v = c.cursors[i].Last()
+				k, v = c.filter(i, c.cursors[i].Last, c.cursors[i].Prev)
 				if k == nil {
 					return nil, nil
 				}
 				c.ks[i] = k
 				if c.NumKeys-1 > i {
-					c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+					c.cursors[i+1] = c.bucketCursor(v)
 					return c.forwardNext(i + 1)
 				}
 				return c.ks, v
@@ -278,7 +238,7 @@ func (c *Cursor) seek(keys ...[]byte) ([][]byte, []byte) {
 		}
 		c.ks[i] = k
 		if c.NumKeys-1 > i {
-			c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+			c.cursors[i+1] = c.bucketCursor(v)
 		}
 	}
 	return c.ks, v
@@ -287,19 +247,21 @@ func (c *Cursor) seek(keys ...[]byte) ([][]byte, []byte) {
 func (c *Cursor) Next() (kout [][]byte, vout []byte) {
 	c.lck.Lock()
 	defer c.lck.Unlock()
+	defer c.slow("Next")()
 
 	c.saveState()
 	defer func() {
-		if kout == nil {
-			c.restoreState()
-		}
+		vout = c.finish(kout, vout)
 	}()
 
-	kout, vout = c.next()
+	kout, vout = c.step(c.next, c.prev)
 	return
 }
 
 func (c *Cursor) next() ([][]byte, []byte) {
+	if k, v, ok := c.takePending(1); ok {
+		return k, v
+	}
 	level := len(c.cursors) - 1
 	if c.cursors[level] == nil {
 		return c.nextBack(level)
@@ -320,20 +282,25 @@ func (c *Cursor) next() ([][]byte, []byte) {
 func (c *Cursor) Prev() (kout [][]byte, vout []byte) {
 	c.lck.Lock()
 	defer c.lck.Unlock()
+	defer c.slow("Prev")()
 
 	c.saveState()
 	defer func() {
-		if kout == nil {
-			c.restoreState()
-		}
+		vout = c.finish(kout, vout)
 	}()
 
-	kout, vout = c.prev()
+	kout, vout = c.step(c.prev, c.next)
 	return
 }
 
 func (c *Cursor) prev() ([][]byte, []byte) {
+	if k, v, ok := c.takePending(-1); ok {
+		return k, v
+	}
 	level := len(c.cursors) - 1
+	if c.cursors[level] == nil {
+		return nil, nil
+	}
 	// Find next
 	k, v := c.prevRev(level)
 	if k != nil {
@@ -350,57 +317,72 @@ func (c *Cursor) prev() ([][]byte, []byte) {
 func (c *Cursor) First() (kout [][]byte, vout []byte) {
 	c.lck.Lock()
 	defer c.lck.Unlock()
+	defer c.slow("First")()
 
 	c.saveState()
 	defer func() {
-		if kout == nil {
-			c.restoreState()
-		}
+		vout = c.finish(kout, vout)
 	}()
 
+	kout, vout = c.start(c.first())
+	return
+}
+
+func (c *Cursor) first() ([][]byte, []byte) {
+	c.pending = 0
+	if c.cursors[c.ls] == nil {
+		return nil, nil
+	}
 	var k, v []byte
 	// Start a vector with all cursors set to start.
 	for i := c.ls; i < c.NumKeys; i++ {
 		k, v = c.firstRev(i)
 		if k == nil {
-			return
+			if c.filters != nil && i > c.ls {
+				return c.backNext(i - 1)
+			}
+			return nil, nil
 		}
 		c.ks[i] = k
 		if i+1 < c.NumKeys {
-			c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+			c.cursors[i+1] = c.bucketCursor(v)
 		}
 	}
-
-	kout, vout = c.ks, v
-	return
+	return c.ks, v
 }
 
 func (c *Cursor) Last() (kout [][]byte, vout []byte) {
 	c.lck.Lock()
 	defer c.lck.Unlock()
+	defer c.slow("Last")()
 
 	c.saveState()
 	defer func() {
-		if kout == nil {
-			c.restoreState()
-		}
+		vout = c.finish(kout, vout)
 	}()
 
-	kout, vout = c.last()
+	kout, vout = c.start(c.last())
 	return
 }
 
 func (c *Cursor) last() ([][]byte, []byte) {
+	c.pending = 0
+	if c.cursors[c.ls] == nil {
+		return nil, nil
+	}
 	var k, v []byte
 	// Start a vector with all cursor set to start.
 	for i := c.ls; i < c.NumKeys; i++ {
 		k, v = c.lastRev(i)
 		if k == nil {
+			if c.filters != nil && i > c.ls {
+				return c.backPrev(i - 1)
+			}
 			return nil, nil
 		}
 		c.ks[i] = k
 		if i+1 < c.NumKeys {
-			c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+			c.cursors[i+1] = c.bucketCursor(v)
 		}
 	}
 
@@ -457,16 +439,16 @@ func (c *Cursor) Rollback() error {
 
 func (c *Cursor) firstRev(i int) ([]byte, []byte) {
 	if c.Reverse {
-		return c.cursors[i].Last()
+		return c.filter(i, c.cursors[i].Last, c.cursors[i].Prev)
 	}
-	return c.cursors[i].First()
+	return c.filter(i, c.cursors[i].First, c.cursors[i].Next)
 }
 
 func (c *Cursor) lastRev(i int) ([]byte, []byte) {
 	if c.Reverse {
-		return c.cursors[i].First()
+		return c.filter(i, c.cursors[i].First, c.cursors[i].Next)
 	}
-	return c.cursors[i].Last()
+	return c.filter(i, c.cursors[i].Last, c.cursors[i].Prev)
 }
 
 func (c *Cursor) backNext(i int) ([][]byte, []byte) {
@@ -482,7 +464,7 @@ func (c *Cursor) backNext(i int) ([][]byte, []byte) {
 	}
 	c.ks[i] = k
 	if i+1 < c.NumKeys {
-		c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+		c.cursors[i+1] = c.bucketCursor(v)
 		return c.forwardNext(i + 1)
 	}
 	return c.ks, v
@@ -501,7 +483,7 @@ func (c *Cursor) backPrev(i int) ([][]byte, []byte) {
 	}
 	c.ks[i] = k
 	if i+1 < c.NumKeys {
-		c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+		c.cursors[i+1] = c.bucketCursor(v)
 		return c.forwardPrev(i + 1)
 	}
 	return c.ks, v
@@ -513,12 +495,15 @@ func (c *Cursor) forwardNext(i int) ([][]byte, []byte) {
 		if i == c.ls {
 			return nil, nil
 		}
+		if c.filters != nil {
+			return c.backNext(i - 1)
+		}
 		//c.err = e.New("db error")
 		return nil, nil
 	}
 	c.ks[i] = k
 	if i+1 < c.NumKeys {
-		c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+		c.cursors[i+1] = c.bucketCursor(v)
 		return c.forwardNext(i + 1)
 	}
 	return c.ks, v
@@ -530,12 +515,15 @@ func (c *Cursor) forwardPrev(i int) ([][]byte, []byte) {
 		if i == c.ls {
 			return nil, nil
 		}
+		if c.filters != nil {
+			return c.backPrev(i - 1)
+		}
 		c.err = e.New("db error")
 		return nil, nil
 	}
 	c.ks[i] = k
 	if i+1 < c.NumKeys {
-		c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
+		c.cursors[i+1] = c.bucketCursor(v)
 		return c.forwardPrev(i + 1)
 	}
 	return c.ks, v
@@ -544,20 +532,20 @@ func (c *Cursor) forwardPrev(i int) ([][]byte, []byte) {
 
 func (c *Cursor) nextRev(i int) ([]byte, []byte) {
 	if c.Reverse {
-		return c.cursors[i].Prev()
+		return c.filter(i, c.cursors[i].Prev, c.cursors[i].Prev)
 	}
-	return c.cursors[i].Next()
+	return c.filter(i, c.cursors[i].Next, c.cursors[i].Next)
 }
 
 func (c *Cursor) prevRev(i int) ([]byte, []byte) {
 	if c.Reverse {
-		return c.cursors[i].Next()
+		return c.filter(i, c.cursors[i].Next, c.cursors[i].Next)
 	}
-	return c.cursors[i].Prev()
+	return c.filter(i, c.cursors[i].Prev, c.cursors[i].Prev)
 }
 
 func (c *Cursor) nextForward(i int) ([][]byte, []byte) {
-	k, v := c.cursors[i].Next()
+	k, v := c.at(i).visit(c.cursors[i].Next())
 	if k == nil {
 		return nil, nil
 	}
@@ -568,38 +556,111 @@ func (c *Cursor) nextForward(i int) ([][]byte, []byte) {
 	return c.ks, v
 }
 
+// nextBack looks for the deepest level with a cursor and moves it to the next
+// key. With one level, a plain bucket, it only moves the cursor of the bucket.
 func (c *Cursor) nextBack(i int) ([][]byte, []byte) {
-	if i-1 < 0 {
-		return nil, nil
-	}
-	if c.cursors[i] != nil {
-		k, v := c.nextRev(i)
-		if k == nil {
+	if c.cursors[i] == nil {
+		if i <= c.ls {
 			return nil, nil
 		}
-		c.ks[i] = k
-		c.cursors[i+1] = c.Tx.Bucket(v).Cursor()
-		if i < c.NumKeys-1 {
-			return c.nextForward(i + 1)
+		return c.nextBack(i - 1)
+	}
+	k, v := c.nextRev(i)
+	if k == nil {
+		return nil, nil
+	}
+	c.ks[i] = k
+	if i < c.NumKeys-1 {
+		c.cursors[i+1] = c.bucketCursor(v)
+		return c.nextForward(i + 1)
+	}
+	return c.ks, v
+}
+
+// bucketCursor opens a cursor in the intermediate bucket named v, nil if v
+// is a leaf.
+func (c *Cursor) bucketCursor(v []byte) *bolt.Cursor {
+	b := subBucket(c.Tx, v)
+	if b == nil {
+		return nil
+	}
+	if c.Debug {
+		c.report.BucketsOpened++
+	}
+	return b.Cursor()
+}
+
+// levelVisitor accounts the entries read in one level of the tree.
+type levelVisitor struct {
+	c     *Cursor
+	level int
+}
+
+func (c *Cursor) at(level int) levelVisitor {
+	return levelVisitor{c: c, level: level}
+}
+
+// visit accounts the entry k, v. It returns k and v unchanged.
+func (lv levelVisitor) visit(k, v []byte) ([]byte, []byte) {
+	c := lv.c
+	if k == nil {
+		return k, v
+	}
+	c.scanned++
+	if !c.Debug {
+		return k, v
+	}
+	c.report.EntriesVisited++
+	c.report.BytesVisited += uint64(len(k) + len(v))
+	if lv.level == c.NumKeys-1 {
+		c.report.LeavesVisited++
+	}
+	return k, v
+}
+
+// finish is called before return from the exported methods that move the
+// cursor. k is the key path that will be returned.
+func (c *Cursor) finish(k [][]byte, v []byte) []byte {
+	if k == nil {
+		c.restoreState()
+		return nil
+	}
+	if c.Debug {
+		c.report.EntriesReturned++
+	}
+	if c.keysOnly {
+		return nil
+	}
+	buf, err := decodeValue(c.Bucket, k, v)
+	if err != nil {
+		if c.err == nil {
+			c.err = e.Push(err, e.New("%v at %v%v", ErrDecode, string(c.Bucket), Path(k)))
 		}
-		return c.ks, v
+		return nil
 	}
-	return c.nextBack(i - 1)
+	return buf
 }
 
 func (c *Cursor) saveState() {
+	c.pendingSave = c.pending
 	for i := 0; i < len(c.cursors); i++ {
 		if c.cursors[i] == nil {
-			//continue
-			// Teste: troquei para break deve ser mais correto
+			// The levels without a cursor are restored without one.
+			for j := i; j < len(c.cursors); j++ {
+				c.cursorsSave[j] = nil
+			}
 			break
 		}
+		if c.cursorsSave[i] == nil {
+			c.cursorsSave[i] = new(bolt.Cursor)
+		}
 		*c.cursorsSave[i] = *c.cursors[i]
 		copy(c.ksSave[i], c.ks[i])
 	}
 }
 
 func (c *Cursor) restoreState() {
+	c.pending = c.pendingSave
 	for i := 0; i < len(c.cursors); i++ {
 		if c.cursorsSave[i] == nil || c.cursors[i] == nil {
 			c.cursors[i] = nil
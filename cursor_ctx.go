@@ -0,0 +1,84 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import "context"
+
+// ctxCheck is the number of entries walked between the checks of the context.
+const ctxCheck = 256
+
+// SkipCtx is like Skip but stops when ctx is done. Then it returns nil, the
+// cursor stays where it was and Err returns the error of the context.
+func (c *Cursor) SkipCtx(ctx context.Context, count uint64) (k [][]byte, v []byte) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("SkipCtx")()
+
+	if c.ctxDone(ctx) {
+		return nil, nil
+	}
+
+	c.saveState()
+	defer func() {
+		v = c.finish(k, v)
+	}()
+
+	k, v = c.first()
+	for i := uint64(0); i < count && k != nil; i++ {
+		if i%ctxCheck == ctxCheck-1 && c.ctxDone(ctx) {
+			return nil, nil
+		}
+		k, v = c.next()
+	}
+	return c.start(k, v)
+}
+
+// SeekCtx is like Seek but doesn't move the cursor if ctx is done.
+func (c *Cursor) SeekCtx(ctx context.Context, keys ...[]byte) (kout [][]byte, vout []byte) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("SeekCtx")()
+
+	if c.ctxDone(ctx) {
+		return nil, nil
+	}
+
+	c.saveState()
+	defer func() {
+		vout = c.finish(kout, vout)
+	}()
+
+	kout, vout = c.start(c.seek(c.Transforms.Apply(keys)...))
+	return
+}
+
+// NextCtx is like Next but doesn't move the cursor if ctx is done.
+func (c *Cursor) NextCtx(ctx context.Context) (kout [][]byte, vout []byte) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("NextCtx")()
+
+	if c.ctxDone(ctx) {
+		return nil, nil
+	}
+
+	c.saveState()
+	defer func() {
+		vout = c.finish(kout, vout)
+	}()
+
+	kout, vout = c.step(c.next, c.prev)
+	return
+}
+
+// ctxDone reports if ctx is done and keeps its error for Err.
+func (c *Cursor) ctxDone(ctx context.Context) bool {
+	err := ctx.Err()
+	if err == nil {
+		return false
+	}
+	c.err = err
+	return true
+}
@@ -0,0 +1,121 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+// countdownCtx is canceled after its Err is called n times.
+type countdownCtx struct {
+	context.Context
+	n int
+}
+
+func (c *countdownCtx) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestCursorCtx(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 100; j++ {
+				keys := [][]byte{[]byte(fmt.Sprint(i)), []byte(fmt.Sprintf("%03d", j))}
+				err := Put(tx, []byte("test_ctx"), keys, []byte(fmt.Sprint(i*100+j)))
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_ctx"),
+			NumKeys: 2,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+
+		_, v := c.SkipCtx(context.Background(), 500)
+		if string(v) != "500" {
+			return e.New("wrong skip %s", v)
+		}
+
+		// Canceled in the middle of the walk.
+		k, _ := c.SkipCtx(&countdownCtx{context.Background(), 2}, 900)
+		if k != nil {
+			return e.New("skip not canceled")
+		}
+		if err := c.Err(); err != context.Canceled {
+			return e.New("wrong error %v", err)
+		}
+		_, v = c.Next()
+		if string(v) != "501" {
+			return e.New("cursor moved by the canceled skip %s", v)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, v = c.NextCtx(ctx)
+		if string(v) != "502" {
+			return e.New("wrong next %s", v)
+		}
+		cancel()
+		k, _ = c.NextCtx(ctx)
+		if k != nil {
+			return e.New("next not canceled")
+		}
+		k, _ = c.SeekCtx(ctx, []byte("9"), []byte("000"))
+		if k != nil {
+			return e.New("seek not canceled")
+		}
+		if err := c.Err(); err != context.Canceled {
+			return e.New("wrong error %v", err)
+		}
+		_, v = c.Next()
+		if string(v) != "503" {
+			return e.New("cursor moved by the canceled calls %s", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
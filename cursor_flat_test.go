@@ -0,0 +1,177 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestCursorFlat(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_flat")
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			err := Put(tx, bucket, [][]byte{[]byte(k)}, []byte(k))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  bucket,
+			NumKeys: 1,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		scan := func() string {
+			got := ""
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				got += string(v)
+			}
+			return got
+		}
+		back := func() string {
+			got := ""
+			for k, v := c.Last(); k != nil; k, v = c.Prev() {
+				got += string(v)
+			}
+			return got
+		}
+		if got := scan(); got != "abcd" {
+			return e.New("wrong scan %v", got)
+		}
+		// At the end the cursor stays in the last entry.
+		k, _ := c.Next()
+		if k != nil {
+			return e.New("next after the end")
+		}
+		if got := back(); got != "dcba" {
+			return e.New("wrong backward scan %v", got)
+		}
+		_, v := c.Skip(2)
+		if string(v) != "c" {
+			return e.New("wrong skip %s", v)
+		}
+		k, _ = c.Skip(4)
+		if k != nil {
+			return e.New("skip after the end")
+		}
+		_, v = c.Seek([]byte("b"))
+		if string(v) != "b" {
+			return e.New("wrong seek %s", v)
+		}
+		_, v = c.Seek([]byte("bb"))
+		if string(v) != "c" {
+			return e.New("wrong seek between keys %s", v)
+		}
+		k, _ = c.Seek([]byte("z"))
+		if k != nil {
+			return e.New("seek after the end")
+		}
+		kvs, err := c.Page(1, 2)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(kvs) != 2 || string(kvs[0].Value) != "b" || string(kvs[1].Value) != "c" {
+			return e.New("wrong page %v", kvs)
+		}
+		n, err := c.Count()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 4 {
+			return e.New("wrong count %v", n)
+		}
+
+		c.Reverse = true
+		if got := scan(); got != "dcba" {
+			return e.New("wrong reverse scan %v", got)
+		}
+		if got := back(); got != "abcd" {
+			return e.New("wrong reverse backward scan %v", got)
+		}
+		_, v = c.Skip(1)
+		if string(v) != "c" {
+			return e.New("wrong reverse skip %s", v)
+		}
+		_, v = c.Seek([]byte("b"))
+		if string(v) != "b" {
+			return e.New("wrong reverse seek %s", v)
+		}
+		_, v = c.Next()
+		if string(v) != "a" {
+			return e.New("wrong reverse next after seek %s", v)
+		}
+		k, _ = c.Next()
+		if k != nil {
+			return e.New("reverse next after the end")
+		}
+		c.Reverse = false
+
+		// Prev at the first entry keeps the cursor there.
+		c.First()
+		k, _ = c.Prev()
+		if k != nil {
+			return e.New("prev before the start")
+		}
+		_, v = c.Next()
+		if string(v) != "b" {
+			return e.New("wrong next after prev %s", v)
+		}
+
+		k, v = c.SeekPrefix()
+		if string(v) != "a" || len(k) != 1 {
+			return e.New("wrong seek prefix %v %s", k, v)
+		}
+		n, err = c.EstimatedCount()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 4 {
+			return e.New("wrong estimated count %v", n)
+		}
+
+		c.Filter(0, func(key []byte) bool {
+			return key[0] != 'b' && key[0] != 'd'
+		})
+		if got := scan(); got != "ac" {
+			return e.New("wrong filtered scan %v", got)
+		}
+		if got := back(); got != "ca" {
+			return e.New("wrong filtered backward scan %v", got)
+		}
+		_, v = c.Seek([]byte("b"))
+		if string(v) != "c" {
+			return e.New("wrong filtered seek %s", v)
+		}
+		k, _ = c.Seek([]byte("d"))
+		if k != nil {
+			return e.New("filtered seek after the end")
+		}
+
+		err = c.Init([]byte("a"))
+		if !IsError(err, ErrArity) {
+			return e.New("expected arity error, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
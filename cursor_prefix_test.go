@@ -0,0 +1,121 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorSeekPrefix(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("en"), []byte("2014"), []byte("a")}, []byte("en14a")},
+		{[]byte("test_bucket"), [][]byte{[]byte("en"), []byte("2015"), []byte("b")}, []byte("en15b")},
+		{[]byte("test_bucket"), [][]byte{[]byte("pt-br"), []byte("2014"), []byte("c")}, []byte("pt14c")},
+		{[]byte("test_bucket"), [][]byte{[]byte("pt-br"), []byte("2015"), []byte("d")}, []byte("pt15d")},
+		{[]byte("test_bucket"), [][]byte{[]byte("pt-br"), []byte("2015"), []byte("e")}, []byte("pt15e")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	scan := func(c *Cursor, prefix ...[]byte) []string {
+		var got []string
+		for k, v := c.SeekPrefix(prefix...); k != nil; k, v = c.Next() {
+			got = append(got, string(v))
+		}
+		return got
+	}
+	equal := func(a []string, b ...string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_bucket"),
+			NumKeys: 3,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		if got := scan(c, []byte("pt-br")); !equal(got, "pt14c", "pt15d", "pt15e") {
+			return e.New("wrong entries %v", got)
+		}
+		if got := scan(c, []byte("en"), []byte("2015")); !equal(got, "en15b") {
+			return e.New("wrong entries %v", got)
+		}
+		if got := scan(c, []byte("pt-br"), []byte("2015")); !equal(got, "pt15d", "pt15e") {
+			return e.New("wrong entries %v", got)
+		}
+		if got := scan(c, []byte("fr")); len(got) != 0 {
+			return e.New("entries for a missing prefix %v", got)
+		}
+		if err := c.Err(); err != nil {
+			return e.Forward(err)
+		}
+		if got := scan(c); !equal(got, "en14a", "en15b", "pt14c", "pt15d", "pt15e") {
+			return e.New("wrong entries %v", got)
+		}
+
+		c.Reverse = true
+		k, v := c.SeekPrefix([]byte("en"))
+		if k == nil || !bytes.Equal(v, []byte("en15b")) {
+			return e.New("wrong reverse entry %v", string(v))
+		}
+		k, v = c.Next()
+		if k == nil || !bytes.Equal(v, []byte("en14a")) {
+			return e.New("wrong reverse entry %v", string(v))
+		}
+		k, _ = c.Next()
+		if k != nil {
+			return e.New("left the prefix")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
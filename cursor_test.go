@@ -6,7 +6,7 @@ package boltdbutils
 
 import (
 	"bytes"
-	"encoding/binary"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
@@ -402,16 +402,10 @@ func TestCursorNext(t *testing.T) {
 	}
 }
 
-func EncInt(x int) []byte {
-	buf := make([]byte, binary.MaxVarintLen64)
-	n := binary.PutVarint(buf, int64(x))
-	return buf[:n]
-}
-
 func TestCursorBigIndexNextPrev(t *testing.T) {
 	data := []testData{
-		{[]byte("test_bucket"), [][]byte{[]byte{'0'}, []byte("pt-br"), EncInt(2015), EncInt(1), EncInt(4), EncInt(14), EncInt(58), EncInt(59), []byte("Log")}, []byte("11")},
-		{[]byte("test_bucket"), [][]byte{[]byte{'1'}, []byte("pt-br"), EncInt(2015), EncInt(12), EncInt(23), EncInt(17), EncInt(25), EncInt(59), []byte("Sem assunto e sem nome")}, []byte("12")},
+		{[]byte("test_bucket"), [][]byte{[]byte{'0'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(1), EncodeInt64(4), EncodeInt64(14), EncodeInt64(58), EncodeInt64(59), []byte("Log")}, []byte("11")},
+		{[]byte("test_bucket"), [][]byte{[]byte{'1'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(12), EncodeInt64(23), EncodeInt64(17), EncodeInt64(25), EncodeInt64(59), []byte("Sem assunto e sem nome")}, []byte("12")},
 	}
 
 	filename, err := rand.FileName("blog-", "db", 10)
@@ -491,8 +485,8 @@ func TestCursorBigIndexNextPrev(t *testing.T) {
 
 func TestCursorBigIndexSeek(t *testing.T) {
 	data := []testData{
-		{[]byte("test_bucket"), [][]byte{[]byte{'0'}, []byte("pt-br"), EncInt(2015), EncInt(1), EncInt(4), EncInt(14), EncInt(58), EncInt(59), []byte("Log")}, []byte("11")},
-		{[]byte("test_bucket"), [][]byte{[]byte{'1'}, []byte("pt-br"), EncInt(2015), EncInt(12), EncInt(23), EncInt(17), EncInt(25), EncInt(59), []byte("Sem assunto e sem nome")}, []byte("12")},
+		{[]byte("test_bucket"), [][]byte{[]byte{'0'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(1), EncodeInt64(4), EncodeInt64(14), EncodeInt64(58), EncodeInt64(59), []byte("Log")}, []byte("11")},
+		{[]byte("test_bucket"), [][]byte{[]byte{'1'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(12), EncodeInt64(23), EncodeInt64(17), EncodeInt64(25), EncodeInt64(59), []byte("Sem assunto e sem nome")}, []byte("12")},
 	}
 
 	filename, err := rand.FileName("blog-", "db", 10)
@@ -533,7 +527,7 @@ func TestCursorBigIndexSeek(t *testing.T) {
 		if err != nil {
 			return e.Forward(err)
 		}
-		_, v := c.Seek([]byte{'1'}, []byte("pt-br"), EncInt(2015), EncInt(12), EncInt(23), EncInt(17), EncInt(25), EncInt(59), []byte("Sem assunto e sem nome"))
+		_, v := c.Seek([]byte{'1'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(12), EncodeInt64(23), EncodeInt64(17), EncodeInt64(25), EncodeInt64(59), []byte("Sem assunto e sem nome"))
 		if !bytes.Equal(v, []byte("12")) {
 			t.Fatal("seek fail", string(v))
 		}
@@ -778,8 +772,8 @@ func TestCursorSkipKeys(t *testing.T) {
 
 func TestCursorReinsert(t *testing.T) {
 	data := []testData{
-		{[]byte("test_bucket"), [][]byte{[]byte{'0'}, []byte("pt-br"), EncInt(2015), EncInt(1), EncInt(4), EncInt(14), EncInt(58), EncInt(59), []byte("Log")}, []byte("11")},
-		{[]byte("test_bucket"), [][]byte{[]byte{'1'}, []byte("pt-br"), EncInt(2015), EncInt(12), EncInt(23), EncInt(17), EncInt(25), EncInt(59), []byte("Sem assunto e sem nome")}, []byte("12")},
+		{[]byte("test_bucket"), [][]byte{[]byte{'0'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(1), EncodeInt64(4), EncodeInt64(14), EncodeInt64(58), EncodeInt64(59), []byte("Log")}, []byte("11")},
+		{[]byte("test_bucket"), [][]byte{[]byte{'1'}, []byte("pt-br"), EncodeInt64(2015), EncodeInt64(12), EncodeInt64(23), EncodeInt64(17), EncodeInt64(25), EncodeInt64(59), []byte("Sem assunto e sem nome")}, []byte("12")},
 	}
 	filename, err := rand.FileName("blog-", "db", 10)
 	if err != nil {
@@ -850,3 +844,66 @@ func TestCursorReinsert(t *testing.T) {
 		t.Fatal(e.Trace(e.Forward(err)))
 	}
 }
+
+func TestCursorMixedDepth(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_bucket")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, keys := range [][][]byte{
+			{[]byte("a"), []byte("1")},
+			{[]byte("b")},
+			{[]byte("c"), []byte("1")},
+			{[]byte("d")},
+		} {
+			err := Put(tx, bucket, keys, []byte("v"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, reverse := range []bool{false, true} {
+			c := &Cursor{
+				Tx:      tx,
+				Bucket:  bucket,
+				NumKeys: 2,
+				Reverse: reverse,
+			}
+			err := c.Init()
+			if err != nil {
+				return e.Forward(err)
+			}
+			var got []string
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				got = append(got, Path(k).String())
+			}
+			want, last := "[/a/1 /c/1]", "/c/1"
+			if reverse {
+				want, last = "[/c/1 /a/1]", "/a/1"
+			}
+			if fmt.Sprint(got) != want {
+				return e.New("reverse %v: wrong leaves %v", reverse, got)
+			}
+			if k, _ := c.Last(); k == nil || Path(k).String() != last {
+				return e.New("reverse %v: wrong last %v", reverse, k)
+			}
+			if k, _ := c.SeekPrefix([]byte("b")); k != nil {
+				return e.New("prefix through a leaf %v", Path(k))
+			}
+			if err := c.Err(); err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import "github.com/fcavani/e"
+
+const ErrNoPosition = "cursor isn't in a leaf"
+
+// Put replaces the value of the leaf under the cursor, like the package
+// function Put. The cursor must be in a writable transaction and stays in the
+// leaf.
+func (c *Cursor) Put(value []byte) error {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("Put")()
+
+	keys, err := c.position()
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = Put(c.Tx, c.Bucket, keys, value)
+	if err != nil {
+		return e.Forward(err)
+	}
+	// The writes invalidate the bolt cursors.
+	err = c.pin(c.skip)
+	if err != nil {
+		return e.Forward(err)
+	}
+	k, _ := c.seek(keys...)
+	if k == nil {
+		return newKeyError(ErrKeyNotFound, c.Bucket, len(keys)-1, keys)
+	}
+	return nil
+}
+
+// Delete removes the leaf under the cursor and the intermediate buckets left
+// empty, like Del. The cursor must be in a writable transaction. After it
+// Next and Prev return the leaves around the deleted one, and Put and Delete
+// fail until the cursor is moved. If the cursor was pinned by Init or
+// SeekPrefix and the prefix had only this leaf, the prefix is deleted too and
+// the cursor returns nil until pinned again.
+func (c *Cursor) Delete() error {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("Delete")()
+
+	keys, err := c.position()
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = Del(c.Tx, c.Bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = c.pin(c.skip)
+	if IsError(err, ErrKeyNotFound) {
+		for i := c.ls; i < c.NumKeys; i++ {
+			c.cursors[i] = nil
+			c.ks[i] = nil
+		}
+		return nil
+	} else if err != nil {
+		return e.Forward(err)
+	}
+
+	k, v := c.seek(copyKeys(keys)...)
+	if k == nil {
+		// The deleted leaf was the last one, in the order of the keys.
+		if c.Reverse {
+			k, v = c.first()
+		} else {
+			k, v = c.last()
+		}
+	}
+	if k == nil {
+		return nil
+	}
+	c.pending = 1
+	if compareKeys(k, keys) < 0 {
+		c.pending = -1
+	}
+	c.pendingV = v
+	return nil
+}
+
+// position returns a copy of the key path of the leaf under the cursor.
+func (c *Cursor) position() ([][]byte, error) {
+	if c.pending != 0 || c.cursors[c.NumKeys-1] == nil || c.ks[c.NumKeys-1] == nil {
+		return nil, newKeyError(ErrNoPosition, c.Bucket, -1, c.skip)
+	}
+	return copyKeys(c.ks), nil
+}
+
+// takePending returns the leaf left by Delete if the cursor moves toward it.
+// dir is 1 for Next and -1 for Prev.
+func (c *Cursor) takePending(dir int) ([][]byte, []byte, bool) {
+	if c.pending == 0 {
+		return nil, nil, false
+	}
+	if c.Reverse {
+		dir = -dir
+	}
+	pending := c.pending
+	c.pending = 0
+	if pending != dir {
+		return nil, nil, false
+	}
+	return c.ks, c.pendingV, true
+}
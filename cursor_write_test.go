@@ -0,0 +1,206 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestCursorWrite(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_write")
+	fill := func(tx *bolt.Tx) error {
+		for _, path := range []string{"a/1", "a/2", "b/1", "b/2", "c/1"} {
+			keys := strings.Split(path, "/")
+			err := Put(tx, bucket, [][]byte{[]byte(keys[0]), []byte(keys[1])}, []byte(path))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	}
+	scan := func(tx *bolt.Tx) string {
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+		if c.Init() != nil {
+			return ""
+		}
+		var got []string
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			got = append(got, string(v))
+		}
+		return strings.Join(got, " ")
+	}
+	newCursor := func(tx *bolt.Tx, reverse bool, keys ...[]byte) *Cursor {
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2, Reverse: reverse}
+		err := c.Init(keys...)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		return c
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := fill(tx)
+		if err != nil {
+			return e.Forward(err)
+		}
+
+		// Delete every other leaf while iterating.
+		c := newCursor(tx, false)
+		var seen []string
+		i := 0
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			seen = append(seen, string(v))
+			if i%2 == 0 {
+				err = c.Delete()
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			i++
+		}
+		if got := strings.Join(seen, " "); got != "a/1 a/2 b/1 b/2 c/1" {
+			return e.New("wrong iteration %v", got)
+		}
+		if got := scan(tx); got != "a/2 b/2" {
+			return e.New("wrong leaves %v", got)
+		}
+		// The intermediate bucket of c was deleted.
+		if _, err := Get(tx, bucket, [][]byte{[]byte("c"), []byte("1")}); !IsError(err, ErrKeyNotFound) {
+			return e.New("expected key not found, got %v", err)
+		}
+
+		// Put and Delete need a leaf.
+		k, _ := c.First()
+		if k == nil {
+			return e.New("no first")
+		}
+		err = c.Delete()
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = c.Delete()
+		if !IsError(err, ErrNoPosition) {
+			return e.New("expected no position, got %v", err)
+		}
+		err = c.Put([]byte("x"))
+		if !IsError(err, ErrNoPosition) {
+			return e.New("expected no position, got %v", err)
+		}
+		// Prev from the leaf after the deleted first leaf finds nothing.
+		k, _ = c.Prev()
+		if k != nil {
+			return e.New("prev before the first leaf")
+		}
+
+		// Put replaces the value and the cursor goes on.
+		err = fill(tx)
+		if err != nil {
+			return e.Forward(err)
+		}
+		c = newCursor(tx, false)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			err = c.Put(append([]byte(strings.ToUpper(string(v))), '!'))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		if got := scan(tx); got != "A/1! A/2! B/1! B/2! C/1!" {
+			return e.New("wrong leaves after put %v", got)
+		}
+
+		// Delete then Prev returns the leaf before the deleted one.
+		_, v := c.Seek([]byte("b"), []byte("1"))
+		if string(v) != "B/1!" {
+			return e.New("wrong seek %s", v)
+		}
+		err = c.Delete()
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, v = c.Prev()
+		if string(v) != "A/2!" {
+			return e.New("wrong prev after delete %s", v)
+		}
+		// Deleting the last leaf leaves the cursor before the end.
+		c.Last()
+		err = c.Delete()
+		if err != nil {
+			return e.Forward(err)
+		}
+		k, _ = c.Next()
+		if k != nil {
+			return e.New("next after the last leaf")
+		}
+		_, v = c.Prev()
+		if string(v) != "B/2!" {
+			return e.New("wrong prev after deleting the last %s", v)
+		}
+
+		// Reverse.
+		c = newCursor(tx, true)
+		seen = nil
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			seen = append(seen, string(v))
+			err = c.Delete()
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		if got := strings.Join(seen, " "); got != "B/2! A/2! A/1!" {
+			return e.New("wrong reverse iteration %v", got)
+		}
+		if got := scan(tx); got != "" {
+			return e.New("leaves left %v", got)
+		}
+
+		// Deleting the last leaf of the prefix deletes the prefix.
+		err = fill(tx)
+		if err != nil {
+			return e.Forward(err)
+		}
+		c = newCursor(tx, false, []byte("b"))
+		seen = nil
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			seen = append(seen, string(v))
+			err = c.Delete()
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		if got := strings.Join(seen, " "); got != "b/1 b/2" {
+			return e.New("wrong prefix iteration %v", got)
+		}
+		k, _ = c.First()
+		if k != nil {
+			return e.New("first in a deleted prefix")
+		}
+		k, _ = c.Prev()
+		if k != nil {
+			return e.New("prev in a deleted prefix")
+		}
+		if got := scan(tx); got != "a/1 a/2 c/1" {
+			return e.New("wrong leaves after prefix %v", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	orphans, err := Verify(db, [][]byte{bucket})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(orphans) != 0 {
+		t.Fatal("orphan buckets", len(orphans))
+	}
+}
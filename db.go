@@ -0,0 +1,225 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// DB wraps a bolt database and runs each call of Put, Get and Del in its own
+// transaction. Transactions that fail to begin because the database isn't
+// open are retried.
+type DB struct {
+	*bolt.DB
+	// Retries is the number of times a transaction is retried when the
+	// database isn't open.
+	Retries int
+	// RetryDelay is the time between the retries.
+	RetryDelay time.Duration
+
+	lck      sync.RWMutex
+	registry map[string]BucketInfo
+	metrics  Metrics
+}
+
+// NewDB wraps db and reads the registry of buckets. If the registry can't be
+// read it starts empty, see LoadRegistry.
+func NewDB(db *bolt.DB) *DB {
+	d, _ := newDB(db)
+	return d
+}
+
+func newDB(db *bolt.DB) (*DB, error) {
+	d := &DB{
+		DB:         db,
+		Retries:    3,
+		RetryDelay: 100 * time.Millisecond,
+	}
+	return d, d.LoadRegistry()
+}
+
+// Open opens the bolt database in path and wraps it. The format of the
+// database is upgraded with UpgradeFormat, or only checked with CheckFormat if
+// it is opened read-only.
+func Open(path string, mode os.FileMode, options *bolt.Options) (*DB, error) {
+	db, err := bolt.Open(path, mode, options)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	if options != nil && options.ReadOnly {
+		err = db.View(CheckFormat)
+	} else {
+		err = db.Update(UpgradeFormat)
+	}
+	if err != nil {
+		db.Close()
+		return nil, e.Forward(err)
+	}
+	d, err := newDB(db)
+	if err != nil {
+		db.Close()
+		return nil, e.Forward(err)
+	}
+	return d, nil
+}
+
+// LoadRegistry reads again the descriptions of the buckets recorded by
+// Register.
+func (d *DB) LoadRegistry() error {
+	var m map[string]BucketInfo
+	err := d.View(func(tx *bolt.Tx) error {
+		var err error
+		m, err = Registered(tx)
+		return err
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	d.lck.Lock()
+	d.registry = m
+	d.lck.Unlock()
+	return nil
+}
+
+// Register records the description of bucket in the database and in the
+// wrapper.
+func (d *DB) Register(bucket []byte, info BucketInfo) error {
+	err := d.Update(func(tx *bolt.Tx) error {
+		return Register(tx, bucket, info)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	d.lck.Lock()
+	if d.registry == nil {
+		d.registry = make(map[string]BucketInfo)
+	}
+	d.registry[string(bucket)] = info
+	d.lck.Unlock()
+	return nil
+}
+
+// Info returns the description of bucket read with the registry.
+func (d *DB) Info(bucket []byte) (BucketInfo, bool) {
+	d.lck.RLock()
+	defer d.lck.RUnlock()
+	info, ok := d.registry[string(bucket)]
+	return info, ok
+}
+
+func (d *DB) retry(fn func() error) error {
+	var err error
+	for i := 0; i <= d.Retries; i++ {
+		if i > 0 {
+			time.Sleep(d.RetryDelay)
+		}
+		err = fn()
+		if err != bolt.ErrDatabaseNotOpen {
+			return err
+		}
+	}
+	return err
+}
+
+// Update runs fn in a writable transaction, like bolt.DB.Update.
+func (d *DB) Update(fn func(tx *bolt.Tx) error) error {
+	return d.retry(func() error {
+		return d.DB.Update(fn)
+	})
+}
+
+// View runs fn in a read-only transaction, like bolt.DB.View.
+func (d *DB) View(fn func(tx *bolt.Tx) error) error {
+	return d.retry(func() error {
+		return d.DB.View(fn)
+	})
+}
+
+// Put stores data in the key path.
+func (d *DB) Put(bucket []byte, keys [][]byte, data []byte) error {
+	start := time.Now()
+	err := d.Update(func(tx *bolt.Tx) error {
+		return Put(tx, bucket, keys, data)
+	})
+	d.observe("Put", bucket, keys, start, err)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Get returns a copy of the data in the key path.
+func (d *DB) Get(bucket []byte, keys [][]byte) ([]byte, error) {
+	start := time.Now()
+	var data []byte
+	err := d.View(func(tx *bolt.Tx) error {
+		buf, err := Get(tx, bucket, keys)
+		if err != nil {
+			return err
+		}
+		data = append([]byte{}, buf...)
+		return nil
+	})
+	d.observe("Get", bucket, keys, start, err)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return data, nil
+}
+
+// Del removes the key path.
+func (d *DB) Del(bucket []byte, keys [][]byte) error {
+	start := time.Now()
+	err := d.Update(func(tx *bolt.Tx) error {
+		return Del(tx, bucket, keys)
+	})
+	d.observe("Del", bucket, keys, start, err)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// NewCursor begins a transaction and returns a cursor over it pinned to the
+// keys, like Cursor.Init. A zero numKeys is taken from the registry. The
+// transaction is writable if writable is true.
+// The caller must end the transaction with Cursor.Commit or
+// Cursor.Rollback. The slices returned by the cursor are valid only until
+// then.
+func (d *DB) NewCursor(bucket []byte, numKeys int, writable bool, keys ...[]byte) (*Cursor, error) {
+	if numKeys == 0 {
+		info, ok := d.Info(bucket)
+		if !ok {
+			return nil, newKeyError(ErrNotRegistered, bucket, -1, keys)
+		}
+		numKeys = info.NumKeys
+	}
+	var tx *bolt.Tx
+	err := d.retry(func() error {
+		var err error
+		tx, err = d.DB.Begin(writable)
+		return err
+	})
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	c := &Cursor{
+		Tx:      tx,
+		Bucket:  bucket,
+		NumKeys: numKeys,
+		metrics: d.metrics,
+	}
+	err = c.Init(keys...)
+	if err != nil {
+		tx.Rollback()
+		return nil, e.Forward(err)
+	}
+	return c, nil
+}
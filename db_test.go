@@ -0,0 +1,200 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestDB(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	for i, d := range data {
+		err = db.Put(d.Bucket, d.Keys, d.Data)
+		if err != nil {
+			t.Fatal(i, e.Trace(e.Forward(err)))
+		}
+	}
+	for i, d := range data {
+		v, err := db.Get(d.Bucket, d.Keys)
+		if err != nil {
+			t.Fatal(i, e.Trace(e.Forward(err)))
+		}
+		if !bytes.Equal(v, d.Data) {
+			t.Fatal("not equal", i, string(v))
+		}
+	}
+
+	c, err := db.NewCursor([]byte("test_bucket"), 3, false, []byte("2014"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	n := 0
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		n++
+	}
+	err = c.Commit()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 2 {
+		t.Fatal("wrong number of entries", n)
+	}
+
+	err = db.Del(data[0].Bucket, data[0].Keys)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	_, err = db.Get(data[0].Bucket, data[0].Keys)
+	if !IsError(err, ErrKeyNotFound) {
+		t.Fatal("expected ErrKeyNotFound", err)
+	}
+
+	_, err = db.NewCursor([]byte("test_bucket"), 3, true, []byte("2016"))
+	if !IsError(err, ErrKeyNotFound) {
+		t.Fatal("expected ErrKeyNotFound", err)
+	}
+	// The writable transaction of the failed cursor must be closed.
+	err = db.Put(data[0].Bucket, data[0].Keys, data[0].Data)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db.Retries = 1
+	db.RetryDelay = 0
+	err = db.Close()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error { return nil })
+	if err != bolt.ErrDatabaseNotOpen {
+		t.Fatal("expected ErrDatabaseNotOpen", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	path := filepath.Join(dir, filename)
+
+	db, err := Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	info := BucketInfo{
+		NumKeys: 3,
+		Strict:  true,
+	}
+	err = db.Register([]byte("test_posts"), info)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.Put([]byte("test_posts"), [][]byte{[]byte("1"), []byte("2015"), []byte("a")}, []byte("a"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	_, err = db.NewCursor([]byte("test_other"), 0, false)
+	if !IsError(err, ErrNotRegistered) {
+		t.Fatal("expected ErrNotRegistered", err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The registry is read at open.
+	db, err = Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+	got, ok := db.Info([]byte("test_posts"))
+	if !ok || got.NumKeys != 3 || !got.Strict {
+		t.Fatalf("wrong info %#v", got)
+	}
+	c, err := db.NewCursor([]byte("test_posts"), 0, false)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer c.Rollback()
+	_, v := c.First()
+	if string(v) != "a" {
+		t.Fatal("wrong value", string(v))
+	}
+}
+
+func TestStrictArity(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_strict")
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Register(tx, bucket, BucketInfo{NumKeys: 2, Strict: true})
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, bucket, [][]byte{[]byte("a"), []byte("1")}, []byte("a1"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, bucket, [][]byte{[]byte("a")}, []byte("a"))
+		if !IsError(err, ErrArity) {
+			return e.New("expected arity error, got %v", err)
+		}
+		err = Put(tx, bucket, [][]byte{[]byte("a"), []byte("1"), []byte("x")}, []byte("a1x"))
+		if !IsError(err, ErrArity) {
+			return e.New("expected arity error, got %v", err)
+		}
+		err = PutBatch(tx, bucket, []Item{
+			{Keys: [][]byte{[]byte("b"), []byte("1")}, Data: []byte("b1")},
+			{Keys: [][]byte{[]byte("c")}, Data: []byte("c")},
+		})
+		if !IsError(err, ErrArity) {
+			return e.New("expected arity error, got %v", err)
+		}
+		// Buckets not registered as strict accept any depth.
+		err = Put(tx, []byte("test_other"), [][]byte{[]byte("a")}, []byte("a"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import "github.com/fcavani/e"
+
+const ErrDecode = "can't decode the value"
+
+// Decoder unmarshals the value of a leaf.
+type Decoder func(v []byte) (interface{}, error)
+
+// SetDecoder sets the decoder used by FirstDecoded and NextDecoded. A nil
+// decoder returns the values as they are.
+func (c *Cursor) SetDecoder(d Decoder) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.decoder = d
+}
+
+// FirstDecoded is like First but returns the value unmarshalled by the
+// decoder. If the decoder fails it returns nil and Err returns the error, the
+// cursor stays in the entry, so NextDecoded continues after it.
+func (c *Cursor) FirstDecoded() ([][]byte, interface{}) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("FirstDecoded")()
+
+	c.saveState()
+	k, v := c.start(c.first())
+	v = c.finish(k, v)
+	return c.decode(k, v)
+}
+
+// NextDecoded is like Next but returns the value unmarshalled by the decoder,
+// see FirstDecoded.
+func (c *Cursor) NextDecoded() ([][]byte, interface{}) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("NextDecoded")()
+
+	c.saveState()
+	k, v := c.step(c.next, c.prev)
+	v = c.finish(k, v)
+	return c.decode(k, v)
+}
+
+func (c *Cursor) decode(k [][]byte, v []byte) ([][]byte, interface{}) {
+	if k == nil {
+		return nil, nil
+	}
+	if c.keysOnly {
+		return k, nil
+	}
+	if c.decoder == nil {
+		return k, v
+	}
+	obj, err := c.decoder(v)
+	if err != nil {
+		c.err = e.Push(err, e.New("%v at %v%v", ErrDecode, string(c.Bucket), Path(k)))
+		return nil, nil
+	}
+	return k, obj
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorDecoder(t *testing.T) {
+	data := []testData{
+		{[]byte("test_decoder"), [][]byte{[]byte("2015"), []byte("a")}, []byte(`{"Title":"a"}`)},
+		{[]byte("test_decoder"), [][]byte{[]byte("2015"), []byte("b")}, []byte(`{"Title":`)},
+		{[]byte("test_decoder"), [][]byte{[]byte("2015"), []byte("c")}, []byte(`{"Title":"c"}`)},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	type post struct {
+		Title string
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_decoder"),
+			NumKeys: 2,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, v := c.FirstDecoded()
+		if _, ok := v.([]byte); !ok {
+			return e.New("value changed without a decoder %#v", v)
+		}
+
+		c.SetDecoder(func(v []byte) (interface{}, error) {
+			var p post
+			err := json.Unmarshal(v, &p)
+			if err != nil {
+				return nil, err
+			}
+			return &p, nil
+		})
+		k, v := c.FirstDecoded()
+		if k == nil || v.(*post).Title != "a" {
+			return e.New("wrong first %#v", v)
+		}
+		k, _ = c.NextDecoded()
+		if k != nil {
+			return e.New("invalid value decoded")
+		}
+		if !IsError(c.Err(), ErrDecode) {
+			return e.New("decode error not kept")
+		}
+		k, v = c.NextDecoded()
+		if k == nil || v.(*post).Title != "c" {
+			return e.New("wrong next after the error %#v", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
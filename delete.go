@@ -0,0 +1,33 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// DeleteWhere deletes the leaves under prefix for which pred returns true.
+// The leaves are collected first and deleted after the walk, so the tree is
+// never changed under a live cursor. It returns the number of deleted leaves.
+func DeleteWhere(tx *bolt.Tx, bucket []byte, prefix [][]byte, pred func(keys [][]byte, v []byte) bool) (int, error) {
+	var del [][][]byte
+	err := walk(tx, bucket, prefix, func(keys [][]byte, v []byte) error {
+		if pred(keys, v) {
+			del = append(del, copyKeys(keys))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	for i, keys := range del {
+		err := Del(tx, bucket, keys)
+		if err != nil {
+			return i, e.Forward(err)
+		}
+	}
+	return len(del), nil
+}
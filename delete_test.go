@@ -0,0 +1,103 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestDeleteWhere(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key1"), []byte("key1")}, []byte("del")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key1"), []byte("key2")}, []byte("del")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key2"), []byte("key1")}, []byte("keep")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key2"), []byte("key2")}, []byte("del")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key2"), []byte("key1"), []byte("key1")}, []byte("del")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		n, err := DeleteWhere(tx, []byte("test_bucket"), [][]byte{[]byte("key1")}, func(keys [][]byte, v []byte) bool {
+			return bytes.Equal(v, []byte("del"))
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 3 {
+			return e.New("wrong number of deleted entries %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			v, err := Get(tx, d.Bucket, d.Keys)
+			if i == 2 || i == 4 {
+				if err != nil {
+					return e.Push(err, e.New("Fail to get %v", i))
+				}
+				if !bytes.Equal(v, d.Data) {
+					return e.New("not equal %v", i)
+				}
+				continue
+			}
+			if err == nil {
+				return e.New("entry %v wasn't deleted", i)
+			}
+		}
+		// test_bucket, key1, key1/key2, key2 and key2/key1.
+		n := 0
+		tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if string(name) != bucketsBucket {
+				n++
+			}
+			return nil
+		})
+		if n != 5 {
+			return e.New("empty buckets not removed %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+
+	"github.com/fcavani/e"
+)
+
+const ErrInvEncoding = "invalid key path encoding"
+
+// encodeKeys serializes a key path into one byte slice. Each key is
+// prefixed by its length as an uvarint.
+func encodeKeys(keys ...[]byte) []byte {
+	size := 0
+	for _, k := range keys {
+		size += binary.MaxVarintLen64 + len(k)
+	}
+	buf := make([]byte, size)
+	n := 0
+	for _, k := range keys {
+		n += binary.PutUvarint(buf[n:], uint64(len(k)))
+		n += copy(buf[n:], k)
+	}
+	return buf[:n]
+}
+
+// decodeKeys is the inverse of encodeKeys.
+func decodeKeys(buf []byte) ([][]byte, error) {
+	keys := make([][]byte, 0)
+	for len(buf) > 0 {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < l {
+			return nil, e.New(ErrInvEncoding)
+		}
+		buf = buf[n:]
+		key := make([]byte, l)
+		copy(key, buf[:l])
+		keys = append(keys, key)
+		buf = buf[l:]
+	}
+	return keys, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fcavani/e"
+)
+
+// KeyError is an error with the location in the tree where it happened.
+type KeyError struct {
+	kind   string
+	bucket []byte
+	level  int
+	path   [][]byte
+}
+
+// newKeyError builds a KeyError for the key path[level] of bucket. kind is
+// one of the Err constants.
+func newKeyError(kind string, bucket []byte, level int, path [][]byte) *KeyError {
+	return &KeyError{
+		kind:   kind,
+		bucket: bucket,
+		level:  level,
+		path:   copyKeys(path),
+	}
+}
+
+// Kind returns the Err constant that describes the error.
+func (k *KeyError) Kind() string {
+	return k.kind
+}
+
+// Bucket returns the top bucket.
+func (k *KeyError) Bucket() []byte {
+	return k.bucket
+}
+
+// Level returns the level of the offending key or -1 if the error isn't
+// about a key.
+func (k *KeyError) Level() int {
+	return k.level
+}
+
+// Key returns the offending key or nil.
+func (k *KeyError) Key() []byte {
+	if k.level < 0 || k.level >= len(k.path) {
+		return nil
+	}
+	return k.path[k.level]
+}
+
+// Path returns the key path given to the failed operation.
+func (k *KeyError) Path() [][]byte {
+	return k.path
+}
+
+// Parent returns the keys above the offending key.
+func (k *KeyError) Parent() [][]byte {
+	if k.level < 0 {
+		return nil
+	}
+	if k.level > len(k.path) {
+		return k.path
+	}
+	return k.path[:k.level]
+}
+
+// Error returns the Err constant alone, so e.Equal(err, ErrXxx) still holds.
+// Format describes the location of the error.
+func (k *KeyError) Error() string {
+	return k.kind
+}
+
+// Format describes the error and its location using the level names of the
+// layout, l may be nil.
+func (k *KeyError) Format(l Layout) string {
+	if k.level < 0 {
+		return fmt.Sprintf("%v: %v", k.kind, string(k.bucket))
+	}
+	under := string(k.bucket) + Path(k.Parent()).String()
+	if name := l.Level(k.level); name != "" {
+		return fmt.Sprintf("%v at level %v (%v) under %v", k.kind, k.level, name, under)
+	}
+	return fmt.Sprintf("%v at level %v under %v", k.kind, k.level, under)
+}
+
+// AsKeyError returns the KeyError inside err.
+func AsKeyError(err error) (*KeyError, bool) {
+	var k *KeyError
+	if errors.As(err, &k) {
+		return k, true
+	}
+	return nil, false
+}
+
+// IsError reports if err is of the kind given by one of the Err constants.
+func IsError(err error, kind string) bool {
+	if err == nil {
+		return false
+	}
+	if k, ok := AsKeyError(err); ok {
+		return k.kind == kind
+	}
+	return e.Equal(err, kind) || e.Contains(err, kind)
+}
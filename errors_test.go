@@ -0,0 +1,82 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestKeyError(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	keys := [][]byte{[]byte("pt-br"), []byte("2015"), []byte("12"), []byte("title")}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return Put(tx, []byte("posts"), keys, []byte("text"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		missing := [][]byte{[]byte("pt-br"), []byte("2015"), []byte("11"), []byte("title")}
+		_, err := Get(tx, []byte("posts"), missing)
+		k, ok := AsKeyError(err)
+		if !ok {
+			return e.New("not a KeyError: %v", err)
+		}
+		if !IsError(err, ErrKeyNotFound) || k.Kind() != ErrKeyNotFound {
+			return e.New("wrong kind %v", k.Kind())
+		}
+		if k.Level() != 2 || !bytes.Equal(k.Key(), []byte("11")) || string(k.Bucket()) != "posts" {
+			return e.New("wrong location %v", k.Format(nil))
+		}
+		if !e.Equal(err, ErrKeyNotFound) {
+			return e.New("wrong message %v", k.Error())
+		}
+		if msg := k.Format(nil); msg != "key not found at level 2 under posts/pt-br/2015" {
+			return e.New("wrong message %v", msg)
+		}
+		msg := k.Format(Layout{"lang", "year", "month", "title"})
+		if msg != "key not found at level 2 (month) under posts/pt-br/2015" {
+			return e.New("wrong message %v", msg)
+		}
+
+		_, err = Get(tx, []byte("nobucket"), keys)
+		if !IsError(err, ErrInvBucket) {
+			return e.New("wrong error %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return Del(tx, []byte("posts"), [][]byte{[]byte("en")})
+	})
+	if !IsError(err, ErrKeyNotFound) {
+		t.Fatal("wrong error", err)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// etagBucket has the hashes and the modification times of the leaves of the
+// buckets with ETags enabled. The key of the bucket alone marks it as enabled.
+const etagBucket = "__etag"
+
+// EnableETag hashes every leaf of bucket and keeps the hashes updated by Put,
+// PutBatch and Del, so ETag doesn't read the values. The modification time of
+// the leaves is kept too, the leaves already in bucket get the current time.
+func EnableETag(tx *bolt.Tx, bucket []byte) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(etagBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(encodeKeys(bucket), []byte{})
+	if err != nil {
+		return e.Forward(err)
+	}
+	if tx.Bucket(bucket) == nil {
+		return nil
+	}
+	now := time.Now()
+	err = walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+		return b.Put(etagKey(bucket, keys), etagValue(v, now))
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// DisableETag removes the hashes of bucket.
+func DisableETag(tx *bolt.Tx, bucket []byte) error {
+	return dropETags(tx, bucket, false)
+}
+
+// ETag returns the quoted hash of the value in the key path, ready for the
+// ETag header. If ETags are enabled in the bucket the value isn't read,
+// otherwise it is read and hashed on every call.
+func ETag(tx *bolt.Tx, bucket []byte, keys [][]byte) (string, error) {
+	if len(keys) == 0 {
+		return "", newKeyError(ErrNoKeys, bucket, -1, nil)
+	}
+	b, err := prefixBucket(tx, bucket, keys[:len(keys)-1])
+	if err != nil {
+		return "", e.Forward(err)
+	}
+	if b.Get(keys[len(keys)-1]) == nil {
+		return "", newKeyError(ErrKeyNotFound, bucket, len(keys)-1, keys)
+	}
+	err = checkExpired(tx, bucket, keys)
+	if err != nil {
+		return "", err
+	}
+	if b := etagsOf(tx, bucket); b != nil {
+		if h := b.Get(etagKey(bucket, keys)); h != nil {
+			return quoteETag(h[:etagHashLen]), nil
+		}
+	}
+	v, err := Get(tx, bucket, keys)
+	if err != nil {
+		return "", e.Forward(err)
+	}
+	return quoteETag(hashValue(v)), nil
+}
+
+// dropETags removes the hashes of the leaves of bucket and, if keep is false,
+// the mark that enables them.
+func dropETags(tx *bolt.Tx, bucket []byte, keep bool) error {
+	b := tx.Bucket([]byte(etagBucket))
+	if b == nil {
+		return nil
+	}
+	prefix := encodeKeys(bucket)
+	var del [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if keep && bytes.Equal(k, prefix) {
+			continue
+		}
+		del = append(del, append([]byte{}, k...))
+	}
+	for _, k := range del {
+		err := b.Delete(k)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	if empty(b) {
+		err := tx.DeleteBucket([]byte(etagBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// etagsOf returns the bucket of the hashes if bucket has ETags enabled.
+func etagsOf(tx *bolt.Tx, bucket []byte) *bolt.Bucket {
+	b := tx.Bucket([]byte(etagBucket))
+	if b == nil || b.Get(encodeKeys(bucket)) == nil {
+		return nil
+	}
+	return b
+}
+
+// putETag updates the hash of the key path.
+func putETag(tx *bolt.Tx, bucket []byte, keys [][]byte, data []byte) error {
+	b := etagsOf(tx, bucket)
+	if b == nil {
+		return nil
+	}
+	err := b.Put(etagKey(bucket, keys), etagValue(data, time.Now()))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// delETag removes the hash of the key path.
+func delETag(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
+	b := etagsOf(tx, bucket)
+	if b == nil {
+		return nil
+	}
+	err := b.Delete(etagKey(bucket, keys))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+func etagKey(bucket []byte, keys [][]byte) []byte {
+	return encodeKeys(append([][]byte{bucket}, keys...)...)
+}
+
+const etagHashLen = 16
+
+func hashValue(v []byte) []byte {
+	h := sha256.Sum256(v)
+	return h[:etagHashLen]
+}
+
+// etagValue is the hash of data followed by the modification time.
+func etagValue(data []byte, mod time.Time) []byte {
+	buf := make([]byte, etagHashLen+8)
+	copy(buf, hashValue(data))
+	binary.BigEndian.PutUint64(buf[etagHashLen:], uint64(mod.UnixNano()))
+	return buf
+}
+
+func quoteETag(h []byte) string {
+	return `"` + hex.EncodeToString(h) + `"`
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestETag(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_etag")
+	k1 := [][]byte{[]byte("2015"), []byte("a")}
+	k2 := [][]byte{[]byte("2015"), []byte("b")}
+
+	var plain string
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, k1, []byte("one"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		plain, err = ETag(tx, bucket, k1)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket([]byte(etagBucket)) != nil {
+			return e.New("hashes stored without EnableETag")
+		}
+		err = EnableETag(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Put(tx, bucket, k2, []byte("two"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		tag, err := ETag(tx, bucket, k1)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tag != plain {
+			return e.New("stored hash differs %v %v", tag, plain)
+		}
+		if tx.Bucket([]byte(etagBucket)).Get(etagKey(bucket, k2)) == nil {
+			return e.New("hash not maintained by Put")
+		}
+		err = Put(tx, bucket, k1, []byte("uno"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		tag, err = ETag(tx, bucket, k1)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tag == plain {
+			return e.New("hash not updated")
+		}
+		err = Del(tx, bucket, k1)
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, err = ETag(tx, bucket, k1)
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("deleted key has an etag: %v", err)
+		}
+		if tx.Bucket([]byte(etagBucket)).Get(etagKey(bucket, k1)) != nil {
+			return e.New("hash not removed by Del")
+		}
+		err = DropBucket(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if etagsOf(tx, bucket) == nil {
+			return e.New("DropBucket disabled the etags")
+		}
+		if tx.Bucket([]byte(etagBucket)).Get(etagKey(bucket, k2)) != nil {
+			return e.New("hash not removed by DropBucket")
+		}
+		err = DisableETag(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket([]byte(etagBucket)) != nil {
+			return e.New("empty etag bucket not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestETagNoRead(t *testing.T) {
+	bucket := []byte("test_etag")
+	keys := [][]byte{[]byte("a")}
+	defer EncryptedBucket(bucket, nil)
+	db := openTestDB(t)
+	defer db.Close()
+
+	err := EncryptedBucket(bucket, bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	var want string
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := EnableETag(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Put(tx, bucket, keys, []byte("value"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		want, err = ETag(tx, bucket, keys)
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// Without the key the value can't be read, the stored hash is used.
+	EncryptedBucket(bucket, nil)
+	err = db.View(func(tx *bolt.Tx) error {
+		tag, err := ETag(tx, bucket, keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tag != want {
+			return e.New("wrong etag %v, want %v", tag, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+)
+
+// openExampleDB opens a database in a temporary directory. close closes it
+// and removes the directory.
+func openExampleDB() (db *bolt.DB, close func()) {
+	dir, err := ioutil.TempDir("", "example-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err = bolt.Open(filepath.Join(dir, "example.db"), 0600, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// posts fills the bucket "posts", a tree of year -> month -> title.
+func posts(db *bolt.DB) {
+	data := []struct {
+		year, month, title, text string
+	}{
+		{"2014", "12", "winter", "cold"},
+		{"2015", "01", "new year", "party"},
+		{"2015", "01", "resolutions", "gym"},
+		{"2015", "03", "spring", "flowers"},
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, d := range data {
+			keys := [][]byte{[]byte(d.year), []byte(d.month), []byte(d.title)}
+			err := boltdbutils.Put(tx, []byte("posts"), keys, []byte(d.text))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExamplePut() {
+	db, close := openExampleDB()
+	defer close()
+
+	bucket := []byte("posts")
+	keys := [][]byte{[]byte("2015"), []byte("01"), []byte("new year")}
+	err := db.Update(func(tx *bolt.Tx) error {
+		return boltdbutils.Put(tx, bucket, keys, []byte("party"))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		v, err := boltdbutils.Get(tx, bucket, keys)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", v)
+		_, err = boltdbutils.Get(tx, bucket, [][]byte{[]byte("2015"), []byte("02"), []byte("new year")})
+		fmt.Println(boltdbutils.IsError(err, boltdbutils.ErrKeyNotFound))
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// party
+	// true
+}
+
+func ExampleCursor() {
+	db, close := openExampleDB()
+	defer close()
+	posts(db)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  []byte("posts"),
+			NumKeys: 3,
+		}
+		err := c.Init()
+		if err != nil {
+			return err
+		}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			fmt.Printf("%s/%s/%s: %s\n", k[0], k[1], k[2], v)
+		}
+		return c.Err()
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 2014/12/winter: cold
+	// 2015/01/new year: party
+	// 2015/01/resolutions: gym
+	// 2015/03/spring: flowers
+}
+
+func ExampleCursor_Skip() {
+	db, close := openExampleDB()
+	defer close()
+	posts(db)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  []byte("posts"),
+			NumKeys: 3,
+		}
+		err := c.Init()
+		if err != nil {
+			return err
+		}
+		// Skip moves to the leaf after the first count leaves, a page of
+		// two leaves starting in the second.
+		k, v := c.Skip(1)
+		for i := 0; i < 2 && k != nil; i++ {
+			fmt.Printf("%s: %s\n", k[2], v)
+			k, v = c.Next()
+		}
+		return c.Err()
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// new year: party
+	// resolutions: gym
+}
+
+func ExampleCursor_Seek() {
+	db, close := openExampleDB()
+	defer close()
+	posts(db)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  []byte("posts"),
+			NumKeys: 3,
+		}
+		// Pin the cursor to the posts of 2015.
+		err := c.Init([]byte("2015"))
+		if err != nil {
+			return err
+		}
+		// Seek moves to the first leaf equal or after the keys.
+		for k, v := c.Seek([]byte("2015"), []byte("02"), []byte("")); k != nil; k, v = c.Next() {
+			fmt.Printf("%s/%s: %s\n", k[1], k[2], v)
+		}
+		return c.Err()
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 03/spring: flowers
+}
+
+func ExampleCursor_reverse() {
+	db, close := openExampleDB()
+	defer close()
+	posts(db)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  []byte("posts"),
+			NumKeys: 3,
+			// The newest posts first.
+			Reverse: true,
+		}
+		err := c.Init()
+		if err != nil {
+			return err
+		}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			fmt.Printf("%s/%s/%s: %s\n", k[0], k[1], k[2], v)
+		}
+		return c.Err()
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 2015/03/spring: flowers
+	// 2015/01/resolutions: gym
+	// 2015/01/new year: party
+	// 2014/12/winter: cold
+}
+
+func ExampleGetAll() {
+	db, close := openExampleDB()
+	defer close()
+	posts(db)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		prefix := [][]byte{[]byte("2015"), []byte("01")}
+		kvs, err := boltdbutils.GetAll(tx, []byte("posts"), prefix, 0)
+		if err != nil {
+			return err
+		}
+		for _, kv := range kvs {
+			fmt.Printf("%s: %s\n", kv.Keys[2], kv.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// new year: party
+	// resolutions: gym
+}
+
+func ExampleScanContext() {
+	db, close := openExampleDB()
+	defer close()
+	posts(db)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		ctx := boltdbutils.WithTx(context.Background(), tx)
+		return boltdbutils.ScanContext(ctx, []byte("posts"), [][]byte{[]byte("2014")}, func(keys [][]byte, v []byte) error {
+			fmt.Printf("%s: %s\n", keys[2], v)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// winter: cold
+}
@@ -0,0 +1,169 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrInvExport = "invalid export document"
+
+// exportFormat identifies the documents written by Export.
+const exportFormat = "boltdbutils"
+
+const exportVersion = 1
+
+// ExportEntry is one leaf in the document written by Export. Keys and Value
+// are base64 encoded in the JSON.
+type ExportEntry struct {
+	Keys  [][]byte `json:"keys"`
+	Value []byte   `json:"value"`
+}
+
+type exportHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Bucket  []byte `json:"bucket"`
+}
+
+// Export writes a JSON document with every leaf of bucket and its full key
+// path, in key order. The names of the intermediate buckets aren't written,
+// the hierarchy is given by the key paths. The document is written as the
+// tree is walked, without holding it in memory:
+//
+//	{"format":"boltdbutils","version":1,"bucket":"...","entries":[
+//	{"keys":["...","..."],"value":"..."},
+//	...
+//	]}
+//
+// The values are written decoded, the ones of an EncryptedBucket in clear.
+func Export(tx *bolt.Tx, bucket []byte, w io.Writer) error {
+	head, err := json.Marshal(exportHeader{
+		Format:  exportFormat,
+		Version: exportVersion,
+		Bucket:  bucket,
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	// Open the object again to append the entries.
+	_, err = w.Write(append(head[:len(head)-1], []byte(`,"entries":[`)...))
+	if err != nil {
+		return e.Forward(err)
+	}
+	sep := []byte("\n")
+	err = walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+		buf, err := json.Marshal(ExportEntry{Keys: keys, Value: v})
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, err = w.Write(append(sep, buf...))
+		if err != nil {
+			return e.Forward(err)
+		}
+		sep = []byte(",\n")
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	_, err = w.Write([]byte("\n]}\n"))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Import reads a document written by Export and puts every leaf in the
+// bucket named in the document, creating new intermediate buckets. It returns
+// the name of the bucket.
+func Import(tx *bolt.Tx, r io.Reader) ([]byte, error) {
+	dec := json.NewDecoder(r)
+	var head exportHeader
+	var bucket []byte
+	err := expectDelim(dec, '{')
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		name, ok := t.(string)
+		if !ok {
+			return nil, e.New(ErrInvExport)
+		}
+		switch name {
+		case "format":
+			err = dec.Decode(&head.Format)
+		case "version":
+			err = dec.Decode(&head.Version)
+		case "bucket":
+			err = dec.Decode(&head.Bucket)
+		case "entries":
+			if head.Format != exportFormat || head.Version != exportVersion || len(head.Bucket) == 0 {
+				return nil, e.New(ErrInvExport)
+			}
+			bucket = head.Bucket
+			err = importEntries(tx, bucket, dec)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+	}
+	err = expectDelim(dec, '}')
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	if bucket == nil {
+		return nil, e.New(ErrInvExport)
+	}
+	return bucket, nil
+}
+
+func importEntries(tx *bolt.Tx, bucket []byte, dec *json.Decoder) error {
+	_, err := tx.CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = expectDelim(dec, '[')
+	if err != nil {
+		return e.Forward(err)
+	}
+	for dec.More() {
+		var entry ExportEntry
+		err = dec.Decode(&entry)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if entry.Value == nil {
+			entry.Value = []byte{}
+		}
+		err = Put(tx, bucket, entry.Keys, entry.Value)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return e.Forward(err)
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return e.New(ErrInvExport)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestExportImport(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte{0, 1, 2}},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte{}},
+	}
+
+	open := func() *bolt.DB {
+		filename, err := rand.FileName("blog-", "db", 10)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		dir, err := ioutil.TempDir("", "blog-")
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		return db
+	}
+
+	src := open()
+	err := src.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var buf bytes.Buffer
+	err = src.View(func(tx *bolt.Tx) error {
+		return Export(tx, []byte("test_bucket"), &buf)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Fatal("invalid json", buf.String())
+	}
+
+	dst := open()
+	err = dst.Update(func(tx *bolt.Tx) error {
+		bucket, err := Import(tx, bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(bucket) != "test_bucket" {
+			return e.New("wrong bucket %v", string(bucket))
+		}
+		for i, d := range data {
+			v, err := Get(tx, d.Bucket, d.Keys)
+			if err != nil {
+				return e.Push(err, e.New("Fail to get %v", i))
+			}
+			if !bytes.Equal(v, d.Data) {
+				return e.New("not equal %v", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The intermediate buckets have new names.
+	err = src.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return dst.View(func(dtx *bolt.Tx) error {
+				if string(name) != "test_bucket" && string(name) != bucketsBucket && dtx.Bucket(name) != nil {
+					return e.New("bucket %v copied", string(name))
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = dst.Update(func(tx *bolt.Tx) error {
+		_, err := Import(tx, strings.NewReader(`{"format":"other","version":1,"bucket":"eA==","entries":[]}`))
+		if !IsError(err, ErrInvExport) {
+			return e.New("expected ErrInvExport: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
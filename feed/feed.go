@@ -0,0 +1,213 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package feed makes Atom and RSS feeds with the newest entries of a bucket
+// indexed by date, like pub -> year -> month -> day -> title.
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+// Feed describes the feed itself.
+type Feed struct {
+	Title       string
+	Link        string
+	ID          string
+	Description string
+	Author      string
+	Updated     time.Time
+}
+
+// Item is one entry of the feed.
+type Item struct {
+	Title   string
+	Link    string
+	ID      string
+	Author  string
+	Summary string
+	Content string
+	Updated time.Time
+}
+
+// Mapper converts a leaf in a feed item. A nil item skips the leaf. The key
+// path and the value are only valid during the call.
+type Mapper func(keys [][]byte, v []byte) (*Item, error)
+
+// Latest returns the items of the newest n leaves under prefix. The keys of
+// the levels must sort in chronological order, the leaves are read with a
+// reverse cursor.
+func Latest(tx *bolt.Tx, bucket []byte, numKeys int, prefix [][]byte, n int, mapper Mapper) ([]*Item, error) {
+	c := &boltdbutils.Cursor{
+		Tx:      tx,
+		Bucket:  bucket,
+		NumKeys: numKeys,
+		Reverse: true,
+	}
+	err := c.Init(prefix...)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	items := make([]*Item, 0, n)
+	for k, v := c.First(); k != nil && len(items) < n; k, v = c.Next() {
+		item, err := mapper(k, v)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	err = c.Err()
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return items, nil
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	Link    *atomLink   `xml:"link,omitempty"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Summary *atomText   `xml:"summary,omitempty"`
+	Content *atomText   `xml:"content,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Link     *atomLink   `xml:"link,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// Atom writes the feed in the Atom format.
+func Atom(w io.Writer, f *Feed, items []*Item) error {
+	af := atomFeed{
+		Title:    f.Title,
+		ID:       f.ID,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Subtitle: f.Description,
+	}
+	if f.Link != "" {
+		af.Link = &atomLink{Href: f.Link, Rel: "alternate"}
+	}
+	if f.Author != "" {
+		af.Author = &atomPerson{Name: f.Author}
+	}
+	for _, item := range items {
+		entry := atomEntry{
+			Title:   item.Title,
+			ID:      item.ID,
+			Updated: item.Updated.UTC().Format(time.RFC3339),
+		}
+		if entry.ID == "" {
+			entry.ID = item.Link
+		}
+		if item.Link != "" {
+			entry.Link = &atomLink{Href: item.Link, Rel: "alternate"}
+		}
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "html", Body: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: item.Content}
+		}
+		af.Entries = append(af.Entries, entry)
+	}
+	return encode(w, af)
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link,omitempty"`
+	GUID        string `xml:"guid,omitempty"`
+	Author      string `xml:"author,omitempty"`
+	Description string `xml:"description,omitempty"`
+	PubDate     string `xml:"pubDate"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// RSS writes the feed in the RSS 2.0 format.
+func RSS(w io.Writer, f *Feed, items []*Item) error {
+	rf := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range items {
+		ri := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        item.ID,
+			Author:      item.Author,
+			Description: item.Summary,
+			PubDate:     item.Updated.UTC().Format(time.RFC1123Z),
+		}
+		if ri.Description == "" {
+			ri.Description = item.Content
+		}
+		rf.Channel.Items = append(rf.Channel.Items, ri)
+	}
+	return encode(w, rf)
+}
+
+func encode(w io.Writer, v interface{}) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return e.Forward(err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(v)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestFeed(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	posts := [][]string{
+		{"pub", "2014", "12", "31", "Old"},
+		{"pub", "2015", "01", "02", "Newest"},
+		{"pub", "2015", "01", "01", "Draft"},
+		{"pub", "2015", "01", "01", "New"},
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, p := range posts {
+			keys := make([][]byte, len(p))
+			for i, k := range p {
+				keys[i] = []byte(k)
+			}
+			err := boltdbutils.Put(tx, []byte("blog"), keys, []byte("text of "+p[4]))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var items []*Item
+	err = db.View(func(tx *bolt.Tx) error {
+		var err error
+		items, err = Latest(tx, []byte("blog"), 5, [][]byte{[]byte("pub")}, 2, func(keys [][]byte, v []byte) (*Item, error) {
+			if string(keys[4]) == "Draft" {
+				return nil, nil
+			}
+			date, err := time.Parse("2006/01/02", string(keys[1])+"/"+string(keys[2])+"/"+string(keys[3]))
+			if err != nil {
+				return nil, e.Forward(err)
+			}
+			return &Item{
+				Title:   string(keys[4]),
+				Link:    "http://example.com/" + string(keys[4]),
+				Content: string(v),
+				Updated: date,
+			}, nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(items) != 2 || items[0].Title != "Newest" || items[1].Title != "New" {
+		t.Fatal("wrong items", items)
+	}
+
+	f := &Feed{
+		Title:   "Blog",
+		Link:    "http://example.com/",
+		ID:      "http://example.com/",
+		Updated: items[0].Updated,
+	}
+	var buf bytes.Buffer
+	err = Atom(&buf, f, items)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	var af atomFeed
+	err = xml.Unmarshal(buf.Bytes(), &af)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(af.Entries) != 2 || af.Entries[0].ID != "http://example.com/Newest" || af.Updated != "2015-01-02T00:00:00Z" {
+		t.Fatal("wrong atom feed", buf.String())
+	}
+
+	buf.Reset()
+	err = RSS(&buf, f, items)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	var rf rssFeed
+	err = xml.Unmarshal(buf.Bytes(), &rf)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(rf.Channel.Items) != 2 || rf.Channel.Items[1].Description != "text of New" {
+		t.Fatal("wrong rss feed", buf.String())
+	}
+}
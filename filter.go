@@ -0,0 +1,62 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import "github.com/fcavani/e"
+
+// Filter makes the cursor skip the keys of level for which pred returns false,
+// and with them the whole subtree under the key. The subtrees are skipped
+// without being read. A nil pred removes the filter of the level. The levels
+// pinned by Init or SeekPrefix aren't filtered and Count and EstimatedCount
+// ignore the filters.
+func (c *Cursor) Filter(level int, pred func(key []byte) bool) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	if level < 0 || level >= c.NumKeys {
+		c.err = e.New(ErrInvLevel)
+		return
+	}
+	if c.filters == nil {
+		if pred == nil {
+			return
+		}
+		c.filters = make([]func(key []byte) bool, c.NumKeys)
+	}
+	c.filters[level] = pred
+	for _, f := range c.filters {
+		if f != nil {
+			return
+		}
+	}
+	c.filters = nil
+}
+
+// match reports if the key k of level i passes the filter.
+func (c *Cursor) match(i int, k []byte) bool {
+	if c.filters == nil || c.filters[i] == nil {
+		return true
+	}
+	return c.filters[i](k)
+}
+
+// filter moves the cursor of level i with move and then with step until a key
+// that passes the filter.
+func (c *Cursor) filter(i int, move, step func() ([]byte, []byte)) ([]byte, []byte) {
+	k, v := c.at(i).visit(move())
+	for k != nil && !c.wanted(i, k, v) {
+		k, v = c.at(i).visit(step())
+	}
+	return k, v
+}
+
+// wanted reports if the entry k, v of level i passes the filter. Above the
+// last level the leaves, of a tree with leaves in many levels, are skipped.
+func (c *Cursor) wanted(i int, k, v []byte) bool {
+	if i < c.NumKeys-1 && subBucket(c.Tx, v) == nil {
+		return false
+	}
+	return c.match(i, k)
+}
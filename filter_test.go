@@ -0,0 +1,138 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorFilter(t *testing.T) {
+	data := []testData{
+		{[]byte("test_filter"), [][]byte{[]byte("en"), []byte("0"), []byte("a")}, []byte("a")},
+		{[]byte("test_filter"), [][]byte{[]byte("en"), []byte("1"), []byte("b")}, []byte("b")},
+		{[]byte("test_filter"), [][]byte{[]byte("pt-br"), []byte("0"), []byte("c")}, []byte("c")},
+		{[]byte("test_filter"), [][]byte{[]byte("pt-br"), []byte("1"), []byte("d")}, []byte("d")},
+		{[]byte("test_filter"), [][]byte{[]byte("pt-br"), []byte("1"), []byte("e")}, []byte("e")},
+		{[]byte("test_filter"), [][]byte{[]byte("zz"), []byte("0"), []byte("f")}, []byte("f")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	scan := func(c *Cursor) string {
+		got := ""
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			got += string(v)
+		}
+		return got
+	}
+	pub := func(key []byte) bool {
+		return string(key) == "1"
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_filter"),
+			NumKeys: 3,
+			Debug:   true,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		c.Filter(1, pub)
+		if got := scan(c); got != "bde" {
+			return e.New("wrong filtered scan %v", got)
+		}
+		if r := c.Report(); r.LeavesVisited != 3 {
+			return e.New("filtered subtrees were read %v", r.LeavesVisited)
+		}
+
+		_, v := c.Skip(1)
+		if string(v) != "d" {
+			return e.New("wrong skip %s", v)
+		}
+		_, v = c.Seek([]byte("en"), []byte("0"), []byte("a"))
+		if string(v) != "b" {
+			return e.New("wrong seek %s", v)
+		}
+		_, v = c.Last()
+		if string(v) != "e" {
+			return e.New("wrong last %s", v)
+		}
+		_, v = c.Prev()
+		if string(v) != "d" {
+			return e.New("wrong prev %s", v)
+		}
+		_, v = c.Prev()
+		if string(v) != "b" {
+			return e.New("wrong prev %s", v)
+		}
+
+		c.Reverse = true
+		if got := scan(c); got != "edb" {
+			return e.New("wrong reverse scan %v", got)
+		}
+		c.Reverse = false
+
+		c.Filter(0, func(key []byte) bool {
+			return string(key) == "pt-br"
+		})
+		c.Filter(2, func(key []byte) bool {
+			return string(key) != "d"
+		})
+		if got := scan(c); got != "e" {
+			return e.New("wrong scan with three filters %v", got)
+		}
+
+		c.Filter(0, nil)
+		c.Filter(1, nil)
+		c.Filter(2, nil)
+		if got := scan(c); got != "abcdef" {
+			return e.New("filters not removed %v", got)
+		}
+
+		c.Filter(3, pub)
+		if c.Err() == nil {
+			return e.New("invalid level accepted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
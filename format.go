@@ -0,0 +1,83 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// formatBucket has the version of the on-disk format.
+const formatBucket = "__format"
+
+var formatKey = []byte("version")
+
+// FormatVersion is the version of the on-disk format written by this
+// package. Databases without a version are taken as version 1.
+const FormatVersion uint64 = 1
+
+const ErrFormat = "unsupported format version"
+
+// FormatMigrations has the functions that upgrade a database from the version
+// of the key to the next one.
+var FormatMigrations = map[uint64]func(tx *bolt.Tx) error{}
+
+// Format returns the format version of the database.
+func Format(tx *bolt.Tx) uint64 {
+	b := tx.Bucket([]byte(formatBucket))
+	if b == nil {
+		return 1
+	}
+	buf := b.Get(formatKey)
+	if len(buf) != 8 {
+		return 1
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+// CheckFormat fails with ErrFormat if the database isn't in FormatVersion.
+func CheckFormat(tx *bolt.Tx) error {
+	v := Format(tx)
+	if v > FormatVersion {
+		return e.New("%v: %v is newer than %v", ErrFormat, v, FormatVersion)
+	}
+	if v < FormatVersion {
+		return e.New("%v: %v must be upgraded to %v", ErrFormat, v, FormatVersion)
+	}
+	return nil
+}
+
+// UpgradeFormat runs the FormatMigrations needed to bring the database to
+// FormatVersion and records the version. It fails with ErrFormat if the
+// database is newer than this package or a migration is missing.
+func UpgradeFormat(tx *bolt.Tx) error {
+	v := Format(tx)
+	if v > FormatVersion {
+		return e.New("%v: %v is newer than %v", ErrFormat, v, FormatVersion)
+	}
+	for ; v < FormatVersion; v++ {
+		migrate, ok := FormatMigrations[v]
+		if !ok {
+			return e.New("%v: no migration from %v", ErrFormat, v)
+		}
+		err := migrate(tx)
+		if err != nil {
+			return e.Push(err, e.New("fail to upgrade the format from %v", v))
+		}
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(formatBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, FormatVersion)
+	err = b.Put(formatKey, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
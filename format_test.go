@@ -0,0 +1,93 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestFormat(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	path := filepath.Join(dir, filename)
+
+	db, err := Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(formatBucket)) == nil {
+			return e.New("format not written")
+		}
+		return CheckFormat(tx)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	setVersion := func(v uint64) {
+		err := db.Update(func(tx *bolt.Tx) error {
+			buf := make([]byte, 8)
+			binary.BigEndian.PutUint64(buf, v)
+			return tx.Bucket([]byte(formatBucket)).Put(formatKey, buf)
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	// A newer database is rejected.
+	setVersion(FormatVersion + 1)
+	db.Close()
+	_, err = Open(path, 0600, nil)
+	if !e.Contains(err, ErrFormat) {
+		t.Fatal("newer format accepted", err)
+	}
+
+	// An older one is upgraded.
+	db2, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	db = NewDB(db2)
+	setVersion(0)
+	db.Close()
+	_, err = Open(path, 0600, nil)
+	if !e.Contains(err, ErrFormat) {
+		t.Fatal("format without migration accepted", err)
+	}
+	migrated := false
+	FormatMigrations[0] = func(tx *bolt.Tx) error {
+		migrated = true
+		return nil
+	}
+	defer delete(FormatMigrations, 0)
+	db, err = Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+	if !migrated {
+		t.Fatal("migration not run")
+	}
+	err = db.View(CheckFormat)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
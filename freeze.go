@@ -0,0 +1,175 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const frozenBucket = "__frozen"
+
+const ErrFrozen = "subtree is frozen"
+
+// Freeze marks the subtree of bucket under prefix as immutable. Put, Del,
+// Move and MoveSubtree fail with ErrFrozen for every key path that starts
+// with prefix, CloneBucket and PromoteBucket if the tree they write or remove
+// has a frozen subtree. An empty prefix freezes the whole bucket.
+//
+// Rekey still encrypts the frozen leaves again, the values read don't change,
+// and RepairIndex still repairs the frozen subtrees.
+func Freeze(tx *bolt.Tx, bucket []byte, prefix [][]byte) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(frozenBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(encodeKeys(append([][]byte{bucket}, prefix...)...), []byte{})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// unfreezes counts the commits of the transactions that called Unfreeze. The
+// leaves of FrozenCache read before are checked again.
+var unfreezes uint64
+
+// Unfreeze removes the mark set by Freeze. The leaves of the subtree held by
+// the FrozenCaches are checked again after the commit.
+func Unfreeze(tx *bolt.Tx, bucket []byte, prefix [][]byte) error {
+	b := tx.Bucket([]byte(frozenBucket))
+	if b == nil {
+		return nil
+	}
+	tx.OnCommit(func() {
+		atomic.AddUint64(&unfreezes, 1)
+	})
+	err := b.Delete(encodeKeys(append([][]byte{bucket}, prefix...)...))
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(b) {
+		err = tx.DeleteBucket([]byte(frozenBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// IsFrozen reports if the key path lies inside a frozen subtree.
+func IsFrozen(tx *bolt.Tx, bucket []byte, keys [][]byte) bool {
+	return frozenLevel(tx, bucket, keys) >= 0
+}
+
+// frozenLevel returns the length of the frozen prefix of keys or -1.
+func frozenLevel(tx *bolt.Tx, bucket []byte, keys [][]byte) int {
+	b := tx.Bucket([]byte(frozenBucket))
+	if b == nil {
+		return -1
+	}
+	path := append([][]byte{bucket}, keys...)
+	for i := 1; i <= len(path); i++ {
+		if b.Get(encodeKeys(path[:i]...)) != nil {
+			return i - 1
+		}
+	}
+	return -1
+}
+
+func checkFrozen(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
+	if level := frozenLevel(tx, bucket, keys); level >= 0 {
+		return newKeyError(ErrFrozen, bucket, level, keys)
+	}
+	return nil
+}
+
+// checkFrozenTree returns ErrFrozen if prefix is in a frozen subtree or has
+// one under it.
+func checkFrozenTree(tx *bolt.Tx, bucket []byte, prefix [][]byte) error {
+	err := checkFrozen(tx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket([]byte(frozenBucket))
+	if b == nil {
+		return nil
+	}
+	p := encodeKeys(append([][]byte{bucket}, prefix...)...)
+	k, _ := b.Cursor().Seek(p)
+	if k == nil || !bytes.HasPrefix(k, p) {
+		return nil
+	}
+	path, err := decodeKeys(k)
+	if err != nil || len(path) < 1 {
+		return newKeyError(ErrInvEncoding, []byte(frozenBucket), -1, nil)
+	}
+	return newKeyError(ErrFrozen, bucket, len(path)-1, path[1:])
+}
+
+// FrozenCache keeps in memory the leaves read from frozen subtrees. Frozen
+// leaves never change so the lookups don't need any lock. The leaves are
+// kept by database, one cache can serve many.
+type FrozenCache struct {
+	m sync.Map
+}
+
+// frozenKey is the key of a leaf in FrozenCache.
+type frozenKey struct {
+	db   *bolt.DB
+	path string
+}
+
+// frozenLeaf is a leaf in FrozenCache, read when unfreezes was gen.
+type frozenLeaf struct {
+	v   []byte
+	gen uint64
+}
+
+func NewFrozenCache() *FrozenCache {
+	return &FrozenCache{}
+}
+
+// Get returns the leaf like the Get function. Leaves of frozen subtrees are
+// served from memory after the first read. The value returned is a copy, the
+// caller may change it.
+func (fc *FrozenCache) Get(tx *bolt.Tx, bucket []byte, keys [][]byte) ([]byte, error) {
+	id := frozenKey{
+		db:   tx.DB(),
+		path: string(encodeKeys(append([][]byte{bucket}, keys...)...)),
+	}
+	gen := atomic.LoadUint64(&unfreezes)
+	if l, found := fc.m.Load(id); found {
+		leaf := l.(frozenLeaf)
+		if leaf.gen == gen {
+			return append([]byte{}, leaf.v...), nil
+		}
+		// Some subtree was unfrozen since the leaf was read.
+		fc.m.Delete(id)
+	}
+	buf, err := Get(tx, bucket, keys)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	if !IsFrozen(tx, bucket, keys) {
+		return buf, nil
+	}
+	v := make([]byte, len(buf))
+	copy(v, buf)
+	fc.m.Store(id, frozenLeaf{v: v, gen: gen})
+	return append([]byte{}, v...), nil
+}
+
+// Reset drops all cached leaves.
+func (fc *FrozenCache) Reset() {
+	fc.m.Range(func(k, v interface{}) bool {
+		fc.m.Delete(k)
+		return true
+	})
+}
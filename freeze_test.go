@@ -0,0 +1,199 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestFreeze(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return Freeze(tx, []byte("test_bucket"), [][]byte{[]byte("2014")})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, data[0].Bucket, data[0].Keys, []byte("x"))
+		if !IsError(err, ErrFrozen) {
+			return e.New("put in a frozen subtree: %v", err)
+		}
+		err = Del(tx, data[1].Bucket, data[1].Keys)
+		if !IsError(err, ErrFrozen) {
+			return e.New("del in a frozen subtree: %v", err)
+		}
+		err = Put(tx, data[2].Bucket, data[2].Keys, []byte("x"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	fc := NewFrozenCache()
+	for i := 0; i < 2; i++ {
+		err = db.View(func(tx *bolt.Tx) error {
+			for j, d := range data[:2] {
+				v, err := fc.Get(tx, d.Bucket, d.Keys)
+				if err != nil {
+					return e.Forward(err)
+				}
+				if !bytes.Equal(v, d.Data) {
+					return e.New("not equal %v %v", j, string(v))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Unfreeze(tx, []byte("test_bucket"), [][]byte{[]byte("2014")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Put(tx, data[0].Bucket, data[0].Keys, []byte("x"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The cache doesn't return the leaves read before Unfreeze.
+	err = db.View(func(tx *bolt.Tx) error {
+		v, err := fc.Get(tx, data[0].Bucket, data[0].Keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "x" {
+			return e.New("stale leaf after unfreeze: %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestFreezeTrees(t *testing.T) {
+	bucket := []byte("test_bucket")
+	db := openTestDB(t)
+	defer db.Close()
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, b := range []string{"test_bucket", "branch"} {
+			err := Put(tx, []byte(b), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		err := Freeze(tx, bucket, [][]byte{[]byte("2014"), []byte("01")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Freeze(tx, []byte("clone"), nil)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := CloneBucket(tx, bucket, []byte("clone"))
+		if !IsError(err, ErrFrozen) {
+			return e.New("clone into a frozen bucket: %v", err)
+		}
+		err = PromoteBucket(tx, []byte("branch"), bucket)
+		if !IsError(err, ErrFrozen) {
+			return e.New("promote over a frozen subtree: %v", err)
+		}
+		err = MoveSubtree(tx, bucket, [][]byte{[]byte("2014")}, [][]byte{[]byte("2015")})
+		if !IsError(err, ErrFrozen) {
+			return e.New("move of a frozen subtree: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestFrozenCacheCopy(t *testing.T) {
+	bucket := []byte("test_bucket")
+	keys := [][]byte{[]byte("a"), []byte("b")}
+	db1 := openTestDB(t)
+	defer db1.Close()
+	db2 := openTestDB(t)
+	defer db2.Close()
+	for i, db := range []*bolt.DB{db1, db2} {
+		err := db.Update(func(tx *bolt.Tx) error {
+			err := Put(tx, bucket, keys, []byte{byte('1' + i)})
+			if err != nil {
+				return e.Forward(err)
+			}
+			return Freeze(tx, bucket, keys[:1])
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	fc := NewFrozenCache()
+	for i := 0; i < 2; i++ {
+		for j, db := range []*bolt.DB{db1, db2} {
+			err := db.View(func(tx *bolt.Tx) error {
+				v, err := fc.Get(tx, bucket, keys)
+				if err != nil {
+					return e.Forward(err)
+				}
+				if string(v) != string([]byte{byte('1' + j)}) {
+					return e.New("wrong value of the database %v: %q", j, v)
+				}
+				// The cached leaf doesn't change with the value returned.
+				v[0] = 'x'
+				return nil
+			})
+			if err != nil {
+				t.Fatal(e.Trace(e.Forward(err)))
+			}
+		}
+	}
+}
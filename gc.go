@@ -0,0 +1,108 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// Verify returns the names of the intermediate buckets that can't be reached
+// from the trees of roots. Only the buckets named like intermediate buckets,
+// by an UUID or by Compact, are considered, so the other top buckets are
+// never orphans. The trees of every other top bucket, like the ones of the
+// package's own metadata and of an interrupted migration, are reachable too,
+// also if they aren't in roots.
+func Verify(db *bolt.DB, roots [][]byte) ([][]byte, error) {
+	var orphans [][]byte
+	err := db.View(func(tx *bolt.Tx) error {
+		reachable := make(map[string]bool)
+		for _, root := range roots {
+			b := tx.Bucket(root)
+			if b == nil {
+				return newKeyError(ErrInvBucket, root, -1, nil)
+			}
+			mark(tx, b, reachable)
+		}
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if !isUUID(name) && !isCompactID(name) {
+				mark(tx, b, reachable)
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if (isUUID(name) || isCompactID(name)) && !reachable[string(name)] {
+				orphans = append(orphans, append([]byte{}, name...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return orphans, nil
+}
+
+// mark adds to reachable the intermediate buckets under b.
+func mark(tx *bolt.Tx, b *bolt.Bucket, reachable map[string]bool) {
+	c := b.Cursor()
+	for _, v := c.First(); v != nil; _, v = c.Next() {
+		if reachable[string(v)] {
+			continue
+		}
+		sub := subBucket(tx, v)
+		if sub == nil {
+			continue
+		}
+		reachable[string(v)] = true
+		mark(tx, sub, reachable)
+	}
+}
+
+// GC deletes the orphan buckets found by Verify, in transactions of
+// WipeChunk buckets, and returns how many were deleted. The trees of the top
+// buckets are kept, see Verify, so only the buckets left by interrupted
+// deletions and by top buckets dropped without their trees are deleted. If GC stops before the end the rest of the orphans are left, call
+// it again to delete them.
+func GC(db *bolt.DB, roots [][]byte) (int, error) {
+	orphans, err := Verify(db, roots)
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	removed := 0
+	for len(orphans) > 0 {
+		n := WipeChunk
+		if n > len(orphans) {
+			n = len(orphans)
+		}
+		deleted := 0
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, name := range orphans[:n] {
+				if tx.Bucket(name) == nil {
+					continue
+				}
+				err := tx.DeleteBucket(name)
+				if err != nil {
+					return e.Forward(err)
+				}
+				err = forgetCounts(tx, name)
+				if err != nil {
+					return e.Forward(err)
+				}
+				deleted++
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, e.Forward(err)
+		}
+		removed += deleted
+		orphans = orphans[n:]
+	}
+	return removed, nil
+}
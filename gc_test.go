@@ -0,0 +1,156 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestGC(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("b")}, []byte("2")},
+		{[]byte("dropped"), [][]byte{[]byte("x"), []byte("y")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		// An interrupted delete: the key is gone but not its buckets.
+		b, err := prefixBucket(tx, []byte("test_bucket"), nil)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = b.Delete([]byte("2015"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// A top bucket dropped without its tree.
+		return tx.DeleteBucket([]byte("dropped"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	roots := [][]byte{[]byte("test_bucket")}
+	orphans, err := Verify(db, roots)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// 2015, 2015/01 and x.
+	if len(orphans) != 3 {
+		t.Fatal("wrong number of orphans", len(orphans))
+	}
+
+	old := WipeChunk
+	WipeChunk = 2
+	defer func() {
+		WipeChunk = old
+	}()
+
+	n, err := GC(db, roots)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 3 {
+		t.Fatal("wrong number of removed buckets", n)
+	}
+	orphans, err = Verify(db, roots)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(orphans) != 0 {
+		t.Fatal("orphans left", len(orphans))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		_, err := Get(tx, data[0].Bucket, data[0].Keys)
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	_, err = GC(db, [][]byte{[]byte("no_bucket")})
+	if !IsError(err, ErrInvBucket) {
+		t.Fatal("expected ErrInvBucket", err)
+	}
+}
+
+func TestGCPackageTrees(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	now := time.Now()
+	keys := [][]byte{[]byte("user"), []byte("post")}
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, []byte("posts"), [][]byte{[]byte("a"), []byte("1")}, []byte("1"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// The copy left by an interrupted migration.
+		err = Put(tx, []byte(migratePrefix+"posts"), [][]byte{[]byte("1"), []byte("a")}, []byte("1"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		ok, err := Allow(tx, keys, 0.001, 1, now)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !ok {
+			return e.New("first request denied")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	n, err := GC(db, [][]byte{[]byte("posts")})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 0 {
+		t.Fatal("buckets of the package deleted", n)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		ok, err := Allow(tx, keys, 0.001, 1, now)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if ok {
+			return e.New("limit reset by GC")
+		}
+		_, err = Get(tx, []byte(migratePrefix+"posts"), [][]byte{[]byte("1"), []byte("a")})
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"math"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// KV is a leaf with its full key path.
+type KV struct {
+	Keys  [][]byte
+	Value []byte
+}
+
+// GetAll returns the leaves under the partial key path prefix in key order.
+// At most limit leaves are returned, a limit of zero or less returns all. The
+// keys and values are copied, so they remain valid after the transaction.
+func GetAll(tx *bolt.Tx, bucket []byte, prefix [][]byte, limit int) ([]KV, error) {
+	b, err := prefixBucket(tx, bucket, prefix)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	var items []Item
+	err = leavesAfter(tx, b, copyKeys(prefix), nil, limit, &items)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	err = decodeItems(bucket, items)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	kvs := make([]KV, len(items))
+	for i, item := range items {
+		kvs[i] = KV{Keys: item.Keys, Value: item.Data}
+	}
+	return kvs, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestGetAll(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("c")}, []byte("3")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("d")}, []byte("4")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var kvs []KV
+	err = db.View(func(tx *bolt.Tx) error {
+		var err error
+		kvs, err = GetAll(tx, []byte("test_bucket"), [][]byte{[]byte("2014")}, 0)
+		if err != nil {
+			return e.Forward(err)
+		}
+		two, err := GetAll(tx, []byte("test_bucket"), nil, 2)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(two) != 2 || !bytes.Equal(two[1].Value, []byte("2")) {
+			return e.New("wrong limited result %v", len(two))
+		}
+		_, err = GetAll(tx, []byte("test_bucket"), [][]byte{[]byte("2016")}, 0)
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("expected ErrKeyNotFound: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(kvs) != 3 {
+		t.Fatal("wrong number of entries", len(kvs))
+	}
+	for i, kv := range kvs {
+		if compareKeys(kv.Keys, data[i].Keys) != 0 || !bytes.Equal(kv.Value, data[i].Data) {
+			t.Fatal("wrong entry", i, string(kv.Value))
+		}
+	}
+}
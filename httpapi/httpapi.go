@@ -0,0 +1,310 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package httpapi serves the trees of a database over HTTP. The URL path is
+// the bucket followed by the key path, each part escaped like a path segment:
+//
+//	GET    /posts/en/2015/hello     the value of the leaf
+//	PUT    /posts/en/2015/hello     writes the body as the value
+//	DELETE /posts/en/2015/hello     deletes the leaf, or the subtree
+//	GET    /posts/en?list=1         the leaves under /en, in JSON
+//
+// The list accepts limit, offset and reverse=1. It returns a page of entries
+// encoded like Export, with the offset of the next page if there is one:
+//
+//	{"entries":[{"keys":["ZW4=","MjAxNQ==","aGVsbG8="],"value":"..."}],"next":100}
+package httpapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+// Config configures the handler of NewHandler.
+type Config struct {
+	// ReadOnly rejects PUT and DELETE.
+	ReadOnly bool
+	// Buckets are the buckets served, all if empty.
+	Buckets []string
+	// NumKeys are the number of levels of the buckets listed that aren't
+	// registered, see boltdbutils.Register.
+	NumKeys map[string]int
+	// MaxValue is the maximum size of the values written by PUT, 1MiB if
+	// zero.
+	MaxValue int64
+	// Limit is the number of entries of a list without limit, 100 if zero.
+	Limit uint64
+	// MaxLimit is the maximum limit of a list, 1000 if zero.
+	MaxLimit uint64
+	// ContentType is the type of the values returned by GET,
+	// application/octet-stream if empty.
+	ContentType string
+}
+
+// List is the response of a list.
+type List struct {
+	Entries []boltdbutils.ExportEntry `json:"entries"`
+	// Next is the offset of the next page, zero if there is none.
+	Next uint64 `json:"next,omitempty"`
+}
+
+type handler struct {
+	db      *bolt.DB
+	cfg     Config
+	buckets map[string]bool
+}
+
+// NewHandler returns a handler that serves the trees of db.
+func NewHandler(db *bolt.DB, cfg Config) http.Handler {
+	if cfg.MaxValue == 0 {
+		cfg.MaxValue = 1 << 20
+	}
+	if cfg.Limit == 0 {
+		cfg.Limit = 100
+	}
+	if cfg.MaxLimit == 0 {
+		cfg.MaxLimit = 1000
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/octet-stream"
+	}
+	h := &handler{
+		db:  db,
+		cfg: cfg,
+	}
+	if len(cfg.Buckets) > 0 {
+		h.buckets = make(map[string]bool, len(cfg.Buckets))
+		for _, b := range cfg.Buckets {
+			h.buckets[b] = true
+		}
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, keys, err := splitPath(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if bucket == nil || (h.buckets != nil && !h.buckets[string(bucket)]) {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if r.URL.Query().Get("list") != "" {
+			h.list(w, r, bucket, keys)
+			return
+		}
+		h.get(w, r, bucket, keys)
+	case http.MethodPut:
+		h.put(w, r, bucket, keys)
+	case http.MethodDelete:
+		h.del(w, r, bucket, keys)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// splitPath returns the bucket and the key path of u.
+func splitPath(u *url.URL) ([]byte, [][]byte, error) {
+	p := strings.Trim(u.EscapedPath(), "/")
+	if p == "" {
+		return nil, nil, nil
+	}
+	parts := strings.Split(p, "/")
+	keys := make([][]byte, len(parts))
+	for i, part := range parts {
+		s, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, nil, e.Forward(err)
+		}
+		keys[i] = []byte(s)
+	}
+	return keys[0], keys[1:], nil
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request, bucket []byte, keys [][]byte) {
+	if len(keys) == 0 {
+		httpError(w, e.New(boltdbutils.ErrNoKeys))
+		return
+	}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		v, err := boltdbutils.Get(tx, bucket, keys)
+		if err != nil {
+			return err
+		}
+		// Get returns the name of the intermediate bucket of a partial
+		// key path, and it has leaves under it.
+		if _, err := boltdbutils.GetAll(tx, bucket, keys, 1); err == nil {
+			return e.New(boltdbutils.ErrNotLeaf)
+		}
+		etag, err := boltdbutils.ETag(tx, bucket, keys)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", h.cfg.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(v)))
+		if r.Method != http.MethodHead {
+			w.Write(v)
+		}
+		return nil
+	})
+	if err != nil {
+		httpError(w, err)
+	}
+}
+
+func (h *handler) put(w http.ResponseWriter, r *http.Request, bucket []byte, keys [][]byte) {
+	if h.cfg.ReadOnly {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	v, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, h.cfg.MaxValue))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	err = h.db.Update(func(tx *bolt.Tx) error {
+		return boltdbutils.Put(tx, bucket, keys, v)
+	})
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) del(w http.ResponseWriter, r *http.Request, bucket []byte, keys [][]byte) {
+	if h.cfg.ReadOnly {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		return boltdbutils.Del(tx, bucket, keys)
+	})
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) list(w http.ResponseWriter, r *http.Request, bucket []byte, keys [][]byte) {
+	q := r.URL.Query()
+	limit, err := uintParam(q, "limit", h.cfg.Limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit > h.cfg.MaxLimit {
+		limit = h.cfg.MaxLimit
+	}
+	offset, err := uintParam(q, "offset", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var list List
+	err = h.db.View(func(tx *bolt.Tx) error {
+		numKeys, err := h.numKeys(tx, bucket)
+		if err != nil {
+			return err
+		}
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  bucket,
+			NumKeys: numKeys,
+			Reverse: q.Get("reverse") != "",
+		}
+		err = c.Init(keys...)
+		if err != nil {
+			return err
+		}
+		// One more to know if there is a next page.
+		kvs, err := c.Page(offset, limit+1)
+		if err != nil {
+			return err
+		}
+		if uint64(len(kvs)) > limit {
+			kvs = kvs[:limit]
+			list.Next = offset + limit
+		}
+		list.Entries = make([]boltdbutils.ExportEntry, len(kvs))
+		for i, kv := range kvs {
+			list.Entries[i] = boltdbutils.ExportEntry{Keys: kv.Keys, Value: kv.Value}
+		}
+		return nil
+	})
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// numKeys returns the number of levels of bucket, from the registry or the
+// configuration.
+func (h *handler) numKeys(tx *bolt.Tx, bucket []byte) (int, error) {
+	info, err := boltdbutils.Lookup(tx, bucket)
+	if err == nil && info.NumKeys > 0 {
+		return info.NumKeys, nil
+	}
+	if n := h.cfg.NumKeys[string(bucket)]; n > 0 {
+		return n, nil
+	}
+	return 0, e.New(boltdbutils.ErrNotRegistered)
+}
+
+func uintParam(q url.Values, name string, def uint64) (uint64, error) {
+	s := q.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, e.New("invalid %v", name)
+	}
+	return n, nil
+}
+
+// statuses maps the errors of the package to the HTTP status.
+var statuses = []struct {
+	kind   string
+	status int
+}{
+	{boltdbutils.ErrKeyNotFound, http.StatusNotFound},
+	{boltdbutils.ErrInvBucket, http.StatusNotFound},
+	{boltdbutils.ErrExpired, http.StatusNotFound},
+	{boltdbutils.ErrNotLeaf, http.StatusNotFound},
+	{boltdbutils.ErrNoKeys, http.StatusBadRequest},
+	{boltdbutils.ErrArity, http.StatusBadRequest},
+	{boltdbutils.ErrNotRegistered, http.StatusBadRequest},
+	{boltdbutils.ErrFrozen, http.StatusConflict},
+	{boltdbutils.ErrQuotaExceeded, http.StatusInsufficientStorage},
+}
+
+// httpError writes the status of err. The body has the Err constant, not the
+// key path, or only the status text for the internal errors.
+func httpError(w http.ResponseWriter, err error) {
+	for _, s := range statuses {
+		if boltdbutils.IsError(err, s.kind) {
+			http.Error(w, s.kind, s.status)
+			return
+		}
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
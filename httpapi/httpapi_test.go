@@ -0,0 +1,131 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package httpapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+func openDB(t *testing.T) *bolt.DB {
+	dir, err := ioutil.TempDir("", "httpapi-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	return db
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string) (int, string) {
+	var r *http.Request
+	if body != "" {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	h := NewHandler(db, Config{
+		Buckets: []string{"posts"},
+		NumKeys: map[string]int{"posts": 3},
+		Limit:   2,
+	})
+	for _, p := range []string{"/en/2015/a", "/en/2015/b", "/en/2016/c", "/pt/2015/d", "/en/2015/a%2Fb"} {
+		code, body := do(t, h, "PUT", "/posts"+p, "value "+p)
+		if code != http.StatusNoContent {
+			t.Fatalf("PUT %v: %v %v", p, code, body)
+		}
+	}
+
+	tests := []struct {
+		method, target string
+		code           int
+		body           string
+	}{
+		{"GET", "/posts/en/2015/a", 200, "value /en/2015/a"},
+		{"GET", "/posts/en/2015/a%2Fb", 200, "value /en/2015/a%2Fb"},
+		{"GET", "/posts/en/2015/x", 404, boltdbutils.ErrKeyNotFound + "\n"},
+		{"GET", "/posts/en/2015", 404, boltdbutils.ErrNotLeaf + "\n"},
+		{"GET", "/posts", 400, boltdbutils.ErrNoKeys + "\n"},
+		{"GET", "/other/a", 404, "404 page not found\n"},
+		{"POST", "/posts/a", 405, "Method Not Allowed\n"},
+		{"GET", "/posts/en/2015/a/b?list=1", 400, boltdbutils.ErrArity + "\n"},
+	}
+	for _, test := range tests {
+		code, body := do(t, h, test.method, test.target, "")
+		if code != test.code || body != test.body {
+			t.Fatalf("%v %v: %v %q, want %v %q", test.method, test.target, code, body, test.code, test.body)
+		}
+	}
+
+	list := func(target string) ([]string, uint64) {
+		code, body := do(t, h, "GET", target, "")
+		if code != 200 {
+			t.Fatalf("GET %v: %v %v", target, code, body)
+		}
+		var l List
+		err := json.Unmarshal([]byte(body), &l)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		var paths []string
+		for _, entry := range l.Entries {
+			paths = append(paths, boltdbutils.Path(entry.Keys).String())
+		}
+		return paths, l.Next
+	}
+	paths, next := list("/posts/en?list=1")
+	if strings.Join(paths, " ") != "/en/2015/a /en/2015/a%2Fb" || next != 2 {
+		t.Fatalf("wrong first page: %v %v", paths, next)
+	}
+	paths, next = list("/posts/en?list=1&offset=2")
+	if strings.Join(paths, " ") != "/en/2015/b /en/2016/c" || next != 0 {
+		t.Fatalf("wrong last page: %v %v", paths, next)
+	}
+	paths, _ = list("/posts?list=1&reverse=1&limit=1")
+	if strings.Join(paths, " ") != "/pt/2015/d" {
+		t.Fatalf("wrong reverse page: %v", paths)
+	}
+
+	code, body := do(t, h, "DELETE", "/posts/en/2015", "")
+	if code != http.StatusNoContent {
+		t.Fatalf("DELETE: %v %v", code, body)
+	}
+	paths, _ = list("/posts?list=1&limit=10")
+	if strings.Join(paths, " ") != "/en/2016/c /pt/2015/d" {
+		t.Fatalf("wrong list after DELETE: %v", paths)
+	}
+
+	ro := NewHandler(db, Config{ReadOnly: true, MaxValue: 4})
+	if code, _ := do(t, ro, "PUT", "/posts/en/2016/c", "x"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT in read only: %v", code)
+	}
+	if code, _ := do(t, ro, "GET", "/posts?list=1", ""); code != http.StatusBadRequest {
+		t.Fatalf("list without NumKeys: %v", code)
+	}
+	big := NewHandler(db, Config{MaxValue: 4})
+	if code, _ := do(t, big, "PUT", "/posts/en/2016/c", "too long"); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("PUT too large: %v", code)
+	}
+}
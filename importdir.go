@@ -0,0 +1,152 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"gopkg.in/yaml.v2"
+)
+
+const ErrInvPost = "invalid post"
+
+// Post is a Markdown file read by ImportDir.
+type Post struct {
+	// Path is the path of the file relative to the directory.
+	Path string
+	// Meta is the YAML front matter.
+	Meta map[string]interface{}
+	// Body is the Markdown after the front matter.
+	Body []byte
+	// Raw is the whole file.
+	Raw []byte
+}
+
+// PostMapper returns the key path and the value of a post. A nil key path
+// skips the post.
+type PostMapper func(p *Post) (keys [][]byte, data []byte, err error)
+
+// DatePostMapper puts the post in the key path year, month, day and title,
+// taken from the date and the title of the front matter. The value is the
+// whole file. The month and the day have two digits so they sort in order.
+func DatePostMapper(p *Post) ([][]byte, []byte, error) {
+	title, _ := p.Meta["title"].(string)
+	if title == "" {
+		return nil, nil, e.New("%v: %v has no title", ErrInvPost, p.Path)
+	}
+	var date time.Time
+	switch d := p.Meta["date"].(type) {
+	case time.Time:
+		date = d
+	case string:
+		var err error
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			date, err = time.Parse(layout, d)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, nil, e.New("%v: %v has an invalid date", ErrInvPost, p.Path)
+		}
+	default:
+		return nil, nil, e.New("%v: %v has no date", ErrInvPost, p.Path)
+	}
+	keys := [][]byte{
+		[]byte(fmt.Sprintf("%04d", date.Year())),
+		[]byte(fmt.Sprintf("%02d", date.Month())),
+		[]byte(fmt.Sprintf("%02d", date.Day())),
+		[]byte(title),
+	}
+	return keys, p.Raw, nil
+}
+
+// ImportDir puts in bucket every Markdown file, .md or .markdown, under dir
+// at the key path returned by mapper. A nil mapper is DatePostMapper. It
+// returns the number of posts imported.
+func ImportDir(tx *bolt.Tx, bucket []byte, dir string, mapper PostMapper) (int, error) {
+	if mapper == nil {
+		mapper = DatePostMapper
+	}
+	n := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return e.Forward(err)
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if info.IsDir() || (ext != ".md" && ext != ".markdown") {
+			return nil
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return e.Forward(err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return e.Forward(err)
+		}
+		p, err := parsePost(rel, raw)
+		if err != nil {
+			return e.Forward(err)
+		}
+		keys, data, err := mapper(p)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if keys == nil {
+			return nil
+		}
+		err = Put(tx, bucket, keys, data)
+		if err != nil {
+			return e.Forward(err)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, e.Forward(err)
+	}
+	return n, nil
+}
+
+// parsePost splits the YAML front matter, between two lines with ---, from
+// the body.
+func parsePost(path string, raw []byte) (*Post, error) {
+	p := &Post{
+		Path: path,
+		Meta: make(map[string]interface{}),
+		Body: raw,
+		Raw:  raw,
+	}
+	text := bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1)
+	if !bytes.HasPrefix(text, []byte("---\n")) {
+		return p, nil
+	}
+	rest := text[4:]
+	end := bytes.Index(rest, []byte("\n---"))
+	if end < 0 {
+		return nil, e.New("%v: %v has an unterminated front matter", ErrInvPost, path)
+	}
+	err := yaml.Unmarshal(rest[:end], &p.Meta)
+	if err != nil {
+		return nil, e.Push(err, e.New("%v: %v has an invalid front matter", ErrInvPost, path))
+	}
+	body := rest[end+4:]
+	if i := bytes.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = nil
+	}
+	p.Body = body
+	return p, nil
+}
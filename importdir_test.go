@@ -0,0 +1,100 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestImportDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	posts := map[string]string{
+		"2015/hello.md":    "---\ntitle: Hello\ndate: 2015-01-02\ntags: [a, b]\n---\n# Hello\n",
+		"2015/world.md":    "---\r\ntitle: World\r\ndate: 2015-03-04T10:00:00Z\r\n---\r\nWorld\r\n",
+		"2015/draft.md":    "---\ntitle: Draft\ndate: 2015-05-06\ndraft: true\n---\nDraft\n",
+		"2015/notes.txt":   "not a post",
+		"old/empty.md":     "---\ntitle: Empty\ndate: 2014-12-31\n---",
+		"old/no-matter.md": "# no front matter\n",
+	}
+	for name, content := range posts {
+		path := filepath.Join(dir, "posts", name)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// Without a title the default mapper fails.
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := ImportDir(tx, []byte("blog"), filepath.Join(dir, "posts"), nil)
+		return err
+	})
+	if !IsError(err, ErrInvPost) {
+		t.Fatal("expected ErrInvPost", err)
+	}
+
+	var bodies = make(map[string][]byte)
+	err = db.Update(func(tx *bolt.Tx) error {
+		n, err := ImportDir(tx, []byte("blog"), filepath.Join(dir, "posts"), func(p *Post) ([][]byte, []byte, error) {
+			if p.Meta["title"] == nil || p.Meta["draft"] == true {
+				return nil, nil, nil
+			}
+			bodies[p.Path] = p.Body
+			return DatePostMapper(p)
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 3 {
+			return e.New("wrong number of posts %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if string(bodies[filepath.Join("2015", "hello.md")]) != "# Hello\n" || len(bodies[filepath.Join("old", "empty.md")]) != 0 {
+		t.Fatal("wrong bodies", bodies)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		v, err := Get(tx, []byte("blog"), [][]byte{[]byte("2015"), []byte("03"), []byte("04"), []byte("World")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, []byte(posts["2015/world.md"])) {
+			return e.New("wrong value %v", string(v))
+		}
+		_, err = Get(tx, []byte("blog"), [][]byte{[]byte("2014"), []byte("12"), []byte("31"), []byte("Empty")})
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
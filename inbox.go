@@ -0,0 +1,96 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const inboxBucket = "__inbox"
+
+// Inbox records the ids of the consumed messages so each message changes the
+// database at most once. The records are kept for TTL, after that Prune
+// forgets them.
+type Inbox struct {
+	DB  *bolt.DB
+	TTL time.Duration
+}
+
+// Process runs fn in a writable transaction unless the message id was
+// already processed. The id is recorded in the same transaction as the
+// changes made by fn. It returns false if the message was a duplicate.
+func (in *Inbox) Process(id string, fn func(tx *bolt.Tx) error) (bool, error) {
+	var done bool
+	err := in.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		done, err = ProcessOnce(tx, id, fn)
+		return err
+	})
+	if err != nil {
+		return false, e.Forward(err)
+	}
+	return done, nil
+}
+
+// ProcessOnce is like Inbox.Process but runs inside the caller transaction.
+func ProcessOnce(tx *bolt.Tx, id string, fn func(tx *bolt.Tx) error) (bool, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(inboxBucket))
+	if err != nil {
+		return false, e.Forward(err)
+	}
+	if b.Get([]byte(id)) != nil {
+		return false, nil
+	}
+	err = fn(tx)
+	if err != nil {
+		return false, e.Forward(err)
+	}
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(time.Now().UnixNano()))
+	err = b.Put([]byte(id), ts)
+	if err != nil {
+		return false, e.Forward(err)
+	}
+	return true, nil
+}
+
+// Prune removes the records older than TTL and returns how many were
+// removed.
+func (in *Inbox) Prune(now time.Time) (int, error) {
+	n := 0
+	err := in.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(inboxBucket))
+		if b == nil {
+			return nil
+		}
+		limit := now.Add(-in.TTL).UnixNano()
+		var del [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if len(v) == 8 && int64(binary.BigEndian.Uint64(v)) < limit {
+				del = append(del, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		for _, k := range del {
+			err = b.Delete(k)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		n = len(del)
+		return nil
+	})
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	return n, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestInbox(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	in := &Inbox{DB: db, TTL: time.Hour}
+	count := 0
+	incr := func(tx *bolt.Tx) error {
+		count++
+		return Put(tx, []byte("test_bucket"), [][]byte{[]byte("counter")}, []byte{byte(count)})
+	}
+	fail := func(tx *bolt.Tx) error {
+		return e.New("fail")
+	}
+
+	// A failed processing doesn't record the id.
+	_, err = in.Process("msg2", fail)
+	if err == nil {
+		t.Fatal("error not returned")
+	}
+	for _, id := range []string{"msg1", "msg1", "msg2", "msg1", "msg2"} {
+		_, err = in.Process(id, incr)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	if count != 2 {
+		t.Fatal("messages processed more than once", count)
+	}
+
+	n, err := in.Prune(time.Now())
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 0 {
+		t.Fatal("pruned too early", n)
+	}
+	n, err = in.Prune(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 2 {
+		t.Fatal("wrong number of pruned records", n)
+	}
+	done, err := in.Process("msg1", incr)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if !done || count != 3 {
+		t.Fatal("pruned message not processed")
+	}
+}
@@ -22,92 +22,268 @@ import (
 
 func Put(tx *bolt.Tx, bucket []byte, keys [][]byte, data []byte) error {
 	var err error
-	var buf []byte
 	var b *bolt.Bucket
 	b, err = tx.CreateBucketIfNotExists(bucket)
 	if err != nil {
 		return e.Forward(err)
 	}
-	if len(keys) == 0 {
-		return e.New("no keys")
+	info, err := writeInfo(tx, bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	buf, err := prepareLeaf(tx, bucket, &info, keys, data)
+	if err != nil {
+		return e.Forward(err)
 	}
 	if len(keys) >= 2 {
 		for i := 0; i < len(keys)-1; i++ {
-			buf = b.Get(keys[i])
-			if buf == nil {
-				id, err := rand.Uuid()
-				if err != nil {
-					return e.Forward(err)
-				}
-				buf = []byte(id)
-				err = b.Put(keys[i], buf)
-				if err != nil {
-					return e.Forward(err)
-				}
-			}
-			b, err = tx.CreateBucket(buf)
-			if e.Contains(err, "bucket already exists") {
-				b = tx.Bucket(buf)
-			} else if err != nil {
+			b, err = child(tx, bucket, b, keys[i])
+			if err != nil {
 				return e.Forward(err)
 			}
 		}
 	}
-	err = b.Put(keys[len(keys)-1], data)
+	err = writeLeaf(tx, bucket, b, keys, data, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// prepareLeaf checks the write of data in the leaf keys of bucket and returns
+// data encoded to be stored. info is the description of bucket read by
+// writeInfo. The quota is charged with the value as stored. Put, PutBatch,
+// CopySubtree, Compact and Restore write the leaves with prepareLeaf and
+// writeLeaf. CloneBucket and PromoteBucket don't, they copy the values as
+// stored.
+func prepareLeaf(tx *bolt.Tx, bucket []byte, info *BucketInfo, keys [][]byte, data []byte) ([]byte, error) {
+	err := checkLeaf(tx, bucket, info, keys)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	buf, err := encodeValue(bucket, info, keys, data)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	err = quotaPut(tx, bucket, keys, buf)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+// checkLeaf checks if the leaf keys of bucket, described by info, can be
+// written.
+func checkLeaf(tx *bolt.Tx, bucket []byte, info *BucketInfo, keys [][]byte) error {
+	if len(keys) == 0 {
+		return newKeyError(ErrNoKeys, bucket, -1, nil)
+	}
+	err := checkArity(info, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = checkFrozen(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// writeLeaf stores buf, data encoded by prepareLeaf, in the last key of keys
+// in b, the bucket of its level, and updates the counts, the expiration
+// time, the ETag, the changelog and the watchers.
+func writeLeaf(tx *bolt.Tx, bucket []byte, b *bolt.Bucket, keys [][]byte, data, buf []byte) error {
+	err := countPut(tx, bucket, keys, b)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(keys[len(keys)-1], buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = clearTTL(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = putETag(tx, bucket, keys, data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = logChange(tx, EventPut, bucket, keys, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	notify(tx, EventPut, bucket, keys, data)
+	return nil
+}
+
+const ErrDuplicateKey = "duplicate key"
+
+// PutUnique is like Put but fails with ErrDuplicateKey if the key path
+// already exists instead of overwriting it.
+func PutUnique(tx *bolt.Tx, bucket []byte, keys [][]byte, data []byte) error {
+	_, err := Get(tx, bucket, keys)
+	if err == nil {
+		return newKeyError(ErrDuplicateKey, bucket, len(keys)-1, keys)
+	}
+	if !IsError(err, ErrKeyNotFound) && !IsError(err, ErrInvBucket) {
+		return e.Forward(err)
+	}
+	err = Put(tx, bucket, keys, data)
 	if err != nil {
 		return e.Forward(err)
 	}
 	return nil
 }
 
+// child returns the bucket of the next level pointed by key, creating it if
+// it doesn't exist. bucket is the top bucket of the tree.
+func child(tx *bolt.Tx, bucket []byte, b *bolt.Bucket, key []byte) (*bolt.Bucket, error) {
+	return childID(tx, bucket, b, key, uuidID)
+}
+
+// uuidID names the new intermediate buckets.
+func uuidID(tx *bolt.Tx) ([]byte, error) {
+	id, err := rand.Uuid()
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return []byte(id), nil
+}
+
+// childID is child with newID naming the intermediate bucket if it is
+// created.
+func childID(tx *bolt.Tx, bucket []byte, b *bolt.Bucket, key []byte, newID func(tx *bolt.Tx) ([]byte, error)) (*bolt.Bucket, error) {
+	buf := b.Get(key)
+	if buf == nil {
+		var err error
+		buf, err = newID(tx)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		err = b.Put(key, buf)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+	}
+	sub, err := tx.CreateBucket(buf)
+	if e.Contains(err, "bucket already exists") {
+		return tx.Bucket(buf), nil
+	} else if err != nil {
+		return nil, e.Forward(err)
+	}
+	err = countBuckets(tx, bucket, 1)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return sub, nil
+}
+
 const ErrKeyNotFound = "key not found"
 
+const ErrNoKeys = "no keys"
+
 func Get(tx *bolt.Tx, bucket []byte, keys [][]byte) ([]byte, error) {
 	var buf []byte
 	if len(keys) == 0 {
-		return nil, e.New("no keys")
+		return nil, newKeyError(ErrNoKeys, bucket, -1, nil)
 	}
 	b := tx.Bucket(bucket)
+	if b == nil {
+		return nil, newKeyError(ErrInvBucket, bucket, -1, keys)
+	}
 	if len(keys) >= 2 {
-		for _, key := range keys[:len(keys)-1] {
+		for i, key := range keys[:len(keys)-1] {
 			buf = b.Get(key)
 			if buf == nil {
-				return nil, e.New(ErrKeyNotFound)
+				return nil, newKeyError(ErrKeyNotFound, bucket, i, keys)
+			}
+			b = subBucket(tx, buf)
+			if b == nil {
+				return nil, newKeyError(ErrKeyNotFound, bucket, i+1, keys)
 			}
-			b = tx.Bucket(buf)
 		}
 	}
 	buf = b.Get(keys[len(keys)-1])
 	if buf == nil {
-		return nil, e.New(ErrKeyNotFound)
+		return nil, newKeyError(ErrKeyNotFound, bucket, len(keys)-1, keys)
+	}
+	err := checkExpired(tx, bucket, keys)
+	if err != nil {
+		return nil, err
+	}
+	buf, err = decodeValue(bucket, keys, buf)
+	if err != nil {
+		if e.Contains(err, ErrChecksum) {
+			return nil, newKeyError(ErrChecksum, bucket, len(keys)-1, keys)
+		}
+		return nil, e.Push(err, e.New("%v at %v%v", ErrDecode, string(bucket), Path(keys)))
 	}
 	return buf, nil
 }
 
+// Exists reports if the key path reaches a leaf or, if it is partial, an
+// intermediate bucket. Expired leaves don't exist. Nothing is copied.
+func Exists(tx *bolt.Tx, bucket []byte, keys [][]byte) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	b, err := prefixBucket(tx, bucket, keys[:len(keys)-1])
+	if err != nil {
+		return false
+	}
+	if b.Get(keys[len(keys)-1]) == nil {
+		return false
+	}
+	return checkExpired(tx, bucket, keys) == nil
+}
+
 func Del(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
 	if len(keys) == 0 {
-		return e.New("no keys")
+		return newKeyError(ErrNoKeys, bucket, -1, nil)
+	}
+	err := checkFrozen(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = quotaDel(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
 	}
 	bname := make([][]byte, len(keys))
 	bs := make([]*bolt.Bucket, len(keys))
 	b := tx.Bucket(bucket)
+	if b == nil {
+		return newKeyError(ErrInvBucket, bucket, -1, keys)
+	}
 	bname[0] = bucket
 	bs[0] = b
+	var v []byte
 	for i := 0; i < len(keys); i++ {
-		v := b.Get(keys[i])
-		b = tx.Bucket(v)
+		v = b.Get(keys[i])
+		if v == nil {
+			return newKeyError(ErrKeyNotFound, bucket, i, keys)
+		}
 		if i+1 < len(keys) {
+			b = subBucket(tx, v)
+			if b == nil {
+				return newKeyError(ErrKeyNotFound, bucket, i+1, keys)
+			}
 			bname[i+1] = v
 			bs[i+1] = b
 		}
 	}
+	err = countDel(tx, bucket, keys, v)
+	if err != nil {
+		return e.Forward(err)
+	}
 
 	for level := len(bs) - 1; level >= 0; level-- {
 		err := bs[level].Delete(keys[level])
 		if err != nil {
 			return e.Forward(err)
 		}
-		if bs[level].Stats().KeyN <= 1 {
+		if empty(bs[level]) {
 			if level-1 < 0 {
 				break
 			}
@@ -115,9 +291,26 @@ func Del(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
 			if err != nil {
 				return e.Forward(err)
 			}
+			err = countBuckets(tx, bucket, -1)
+			if err != nil {
+				return e.Forward(err)
+			}
 			continue
 		}
 		break
 	}
+	err = clearTTL(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = delETag(tx, bucket, keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = logChange(tx, EventDel, bucket, keys, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	notify(tx, EventDel, bucket, keys, nil)
 	return nil
 }
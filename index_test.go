@@ -6,12 +6,11 @@ package boltdbutils
 
 import (
 	"bytes"
-	"encoding/binary"
-	"fmt"
 	"io/ioutil"
-	"log"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/fcavani/e"
@@ -176,16 +175,16 @@ func TestDel(t *testing.T) {
 		t.Fatal(e.Trace(e.Forward(err)))
 	}
 
-	err = PrintDb(db, buckets)
-	if err != nil {
-		t.Fatal(e.Trace(e.Forward(err)))
-	}
+	db.View(func(tx *bolt.Tx) error {
+		logTrees(t, tx, buckets)
+		return nil
+	})
 
 	err = db.Update(func(tx *bolt.Tx) error {
 		for i, d := range data {
 			err := Del(tx, d.Bucket, d.Keys)
 			if err != nil {
-				PrintDbTx(tx, buckets)
+				logTrees(t, tx, buckets)
 				return e.Push(err, e.New("Fail to del %v", i))
 			}
 		}
@@ -194,10 +193,10 @@ func TestDel(t *testing.T) {
 	if err != nil {
 		t.Fatal(e.Trace(e.Forward(err)))
 	}
-	err = PrintDb(db, buckets)
-	if err != nil {
-		t.Fatal(e.Trace(e.Forward(err)))
-	}
+	db.View(func(tx *bolt.Tx) error {
+		logTrees(t, tx, buckets)
+		return nil
+	})
 	err = DbEmpty(db, buckets)
 	if err != nil {
 		t.Fatal(e.Trace(e.Forward(err)))
@@ -224,86 +223,103 @@ func DbEmpty(db *bolt.DB, buckets []string) error {
 	return e.Forward(err)
 }
 
-func PrintDb(db *bolt.DB, buckets []string) error {
-	err := db.View(func(tx *bolt.Tx) error {
-		// err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
-		// 	fmt.Println(string(name))
-		// 	return nil
-		// })
-		for _, bucket := range buckets {
-			b := tx.Bucket([]byte(bucket))
-			if b == nil {
-				log.Printf("Bucket %v not found.", bucket)
-				continue
-			}
-			fmt.Println(bucket, b.Stats().KeyN)
-			err := b.ForEach(func(k, v []byte) error {
-				fmt.Printf("\t%v -> %v\n", string(k), string(v))
-				err := goInside(tx, v, 2)
-				if err != nil {
-					return e.Forward(err)
-				}
-				return nil
-			})
-			if err != nil {
-				return e.Forward(err)
-			}
+// logTrees logs the trees of buckets.
+func logTrees(t *testing.T, tx *bolt.Tx, buckets []string) {
+	for _, bucket := range buckets {
+		var buf bytes.Buffer
+		err := DumpTree(tx, []byte(bucket), &buf, DumpOptions{})
+		if err != nil {
+			t.Logf("%v: %v", bucket, err)
+			continue
 		}
-		return nil
-	})
-	if err != nil {
-		return e.Forward(err)
+		t.Logf("%v\n%v", bucket, buf.String())
 	}
-	return nil
 }
 
-func PrintDbTx(tx *bolt.Tx, buckets []string) error {
-	for _, bucket := range buckets {
-		b := tx.Bucket([]byte(bucket))
-		if b == nil {
-			log.Printf("Bucket %v not found.", bucket)
-			continue
+func TestPutUnique(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	keys := [][]byte{[]byte("en"), []byte("2015"), []byte("title")}
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := PutUnique(tx, []byte("test_bucket"), keys, []byte("first"))
+		if err != nil {
+			return e.Forward(err)
 		}
-		fmt.Println(bucket, b.Stats().KeyN)
-		err := b.ForEach(func(k, v []byte) error {
-			fmt.Printf("\t%v -> %v\n", string(k), string(v))
-			err := goInside(tx, v, 2)
-			if err != nil {
-				return e.Forward(err)
-			}
-			return nil
-		})
+		err = PutUnique(tx, []byte("test_bucket"), keys, []byte("second"))
+		if !IsError(err, ErrDuplicateKey) {
+			return e.New("expected ErrDuplicateKey: %v", err)
+		}
+		if k, ok := AsKeyError(err); !ok || k.Level() != 2 {
+			return e.New("wrong level in %v", err)
+		}
+		v, err := Get(tx, []byte("test_bucket"), keys)
 		if err != nil {
 			return e.Forward(err)
 		}
+		if string(v) != "first" {
+			return e.New("overwritten: %v", string(v))
+		}
+		return PutUnique(tx, []byte("test_bucket"), [][]byte{[]byte("en"), []byte("2015"), []byte("other")}, []byte("x"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
 	}
-	return nil
 }
 
-func goInside(tx *bolt.Tx, v []byte, level int) error {
-	sub := tx.Bucket(v)
-	if sub == nil {
-		return e.New("bucket %v not found", string(v))
-	}
-	//fmt.Println(string(v), sub.Stats().KeyN)
-	err := sub.ForEach(func(k, v []byte) error {
-		for i := 0; i < level; i++ {
-			fmt.Print("\t")
+func TestExists(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, [][]byte{[]byte("en"), []byte("2015"), []byte("a")}, []byte("a"))
+		if err != nil {
+			return e.Forward(err)
 		}
-		fmt.Printf("%v (%v) -> %v\n", string(k), decNumber(k), string(v))
-		err := goInside(tx, v, level+1)
+		err = PutTTL(tx, bucket, [][]byte{[]byte("en"), []byte("2015"), []byte("b")}, []byte("b"), time.Now().Add(-time.Second))
 		if err != nil {
-			return nil
+			return e.Forward(err)
+		}
+		for _, d := range []struct {
+			path   string
+			exists bool
+		}{
+			{"en/2015/a", true},
+			{"en/2015", true},
+			{"en", true},
+			{"en/2015/b", false},
+			{"en/2015/c", false},
+			{"en/2016/a", false},
+			{"pt", false},
+			{"en/2015/a/x", false},
+		} {
+			var keys [][]byte
+			for _, k := range strings.Split(d.path, "/") {
+				keys = append(keys, []byte(k))
+			}
+			if Exists(tx, bucket, keys) != d.exists {
+				return e.New("wrong existence of %v", d.path)
+			}
+		}
+		if Exists(tx, []byte("test_none"), [][]byte{[]byte("en")}) {
+			return e.New("key in a missing bucket")
 		}
 		return nil
 	})
 	if err != nil {
-		return e.Forward(err)
+		t.Fatal(e.Trace(e.Forward(err)))
 	}
-	return nil
-}
-
-func decNumber(buf []byte) int64 {
-	num, _ := binary.Varint(buf)
-	return num
 }
@@ -0,0 +1,42 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package boltdbutils
+
+import "iter"
+
+// All iterates over the entries under the prefix of Init, in the order given
+// by Reverse, starting at the first one:
+//
+//	for k, v := range c.All() {
+//		...
+//	}
+//	if err := c.Err(); err != nil {
+//		...
+//	}
+//
+// The keys and the value are only valid until the next step. An error stops
+// the iteration and is kept for Err.
+func (c *Cursor) All() iter.Seq2[[][]byte, []byte] {
+	return func(yield func([][]byte, []byte) bool) {
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// From is like All but starts at the entry found by Seek with keys.
+func (c *Cursor) From(keys ...[]byte) iter.Seq2[[][]byte, []byte] {
+	return func(yield func([][]byte, []byte) bool) {
+		for k, v := c.Seek(keys...); k != nil; k, v = c.Next() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorIter(t *testing.T) {
+	data := []testData{
+		{[]byte("test_iter"), [][]byte{[]byte("en"), []byte("2014"), []byte("a")}, []byte("1")},
+		{[]byte("test_iter"), [][]byte{[]byte("en"), []byte("2015"), []byte("b")}, []byte("2")},
+		{[]byte("test_iter"), [][]byte{[]byte("pt"), []byte("2014"), []byte("c")}, []byte("3")},
+		{[]byte("test_iter"), [][]byte{[]byte("pt"), []byte("2015"), []byte("d")}, []byte("4")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_iter"),
+			NumKeys: 3,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		got := ""
+		for _, v := range c.All() {
+			got += string(v)
+		}
+		if got != "1234" {
+			return e.New("wrong iteration %v", got)
+		}
+		got = ""
+		for k, v := range c.From([]byte("en"), []byte("2015"), []byte("b")) {
+			got += string(v)
+			if string(k[0]) == "pt" {
+				break
+			}
+		}
+		if got != "23" {
+			return e.New("wrong iteration from %v", got)
+		}
+		if err := c.Err(); err != nil {
+			return e.Forward(err)
+		}
+		for range c.From([]byte("en")) {
+			return e.New("iteration with wrong number of keys")
+		}
+		if c.Err() == nil {
+			return e.New("error not kept")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
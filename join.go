@@ -0,0 +1,93 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+// JoinRow is a leaf of the left cursor of a JoinCursor with the leaf of the
+// right cursor it points to. The slices are only valid until the next move of
+// the JoinCursor.
+type JoinRow struct {
+	Keys       [][]byte
+	Value      []byte
+	RightKeys  [][]byte
+	RightValue []byte
+}
+
+// JoinCursor iterates Left and joins each leaf with the leaf of Right found
+// by On, like an index bucket title -> uuid joined with the content bucket
+// uuid -> text. The leaves of Left without a match in Right are skipped. Both
+// cursors must be initialized and in the same transaction, and are only
+// moved by the JoinCursor.
+type JoinCursor struct {
+	Left  *Cursor
+	Right *Cursor
+	// On returns the key path of Right, with Right.NumKeys keys, joined with
+	// the leaf keys, value of Left. A nil On uses the value of the leaf as
+	// the only key, for a Right with one level.
+	On func(keys [][]byte, value []byte) [][]byte
+}
+
+// First returns the first row, or nil if there is none.
+func (j *JoinCursor) First() *JoinRow {
+	return j.join(j.Left.First, j.Left.Next)
+}
+
+// Last returns the last row, or nil if there is none.
+func (j *JoinCursor) Last() *JoinRow {
+	return j.join(j.Left.Last, j.Left.Prev)
+}
+
+// Next returns the row after the current one, or nil at the end.
+func (j *JoinCursor) Next() *JoinRow {
+	return j.join(j.Left.Next, j.Left.Next)
+}
+
+// Prev returns the row before the current one, or nil at the start.
+func (j *JoinCursor) Prev() *JoinRow {
+	return j.join(j.Left.Prev, j.Left.Prev)
+}
+
+// Seek returns the first row with the left keys equal or after keys.
+func (j *JoinCursor) Seek(keys ...[]byte) *JoinRow {
+	seek := func() ([][]byte, []byte) {
+		return j.Left.Seek(keys...)
+	}
+	return j.join(seek, j.Left.Next)
+}
+
+// Err returns the errors of both cursors, the left first.
+func (j *JoinCursor) Err() error {
+	err := j.Left.Err()
+	if err != nil {
+		j.Right.Err()
+		return err
+	}
+	return j.Right.Err()
+}
+
+// join moves Left with move and after with step until a leaf has a match.
+func (j *JoinCursor) join(move, step func() ([][]byte, []byte)) *JoinRow {
+	for k, v := move(); k != nil; k, v = step() {
+		var want [][]byte
+		if j.On != nil {
+			want = j.On(k, v)
+		} else {
+			want = [][]byte{v}
+		}
+		if len(want) != j.Right.NumKeys {
+			continue
+		}
+		rk, rv := j.Right.Seek(copyKeys(want)...)
+		if rk == nil || compareKeys(rk, j.Right.Transforms.Apply(want)) != 0 {
+			continue
+		}
+		return &JoinRow{
+			Keys:       k,
+			Value:      v,
+			RightKeys:  rk,
+			RightValue: rv,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestJoinCursor(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		// pub -> title -> id and id -> text.
+		index := []struct{ pub, title, id string }{
+			{"1", "apple", "id3"},
+			{"1", "banana", "id1"},
+			{"1", "cherry", "id9"},
+			{"2", "date", "id2"},
+		}
+		for _, d := range index {
+			err := Put(tx, []byte("test_index"), [][]byte{[]byte(d.pub), []byte(d.title)}, []byte(d.id))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		for _, id := range []string{"id1", "id2", "id3"} {
+			err := Put(tx, []byte("test_text"), [][]byte{[]byte(id)}, []byte("text of "+id))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		left := &Cursor{Tx: tx, Bucket: []byte("test_index"), NumKeys: 2}
+		err := left.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		right := &Cursor{Tx: tx, Bucket: []byte("test_text"), NumKeys: 1}
+		err = right.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		j := &JoinCursor{Left: left, Right: right}
+
+		got := ""
+		for r := j.First(); r != nil; r = j.Next() {
+			got += string(r.Keys[1]) + "=" + string(r.RightValue) + ";"
+		}
+		// cherry points to a missing text.
+		if got != "apple=text of id3;banana=text of id1;date=text of id2;" {
+			return e.New("wrong join %v", got)
+		}
+		got = ""
+		for r := j.Last(); r != nil; r = j.Prev() {
+			got += string(r.Keys[1]) + ";"
+		}
+		if got != "date;banana;apple;" {
+			return e.New("wrong backward join %v", got)
+		}
+		r := j.Seek([]byte("1"), []byte("c"))
+		if r == nil || string(r.Keys[1]) != "date" || string(r.RightKeys[0]) != "id2" {
+			return e.New("wrong seek %v", r)
+		}
+
+		// On builds the key path of the right cursor.
+		j.On = func(keys [][]byte, value []byte) [][]byte {
+			return [][]byte{append([]byte("id"), keys[0]...)}
+		}
+		got = ""
+		for r := j.First(); r != nil; r = j.Next() {
+			got += string(r.Keys[1]) + "=" + string(r.RightValue) + ";"
+		}
+		if got != "apple=text of id1;banana=text of id1;cherry=text of id1;date=text of id2;" {
+			return e.New("wrong join with on %v", got)
+		}
+		return j.Err()
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Key builds a key path segment by segment. Numbers are encoded so the
+// keys sort in numeric order under bolt's byte comparison:
+//
+//	K().Str("pt-br").Int(2015).Int(12).Int(23).Str(title).Build()
+type Key struct {
+	path Path
+}
+
+// K starts a new key path.
+func K() *Key {
+	return &Key{}
+}
+
+// NewKey is the same as K.
+func NewKey() *Key {
+	return K()
+}
+
+// Str adds a string segment.
+func (k *Key) Str(s string) *Key {
+	k.path = append(k.path, []byte(s))
+	return k
+}
+
+// Int adds an integer segment. Negative numbers sort before positive ones.
+func (k *Key) Int(i int64) *Key {
+	k.path = append(k.path, EncodeInt64(i))
+	return k
+}
+
+// Uint adds an unsigned integer segment.
+func (k *Key) Uint(u uint64) *Key {
+	k.path = append(k.path, EncodeUint64(u))
+	return k
+}
+
+// Float adds a floating point segment. NaN isn't ordered.
+func (k *Key) Float(f float64) *Key {
+	k.path = append(k.path, EncodeFloat64(f))
+	return k
+}
+
+// Time adds a timestamp segment with nanosecond precision. The time zone
+// is lost, the key is decoded in UTC.
+func (k *Key) Time(t time.Time) *Key {
+	k.path = append(k.path, EncodeTimeKey(t))
+	return k
+}
+
+// Bytes adds a raw segment.
+func (k *Key) Bytes(b []byte) *Key {
+	seg := make([]byte, len(b))
+	copy(seg, b)
+	k.path = append(k.path, seg)
+	return k
+}
+
+// Build returns the key path.
+func (k *Key) Build() Path {
+	out := make(Path, len(k.path))
+	copy(out, k.path)
+	return out
+}
+
+// EncodeInt64 encodes i in 8 bytes, in big-endian with the sign bit flipped,
+// so the keys sort in numeric order under bolt's byte comparison. Negative
+// numbers sort before positive ones.
+func EncodeInt64(i int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(i)^1<<63)
+	return buf
+}
+
+// DecodeInt64 is the inverse of EncodeInt64. buf must have 8 bytes.
+func DecodeInt64(buf []byte) int64 {
+	return int64(binary.BigEndian.Uint64(buf) ^ 1<<63)
+}
+
+// EncodeUint64 encodes u in 8 bytes in big-endian, so the keys sort in
+// numeric order.
+func EncodeUint64(u uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, u)
+	return buf
+}
+
+// DecodeUint64 is the inverse of EncodeUint64. buf must have 8 bytes.
+func DecodeUint64(buf []byte) uint64 {
+	return binary.BigEndian.Uint64(buf)
+}
+
+// EncodeFloat64 encodes f in 8 bytes. It flips the sign bit of positive
+// numbers and all bits of negative numbers, so the IEEE 754 representation
+// sorts as the numbers. NaN isn't ordered.
+func EncodeFloat64(f float64) []byte {
+	u := math.Float64bits(f)
+	if u&(1<<63) != 0 {
+		u = ^u
+	} else {
+		u |= 1 << 63
+	}
+	return EncodeUint64(u)
+}
+
+// DecodeFloat64 is the inverse of EncodeFloat64. buf must have 8 bytes.
+func DecodeFloat64(buf []byte) float64 {
+	u := DecodeUint64(buf)
+	if u&(1<<63) != 0 {
+		u &^= 1 << 63
+	} else {
+		u = ^u
+	}
+	return math.Float64frombits(u)
+}
+
+// EncodeTimeKey encodes t with nanosecond precision in 8 bytes that sort in
+// chronological order. The time zone is lost.
+func EncodeTimeKey(t time.Time) []byte {
+	return EncodeInt64(t.UnixNano())
+}
+
+// DecodeTimeKey is the inverse of EncodeTimeKey. The time is in UTC.
+func DecodeTimeKey(buf []byte) time.Time {
+	return time.Unix(0, DecodeInt64(buf)).UTC()
+}
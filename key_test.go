@@ -0,0 +1,90 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestKeyInt(t *testing.T) {
+	nums := []int64{-1 << 63, -1000, -2, -1, 0, 1, 2, 12, 1000, 1<<63 - 1}
+	for i := range nums {
+		if DecodeInt64(EncodeInt64(nums[i])) != nums[i] {
+			t.Fatal("decode fail", nums[i])
+		}
+		if i == 0 {
+			continue
+		}
+		if bytes.Compare(EncodeInt64(nums[i-1]), EncodeInt64(nums[i])) >= 0 {
+			t.Fatal("wrong order", nums[i-1], nums[i])
+		}
+	}
+}
+
+func TestKeyBuilder(t *testing.T) {
+	p := K().Str("pt-br").Int(2015).Int(12).Str("title").Build()
+	if len(p) != 4 {
+		t.Fatal("wrong length", len(p))
+	}
+	if string(p[0]) != "pt-br" || DecodeInt64(p[1]) != 2015 || DecodeInt64(p[2]) != 12 || string(p[3]) != "title" {
+		t.Fatal("wrong key path", p)
+	}
+	feb := K().Int(2).Build()
+	dec := K().Int(12).Build()
+	if bytes.Compare(feb[0], dec[0]) >= 0 {
+		t.Fatal("month 12 sorts before month 2")
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+	floats := []float64{math.Inf(-1), -1e10, -1.5, -0.1, 0, 0.1, 1.5, 1e10, math.Inf(1)}
+	for i := range floats {
+		if DecodeFloat64(EncodeFloat64(floats[i])) != floats[i] {
+			t.Fatal("decode fail", floats[i])
+		}
+		if i > 0 && bytes.Compare(EncodeFloat64(floats[i-1]), EncodeFloat64(floats[i])) >= 0 {
+			t.Fatal("wrong order", floats[i-1], floats[i])
+		}
+	}
+
+	t1 := time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2015, 2, 1, 0, 0, 0, 0, time.UTC)
+	t3 := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	p := NewKey().Time(t1).Time(t2).Time(t3).Uint(7).Bytes([]byte{0xff}).Build()
+	if bytes.Compare(p[0], p[1]) >= 0 || bytes.Compare(p[1], p[2]) >= 0 {
+		t.Fatal("times in wrong order")
+	}
+	if !time.Unix(0, DecodeInt64(p[2])).Equal(t3) {
+		t.Fatal("time decode fail")
+	}
+	if DecodeUint64(p[3]) != 7 || !bytes.Equal(p[4], []byte{0xff}) {
+		t.Fatal("wrong key path", p)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	// The months sort as numbers, unlike with a varint.
+	if bytes.Compare(EncodeInt64(2), EncodeInt64(12)) >= 0 {
+		t.Fatal("month 12 sorts before month 2")
+	}
+	if bytes.Compare(EncodeUint64(255), EncodeUint64(256)) >= 0 || DecodeUint64(EncodeUint64(1<<40)) != 1<<40 {
+		t.Fatal("wrong uint64 encoding")
+	}
+	if len(EncodeInt64(-1)) != 8 || len(EncodeFloat64(0.5)) != 8 {
+		t.Fatal("encoding isn't fixed width")
+	}
+	t1 := time.Date(2015, 2, 1, 0, 0, 0, 0, time.FixedZone("BRT", -3*3600))
+	t2 := t1.Add(time.Nanosecond)
+	if bytes.Compare(EncodeTimeKey(t1), EncodeTimeKey(t2)) >= 0 {
+		t.Fatal("times in wrong order")
+	}
+	got := DecodeTimeKey(EncodeTimeKey(t1))
+	if !got.Equal(t1) || got.Location() != time.UTC {
+		t.Fatal("wrong time", got)
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+// KeysOnly makes the cursor return only the key paths, with nil values. The
+// values of the leaves aren't verified, decrypted, decompressed or decoded,
+// and Page doesn't copy them. The moves still open the intermediate buckets
+// to go down the tree.
+func (c *Cursor) KeysOnly(on bool) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.keysOnly = on
+}
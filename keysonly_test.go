@@ -0,0 +1,85 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestKeysOnly(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_keysonly")
+
+	setCodec(t, db, bucket, nil, true)
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, p := range []string{"/a/1", "/a/2", "/b/1"} {
+			keys, _ := ParsePath(p)
+			err := Put(tx, bucket, keys, []byte(p))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		// A corrupted value, that only KeysOnly reads without error.
+		b, err := prefixBucket(tx, bucket, [][]byte{[]byte("a")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		v := append([]byte{}, b.Get([]byte("2"))...)
+		v[len(v)-1] ^= 0x10
+		return b.Put([]byte("2"), v)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		c.KeysOnly(true)
+		var paths []string
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v != nil {
+				return e.New("value of %v returned", Path(k))
+			}
+			paths = append(paths, Path(k).String())
+		}
+		if got := strings.Join(paths, " "); got != "/a/1 /a/2 /b/1" {
+			return e.New("wrong keys %q", got)
+		}
+		if err := c.Err(); err != nil {
+			return e.Forward(err)
+		}
+		kvs, err := c.Page(0, 10)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(kvs) != 3 || kvs[1].Value != nil {
+			return e.New("wrong page %v", kvs)
+		}
+		if k, v := c.FirstDecoded(); k == nil || v != nil {
+			return e.New("wrong decoded %v %v", Path(k), v)
+		}
+
+		c.KeysOnly(false)
+		if k, v := c.First(); k == nil || string(v) != "/a/1" {
+			return e.New("wrong leaf %v %q", Path(k), v)
+		}
+		if k, _ := c.Next(); k == nil || !IsError(c.Err(), ErrChecksum) {
+			return e.New("corruption not detected: %v", c.Err())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
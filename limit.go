@@ -0,0 +1,75 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+// Limit makes Next and Prev return nil after n leaves, counting the one
+// where First, Last, Seek, Skip or SeekPrefix put the cursor, that start the
+// count again. The cursor stays in the last leaf. A zero n removes the limit.
+func (c *Cursor) Limit(n uint64) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.limit = n
+	c.returned = 0
+}
+
+// StopAt makes the cursor return nil instead of the leaves after the key
+// path keys in the order of the cursor, the greater ones, or the smaller
+// ones if Reverse is set. The leaf in keys is returned, and all the leaves
+// under keys if it is a partial key path. The moves that return nil leave the
+// cursor where it was, so with Reverse StopAt bounds the first keys of the
+// tree. Without keys it removes the boundary.
+func (c *Cursor) StopAt(keys ...[]byte) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	if len(keys) == 0 {
+		c.stopAt = nil
+		return
+	}
+	c.stopAt = copyKeys(c.Transforms.Apply(keys))
+}
+
+// start applies the options to the leaf where the cursor was positioned.
+func (c *Cursor) start(k [][]byte, v []byte) ([][]byte, []byte) {
+	if k == nil || c.past(k) {
+		return nil, nil
+	}
+	c.returned = 1
+	return k, v
+}
+
+// step moves the cursor with move, for Next or Prev, and applies the options
+// to the leaf reached. Over the limit it doesn't move, after the boundary it
+// moves back with undo.
+func (c *Cursor) step(move, undo func() ([][]byte, []byte)) ([][]byte, []byte) {
+	if c.limit > 0 && c.returned >= c.limit {
+		return nil, nil
+	}
+	k, v := move()
+	if k == nil {
+		return nil, nil
+	}
+	if c.past(k) {
+		undo()
+		return nil, nil
+	}
+	c.returned++
+	return k, v
+}
+
+// past reports if the leaf k is after the boundary of StopAt.
+func (c *Cursor) past(k [][]byte) bool {
+	if c.stopAt == nil {
+		return false
+	}
+	n := len(c.stopAt)
+	if n > len(k) {
+		n = len(k)
+	}
+	cmp := compareKeys(k[:n], c.stopAt)
+	if c.Reverse {
+		return cmp < 0
+	}
+	return cmp > 0
+}
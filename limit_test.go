@@ -0,0 +1,132 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestLimitStopAt(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_limit")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, p := range []string{"/a/1", "/a/2", "/b/1", "/b/2", "/b/3", "/c/1", "/d/1"} {
+			keys, _ := ParsePath(p)
+			err := Put(tx, bucket, keys, []byte(p))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	type options struct {
+		reverse bool
+		limit   uint64
+		stopAt  string
+	}
+	list := func(tx *bolt.Tx, o options, first func(c *Cursor) ([][]byte, []byte)) (string, error) {
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2, Reverse: o.reverse}
+		err := c.Init()
+		if err != nil {
+			return "", e.Forward(err)
+		}
+		c.Limit(o.limit)
+		if o.stopAt != "" {
+			keys, _ := ParsePath(o.stopAt)
+			c.StopAt(keys...)
+		}
+		var paths []string
+		for k, _ := first(c); k != nil; k, _ = c.Next() {
+			paths = append(paths, Path(k).String())
+		}
+		if k, _ := c.Next(); k != nil {
+			return "", e.New("Next after the end returned %v", Path(k))
+		}
+		return strings.Join(paths, " "), c.Err()
+	}
+	first := func(c *Cursor) ([][]byte, []byte) { return c.First() }
+	seekB := func(c *Cursor) ([][]byte, []byte) { return c.Seek([]byte("b"), []byte("2")) }
+	skip := func(c *Cursor) ([][]byte, []byte) { return c.Skip(1) }
+
+	tests := []struct {
+		o     options
+		first func(c *Cursor) ([][]byte, []byte)
+		want  string
+	}{
+		{options{limit: 3}, first, "/a/1 /a/2 /b/1"},
+		{options{limit: 2}, seekB, "/b/2 /b/3"},
+		{options{reverse: true, limit: 2}, skip, "/c/1 /b/3"},
+		{options{stopAt: "/b"}, first, "/a/1 /a/2 /b/1 /b/2 /b/3"},
+		{options{stopAt: "/b/2"}, first, "/a/1 /a/2 /b/1 /b/2"},
+		{options{stopAt: "/b/2", limit: 3}, seekB, "/b/2"},
+		{options{reverse: true, stopAt: "/b/2"}, first, "/d/1 /c/1 /b/3 /b/2"},
+		{options{reverse: true, stopAt: "/b"}, seekB, "/b/2 /b/1"},
+		{options{reverse: true, stopAt: "/c"}, seekB, ""},
+		{options{limit: 1}, first, "/a/1"},
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, test := range tests {
+			got, err := list(tx, test.o, test.first)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if got != test.want {
+				return e.New("%v %+v: %q, want %q", i, test.o, got, test.want)
+			}
+		}
+
+		// Page and Prev keep the options.
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		c.StopAt([]byte("b"))
+		kvs, err := c.Page(3, 10)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(kvs) != 2 || string(kvs[1].Value) != "/b/3" {
+			return e.New("wrong page %v", kvs)
+		}
+		c.StopAt()
+		c.Limit(2)
+		c.Seek([]byte("c"), []byte("1"))
+		if k, _ := c.Prev(); Path(k).String() != "/b/3" {
+			return e.New("wrong Prev %v", Path(k))
+		}
+		if k, _ := c.Prev(); k != nil {
+			return e.New("Prev over the limit %v", Path(k))
+		}
+		c.Limit(0)
+		if k, _ := c.Prev(); Path(k).String() != "/b/2" {
+			return e.New("the cursor moved after the limit: %v", Path(k))
+		}
+		c.StopAt([]byte("b"), []byte("3"))
+		if k, _ := c.Next(); Path(k).String() != "/b/3" {
+			return e.New("wrong Next %v", Path(k))
+		}
+		if k, _ := c.Next(); k != nil {
+			return e.New("Next after the boundary %v", Path(k))
+		}
+		if k, _ := c.Prev(); Path(k).String() != "/b/2" {
+			return e.New("the cursor moved after the boundary: %v", Path(k))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
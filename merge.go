@@ -0,0 +1,51 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// Merge replaces the leaf in the key path with the value returned by fn. fn
+// receives a copy of the current value, nil if the leaf doesn't exist or
+// expired. If fn returns nil the leaf is deleted, if fn fails nothing
+// changes.
+func Merge(tx *bolt.Tx, bucket []byte, keys [][]byte, fn func(old []byte) ([]byte, error)) error {
+	var old []byte
+	exists := true
+	v, err := Get(tx, bucket, keys)
+	switch {
+	case err == nil:
+		if subBucket(tx, v) != nil {
+			return newKeyError(ErrNotLeaf, bucket, len(keys)-1, keys)
+		}
+		old = append([]byte{}, v...)
+	case IsError(err, ErrExpired):
+	case IsError(err, ErrKeyNotFound), IsError(err, ErrInvBucket):
+		exists = false
+	default:
+		return e.Forward(err)
+	}
+	data, err := fn(old)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if data == nil {
+		if !exists {
+			return nil
+		}
+		err = Del(tx, bucket, keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return nil
+	}
+	err = Put(tx, bucket, keys, data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestMerge(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_merge")
+	keys := [][]byte{[]byte("views"), []byte("post")}
+	inc := func(old []byte) ([]byte, error) {
+		var n uint64
+		if old != nil {
+			n = binary.BigEndian.Uint64(old)
+		}
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, n+1)
+		return buf, nil
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 3; i++ {
+			err := Merge(tx, bucket, keys, inc)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		v, err := Get(tx, bucket, keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n := binary.BigEndian.Uint64(v); n != 3 {
+			return e.New("wrong count %v", n)
+		}
+
+		err = Merge(tx, bucket, keys, func(old []byte) ([]byte, error) {
+			return nil, e.New("fail")
+		})
+		if err == nil {
+			return e.New("error not returned")
+		}
+		err = Merge(tx, bucket, keys[:1], inc)
+		if !IsError(err, ErrNotLeaf) {
+			return e.New("expected ErrNotLeaf %v", err)
+		}
+
+		err = Merge(tx, bucket, keys, func(old []byte) ([]byte, error) {
+			return nil, nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket(bucket) != nil && tx.Bucket(bucket).Get(keys[0]) != nil {
+			return e.New("leaf not deleted")
+		}
+		return Merge(tx, bucket, keys, func(old []byte) ([]byte, error) {
+			return nil, nil
+		})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
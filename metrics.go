@@ -0,0 +1,173 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Op is an operation of an instrumented DB or of one of its cursors.
+type Op struct {
+	// Name is Put, Get or Del, or the name of the cursor method.
+	Name   string
+	Bucket []byte
+	// Prefix is the first key of the key path, or of the keys the cursor is
+	// pinned to, nil if there is none.
+	Prefix []byte
+	// Depth is the number of keys of the key path, or the number of levels
+	// of the cursor.
+	Depth    int
+	Duration time.Duration
+	// Err is the error of the operation. The cursors report their errors
+	// once, in the operation that had them.
+	Err error
+}
+
+// Metrics receives the operations of the databases instrumented with it.
+// Observe is called by concurrent goroutines and must not keep the slices of
+// op. A Prometheus backend is an Observe that updates a counter and a
+// histogram labeled by op.Name.
+type Metrics interface {
+	Observe(op Op)
+}
+
+// Instrument sends the operations Put, Get and Del of d, and the operations
+// of the cursors returned by NewCursor, to m. Call it before using d, a nil m
+// stops the instrumentation.
+func Instrument(d *DB, m Metrics) *DB {
+	d.metrics = m
+	return d
+}
+
+// observe sends the operation that started at start to the metrics of d.
+func (d *DB) observe(name string, bucket []byte, keys [][]byte, start time.Time, err error) {
+	if d.metrics == nil {
+		return
+	}
+	op := Op{
+		Name:     name,
+		Bucket:   bucket,
+		Depth:    len(keys),
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if len(keys) > 0 {
+		op.Prefix = keys[0]
+	}
+	d.metrics.Observe(op)
+}
+
+// LatencyBuckets are the upper bounds of the latency histograms of
+// ExpvarMetrics. Set it before creating them.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// MaxPrefixes is the number of prefixes counted by ExpvarMetrics, the others
+// are counted together as "other".
+var MaxPrefixes = 1000
+
+// ExpvarMetrics publishes the operations in a map of expvar with the maps:
+//
+//	ops       the number of operations by name
+//	errors    the number of errors by name
+//	latency   by name, the number of operations by upper bound of the
+//	          duration, "inf" above the last one
+//	depth     the number of operations by depth
+//	prefixes  the number of operations by bucket and first key, the hot
+//	          prefixes, as "bucket/key"
+type ExpvarMetrics struct {
+	ops      *expvar.Map
+	errors   *expvar.Map
+	latency  *expvar.Map
+	depth    *expvar.Map
+	prefixes *expvar.Map
+	bounds   []time.Duration
+	max      int
+	lck      sync.Mutex
+	count    int
+}
+
+// NewExpvarMetrics publishes the metrics with name. It panics if the name is
+// in use, like expvar.NewMap.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		ops:      new(expvar.Map).Init(),
+		errors:   new(expvar.Map).Init(),
+		latency:  new(expvar.Map).Init(),
+		depth:    new(expvar.Map).Init(),
+		prefixes: new(expvar.Map).Init(),
+		bounds:   LatencyBuckets,
+		max:      MaxPrefixes,
+	}
+	root := expvar.NewMap(name)
+	root.Set("ops", m.ops)
+	root.Set("errors", m.errors)
+	root.Set("latency", m.latency)
+	root.Set("depth", m.depth)
+	root.Set("prefixes", m.prefixes)
+	return m
+}
+
+func (m *ExpvarMetrics) Observe(op Op) {
+	m.ops.Add(op.Name, 1)
+	if op.Err != nil {
+		m.errors.Add(op.Name, 1)
+	}
+	m.depth.Add(strconv.Itoa(op.Depth), 1)
+	m.histogram(op.Name).Add(m.bound(op.Duration), 1)
+	if op.Prefix != nil {
+		m.prefixes.Add(m.prefix(string(op.Bucket)+"/"+KeyString(op.Prefix)), 1)
+	}
+}
+
+// histogram returns the latency histogram of the operation name.
+func (m *ExpvarMetrics) histogram(name string) *expvar.Map {
+	if h, ok := m.latency.Get(name).(*expvar.Map); ok {
+		return h
+	}
+	m.lck.Lock()
+	defer m.lck.Unlock()
+	if h, ok := m.latency.Get(name).(*expvar.Map); ok {
+		return h
+	}
+	h := new(expvar.Map).Init()
+	m.latency.Set(name, h)
+	return h
+}
+
+func (m *ExpvarMetrics) bound(d time.Duration) string {
+	for _, b := range m.bounds {
+		if d <= b {
+			return b.String()
+		}
+	}
+	return "inf"
+}
+
+// prefix returns key, or "other" if there are too many prefixes.
+func (m *ExpvarMetrics) prefix(key string) string {
+	if m.prefixes.Get(key) != nil {
+		return key
+	}
+	m.lck.Lock()
+	defer m.lck.Unlock()
+	if m.prefixes.Get(key) != nil {
+		return key
+	}
+	if m.count >= m.max {
+		return "other"
+	}
+	m.count++
+	m.prefixes.Add(key, 0)
+	return key
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"expvar"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcavani/e"
+)
+
+type recorder struct {
+	lck sync.Mutex
+	ops []Op
+}
+
+func (r *recorder) Observe(op Op) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	op.Prefix = append([]byte{}, op.Prefix...)
+	r.ops = append(r.ops, op)
+}
+
+func TestInstrument(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	db, err := Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+	r := &recorder{}
+	Instrument(db, r)
+
+	bucket := []byte("posts")
+	for _, p := range []string{"/en/2015/a", "/en/2015/b", "/pt/2015/c"} {
+		keys, _ := ParsePath(p)
+		err = db.Put(bucket, keys, []byte(p))
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	_, err = db.Get(bucket, [][]byte{[]byte("en"), []byte("2015"), []byte("x")})
+	if err == nil {
+		t.Fatal("found a missing key")
+	}
+	c, err := db.NewCursor(bucket, 3, false, []byte("en"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	c.First()
+	c.Skip(1)
+	c.Seek([]byte("2015"))
+	c.Next()
+	c.Rollback()
+
+	want := []struct {
+		name   string
+		prefix string
+		depth  int
+		err    bool
+	}{
+		{"Put", "en", 3, false},
+		{"Put", "en", 3, false},
+		{"Put", "pt", 3, false},
+		{"Get", "en", 3, true},
+		{"First", "en", 3, false},
+		{"Skip", "en", 3, false},
+		{"Seek", "en", 3, true},
+		{"Next", "en", 3, false},
+	}
+	if len(r.ops) != len(want) {
+		t.Fatalf("wrong number of operations %v: %+v", len(r.ops), r.ops)
+	}
+	for i, w := range want {
+		op := r.ops[i]
+		if op.Name != w.name || string(op.Prefix) != w.prefix || op.Depth != w.depth || (op.Err != nil) != w.err || string(op.Bucket) != "posts" {
+			t.Fatalf("wrong operation %v: %+v", i, op)
+		}
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	defer func(n int) { MaxPrefixes = n }(MaxPrefixes)
+	MaxPrefixes = 2
+	m := NewExpvarMetrics("boltdbutils_test")
+	ops := []Op{
+		{Name: "Get", Bucket: []byte("posts"), Prefix: []byte("en"), Depth: 3, Duration: 50 * time.Microsecond},
+		{Name: "Get", Bucket: []byte("posts"), Prefix: []byte("en"), Depth: 3, Duration: 5 * time.Millisecond},
+		{Name: "Put", Bucket: []byte("posts"), Prefix: []byte("pt br"), Depth: 2, Duration: 2 * time.Second, Err: e.New("fail")},
+		{Name: "Put", Bucket: []byte("posts"), Prefix: []byte("fr"), Depth: 3},
+		{Name: "Next", Bucket: []byte("posts"), Depth: 3},
+	}
+	for _, op := range ops {
+		m.Observe(op)
+	}
+	root := expvar.Get("boltdbutils_test").(*expvar.Map)
+	get := func(path ...string) string {
+		v := expvar.Var(root)
+		for _, p := range path {
+			v = v.(*expvar.Map).Get(p)
+			if v == nil {
+				return "<nil>"
+			}
+		}
+		return v.String()
+	}
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"ops", "Get"}, "2"},
+		{[]string{"ops", "Next"}, "1"},
+		{[]string{"errors", "Put"}, "1"},
+		{[]string{"errors", "Get"}, "<nil>"},
+		{[]string{"depth", "3"}, "4"},
+		{[]string{"latency", "Get", "100µs"}, "1"},
+		{[]string{"latency", "Get", "10ms"}, "1"},
+		{[]string{"latency", "Put", "inf"}, "1"},
+		{[]string{"prefixes", "posts/en"}, "2"},
+		{[]string{"prefixes", "posts/pt%20br"}, "1"},
+		{[]string{"prefixes", "other"}, "1"},
+	}
+	for _, test := range tests {
+		if got := get(test.path...); got != test.want {
+			t.Fatalf("%v: %v, want %v", test.path, got, test.want)
+		}
+	}
+}
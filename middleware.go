@@ -0,0 +1,73 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrNoTx = "no transaction in the context"
+
+type txKey struct{}
+
+// WithTx returns a copy of ctx carrying the transaction tx.
+func WithTx(ctx context.Context, tx *bolt.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx.
+func TxFromContext(ctx context.Context) (*bolt.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*bolt.Tx)
+	return tx, ok && tx != nil
+}
+
+// ViewHandler runs h with a read-only transaction in the request context.
+// The transaction is rolled back when h returns, even if it panics, so the
+// slices read from it must not outlive the request. Bolt transactions can't
+// be shared between goroutines, h must not hand the context to another
+// goroutine that reads from the database.
+func ViewHandler(db *bolt.DB, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tx, err := db.Begin(false)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer tx.Rollback()
+		h.ServeHTTP(w, r.WithContext(WithTx(r.Context(), tx)))
+	})
+}
+
+// GetContext is Get with the transaction carried by ctx.
+func GetContext(ctx context.Context, bucket []byte, keys [][]byte) ([]byte, error) {
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return nil, e.New(ErrNoTx)
+	}
+	buf, err := Get(tx, bucket, keys)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+// ScanContext calls fn for every leaf under the partial key path prefix, in
+// key order, with the transaction carried by ctx. The key path given to fn is
+// only valid during the call.
+func ScanContext(ctx context.Context, bucket []byte, prefix [][]byte, fn func(keys [][]byte, v []byte) error) error {
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return e.New(ErrNoTx)
+	}
+	err := walk(tx, bucket, prefix, fn)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrNotModified = "not modified"
+
+// ModTime returns the time of the last write in the key path. It is zero if
+// ETags aren't enabled in bucket, see EnableETag.
+func ModTime(tx *bolt.Tx, bucket []byte, keys [][]byte) (time.Time, error) {
+	_, err := Get(tx, bucket, keys)
+	if err != nil {
+		return time.Time{}, e.Forward(err)
+	}
+	return modTime(tx, bucket, keys), nil
+}
+
+// GetIfModifiedSince is like Get but fails with ErrNotModified if the key
+// path wasn't written after t. The modification time is truncated to the
+// second, like the HTTP dates. Without ETags enabled in bucket the value is
+// always returned.
+func GetIfModifiedSince(tx *bolt.Tx, bucket []byte, keys [][]byte, t time.Time) ([]byte, error) {
+	v, err := Get(tx, bucket, keys)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	mod := modTime(tx, bucket, keys)
+	if !mod.IsZero() && !mod.Truncate(time.Second).After(t) {
+		return nil, newKeyError(ErrNotModified, bucket, len(keys)-1, keys)
+	}
+	return v, nil
+}
+
+func modTime(tx *bolt.Tx, bucket []byte, keys [][]byte) time.Time {
+	b := etagsOf(tx, bucket)
+	if b == nil {
+		return time.Time{}
+	}
+	h := b.Get(etagKey(bucket, keys))
+	if len(h) < etagHashLen+8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(h[etagHashLen:])))
+}
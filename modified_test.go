@@ -0,0 +1,101 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestGetIfModifiedSince(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_modified")
+	keys := [][]byte{[]byte("2015"), []byte("a")}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, keys, []byte("one"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Without ETags the value is always modified.
+		v, err := GetIfModifiedSince(tx, bucket, keys, future)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "one" {
+			return e.New("wrong value %s", v)
+		}
+		mod, err := ModTime(tx, bucket, keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !mod.IsZero() {
+			return e.New("modification time without ETags %v", mod)
+		}
+		err = EnableETag(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Put(tx, bucket, keys, []byte("two"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		mod, err := ModTime(tx, bucket, keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if mod.Before(past) || mod.After(future) {
+			return e.New("wrong modification time %v", mod)
+		}
+		v, err := GetIfModifiedSince(tx, bucket, keys, past)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "two" {
+			return e.New("wrong value %s", v)
+		}
+		_, err = GetIfModifiedSince(tx, bucket, keys, future)
+		if !IsError(err, ErrNotModified) {
+			return e.New("expected ErrNotModified: %v", err)
+		}
+		_, err = GetIfModifiedSince(tx, bucket, keys, mod.Truncate(time.Second))
+		if !IsError(err, ErrNotModified) {
+			return e.New("expected ErrNotModified at the same second: %v", err)
+		}
+		_, err = GetIfModifiedSince(tx, bucket, [][]byte{[]byte("2015"), []byte("b")}, past)
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("expected ErrKeyNotFound: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrNotLeaf = "key path isn't a leaf"
+
+const ErrMoveInto = "can't move a subtree into itself"
+
+// Move moves the leaf in oldKeys to newKeys. It fails with ErrDuplicateKey if
+// newKeys exists. The expiration time of the leaf moves with it. The
+// intermediate buckets left empty are removed like in Del.
+func Move(tx *bolt.Tx, bucket []byte, oldKeys, newKeys [][]byte) error {
+	if compareKeys(oldKeys, newKeys) == 0 && len(oldKeys) == len(newKeys) {
+		return nil
+	}
+	err := checkFrozen(tx, bucket, oldKeys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	v, err := Get(tx, bucket, oldKeys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if subBucket(tx, v) != nil {
+		return newKeyError(ErrNotLeaf, bucket, len(oldKeys)-1, oldKeys)
+	}
+	data := append([]byte{}, v...)
+	exp, hasTTL := expiresAt(tx, bucket, oldKeys)
+	err = PutUnique(tx, bucket, newKeys, data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if hasTTL {
+		err = setTTL(tx, bucket, newKeys, exp)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	err = Del(tx, bucket, oldKeys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// MoveSubtree moves the intermediate bucket in oldPrefix, with everything
+// under it, to newPrefix. Only the reference to the bucket moves, the leaves
+// aren't copied, but the encrypted values are encrypted again for their new
+// key paths. It fails with ErrDuplicateKey if newPrefix exists and with
+// ErrMoveInto if newPrefix is under oldPrefix.
+func MoveSubtree(tx *bolt.Tx, bucket []byte, oldPrefix, newPrefix [][]byte) error {
+	if len(oldPrefix) == 0 || len(newPrefix) == 0 {
+		return newKeyError(ErrNoKeys, bucket, -1, nil)
+	}
+	if len(newPrefix) >= len(oldPrefix) && compareKeys(oldPrefix, newPrefix[:len(oldPrefix)]) == 0 {
+		if len(newPrefix) == len(oldPrefix) {
+			return nil
+		}
+		return newKeyError(ErrMoveInto, bucket, len(oldPrefix)-1, newPrefix)
+	}
+	err := checkFrozenTree(tx, bucket, oldPrefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = checkFrozen(tx, bucket, newPrefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	parent, err := prefixBucket(tx, bucket, oldPrefix[:len(oldPrefix)-1])
+	if err != nil {
+		return e.Forward(err)
+	}
+	id := parent.Get(oldPrefix[len(oldPrefix)-1])
+	if subBucket(tx, id) == nil {
+		return newKeyError(ErrKeyNotFound, bucket, len(oldPrefix)-1, oldPrefix)
+	}
+	id = append([]byte{}, id...)
+
+	// The metadata of the leaves is keyed by the key path.
+	type meta struct {
+		rel  [][]byte
+		exp  time.Time
+		ttl  bool
+		etag []byte
+	}
+	var metas []meta
+	etags := etagsOf(tx, bucket)
+	err = walk(tx, bucket, oldPrefix, func(keys [][]byte, v []byte) error {
+		m := meta{}
+		m.exp, m.ttl = expiresAt(tx, bucket, keys)
+		if etags != nil {
+			if h := etags.Get(etagKey(bucket, keys)); h != nil {
+				m.etag = append([]byte{}, h...)
+			}
+		}
+		if m.ttl || m.etag != nil {
+			m.rel = copyKeys(keys[len(oldPrefix):])
+			metas = append(metas, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+
+	b, err := tx.CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	for _, key := range newPrefix[:len(newPrefix)-1] {
+		b, err = child(tx, bucket, b, key)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	last := newPrefix[len(newPrefix)-1]
+	if b.Get(last) != nil {
+		return newKeyError(ErrDuplicateKey, bucket, len(newPrefix)-1, newPrefix)
+	}
+	err = countMove(tx, bucket, newPrefix, id)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(last, id)
+	if err != nil {
+		return e.Forward(err)
+	}
+	// The encrypted values are bound to the key path.
+	err = resealTree(tx, bucket, oldPrefix, bucket, newPrefix, tx.Bucket(id), nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	// Del removes the reference and the empty buckets above it, the moved
+	// bucket isn't touched.
+	err = Del(tx, bucket, oldPrefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = logTree(tx, bucket, newPrefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	notify(tx, EventPut, bucket, newPrefix, nil)
+
+	for _, m := range metas {
+		oldKeys := append(append([][]byte{}, oldPrefix...), m.rel...)
+		newKeys := append(append([][]byte{}, newPrefix...), m.rel...)
+		if m.ttl {
+			err = clearTTL(tx, bucket, oldKeys)
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = setTTL(tx, bucket, newKeys, m.exp)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		if m.etag != nil {
+			err = delETag(tx, bucket, oldKeys)
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = etags.Put(etagKey(bucket, newKeys), m.etag)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+	}
+	return nil
+}
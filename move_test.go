@@ -0,0 +1,144 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestMove(t *testing.T) {
+	data := []testData{
+		{[]byte("test_move"), [][]byte{[]byte("en"), []byte("2014"), []byte("a")}, []byte("a")},
+		{[]byte("test_move"), [][]byte{[]byte("en"), []byte("2014"), []byte("b")}, []byte("b")},
+		{[]byte("test_move"), [][]byte{[]byte("en"), []byte("2015"), []byte("c")}, []byte("c")},
+		{[]byte("test_move"), [][]byte{[]byte("pt"), []byte("2015"), []byte("d")}, []byte("d")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_move")
+	future := time.Now().Add(time.Hour)
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		err := EnableETag(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return PutTTL(tx, bucket, data[0].Keys, data[0].Data, future)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Rename the title, the TTL follows the leaf.
+		newKeys := [][]byte{[]byte("en"), []byte("2014"), []byte("z")}
+		err := Move(tx, bucket, data[0].Keys, newKeys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if _, err := Get(tx, bucket, data[0].Keys); !IsError(err, ErrKeyNotFound) {
+			return e.New("old key path still exists %v", err)
+		}
+		if exp, ok := expiresAt(tx, bucket, newKeys); !ok || !exp.Equal(time.Unix(0, future.UnixNano())) {
+			return e.New("TTL not moved")
+		}
+		if _, ok := expiresAt(tx, bucket, data[0].Keys); ok {
+			return e.New("TTL of the old key path not removed")
+		}
+		err = Move(tx, bucket, newKeys, data[1].Keys)
+		if !IsError(err, ErrDuplicateKey) {
+			return e.New("expected ErrDuplicateKey %v", err)
+		}
+		err = Move(tx, bucket, [][]byte{[]byte("en"), []byte("2014")}, newKeys)
+		if !IsError(err, ErrNotLeaf) {
+			return e.New("expected ErrNotLeaf %v", err)
+		}
+		// Leaves the pt subtree empty.
+		err = Move(tx, bucket, data[3].Keys, [][]byte{[]byte("en"), []byte("2015"), []byte("d")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket(bucket).Get([]byte("pt")) != nil {
+			return e.New("empty subtree not removed")
+		}
+
+		// Move en/2014 to pt/2014.
+		tag, err := ETag(tx, bucket, data[1].Keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = MoveSubtree(tx, bucket, [][]byte{[]byte("en"), []byte("2014")}, [][]byte{[]byte("en")})
+		if !IsError(err, ErrDuplicateKey) {
+			return e.New("expected ErrDuplicateKey %v", err)
+		}
+		err = MoveSubtree(tx, bucket, [][]byte{[]byte("en")}, [][]byte{[]byte("en"), []byte("x")})
+		if !IsError(err, ErrMoveInto) {
+			return e.New("expected ErrMoveInto %v", err)
+		}
+		err = MoveSubtree(tx, bucket, [][]byte{[]byte("en"), []byte("2014")}, [][]byte{[]byte("pt"), []byte("2014")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		moved := [][]byte{[]byte("pt"), []byte("2014"), []byte("z")}
+		v, err := Get(tx, bucket, moved)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "a" {
+			return e.New("wrong value %s", v)
+		}
+		if _, ok := expiresAt(tx, bucket, moved); !ok {
+			return e.New("TTL not moved with the subtree")
+		}
+		h := tx.Bucket([]byte(etagBucket)).Get(etagKey(bucket, [][]byte{[]byte("pt"), []byte("2014"), []byte("b")}))
+		if h == nil || quoteETag(h[:etagHashLen]) != tag {
+			return e.New("ETag not moved with the subtree")
+		}
+		if tx.Bucket([]byte(etagBucket)).Get(etagKey(bucket, data[1].Keys)) != nil {
+			return e.New("old ETag not removed")
+		}
+		if _, err := Get(tx, bucket, data[1].Keys); !IsError(err, ErrKeyNotFound) {
+			return e.New("old subtree still exists %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	orphans, err := Verify(db, [][]byte{bucket})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(orphans) != 0 {
+		t.Fatal("buckets lost by the moves", len(orphans))
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const outboxBucket = "__outbox"
+
+// MessageStatus is the status of a message in the outbox.
+type MessageStatus int
+
+const (
+	MessagePending MessageStatus = iota
+	MessageSent
+	MessageFailed
+)
+
+// Message is an outbound message recorded by Enqueue.
+type Message struct {
+	Seq       uint64
+	Topic     string
+	Payload   []byte
+	Status    MessageStatus
+	Attempts  int
+	LastError string
+	Created   time.Time
+	Updated   time.Time
+}
+
+// Sink receives the messages drained from the outbox.
+type Sink interface {
+	Send(m *Message) error
+}
+
+// Enqueue records an outbound message in the same transaction as the data
+// change, so the message exists if and only if the change was committed.
+func Enqueue(tx *bolt.Tx, topic string, payload []byte) (uint64, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(outboxBucket))
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	now := time.Now()
+	m := &Message{
+		Seq:     seq,
+		Topic:   topic,
+		Payload: payload,
+		Status:  MessagePending,
+		Created: now,
+		Updated: now,
+	}
+	err = putMessage(b, m)
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	return seq, nil
+}
+
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+func putMessage(b *bolt.Bucket, m *Message) error {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(seqKey(m.Seq), buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// OutboxMessage returns the message with the sequence number seq.
+func OutboxMessage(tx *bolt.Tx, seq uint64) (*Message, error) {
+	b := tx.Bucket([]byte(outboxBucket))
+	if b == nil {
+		return nil, e.New(ErrKeyNotFound)
+	}
+	buf := b.Get(seqKey(seq))
+	if buf == nil {
+		return nil, e.New(ErrKeyNotFound)
+	}
+	m := new(Message)
+	err := json.Unmarshal(buf, m)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return m, nil
+}
+
+// PurgeOutbox removes the sent and failed messages updated before t.
+func PurgeOutbox(tx *bolt.Tx, t time.Time) (int, error) {
+	b := tx.Bucket([]byte(outboxBucket))
+	if b == nil {
+		return 0, nil
+	}
+	var del [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		m := new(Message)
+		err := json.Unmarshal(v, m)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if m.Status != MessagePending && m.Updated.Before(t) {
+			del = append(del, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	for _, k := range del {
+		err = b.Delete(k)
+		if err != nil {
+			return 0, e.Forward(err)
+		}
+	}
+	return len(del), nil
+}
+
+// Dispatcher drains the outbox to a Sink. A message is marked as sent only
+// after Sink.Send returns, so the delivery is at-least-once.
+type Dispatcher struct {
+	DB   *bolt.DB
+	Sink Sink
+	// Batch is the maximum number of messages sent by each Dispatch.
+	Batch int
+	// MaxAttempts is the number of failed sends after which a message is
+	// marked as MessageFailed. Zero means retry forever.
+	MaxAttempts int
+}
+
+// Dispatch sends one batch of pending messages and returns how many were
+// sent.
+func (d *Dispatcher) Dispatch() (int, error) {
+	var pending []*Message
+	err := d.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(outboxBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if d.Batch > 0 && len(pending) >= d.Batch {
+				break
+			}
+			m := new(Message)
+			err := json.Unmarshal(v, m)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if m.Status == MessagePending {
+				pending = append(pending, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+
+	sent := 0
+	for _, m := range pending {
+		m.Attempts++
+		m.Updated = time.Now()
+		serr := d.Sink.Send(m)
+		if serr == nil {
+			m.Status = MessageSent
+			m.LastError = ""
+			sent++
+		} else {
+			m.LastError = serr.Error()
+			if d.MaxAttempts > 0 && m.Attempts >= d.MaxAttempts {
+				m.Status = MessageFailed
+			}
+		}
+		err = d.DB.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte(outboxBucket))
+			if b == nil {
+				return e.New(ErrInvBucket)
+			}
+			return putMessage(b, m)
+		})
+		if err != nil {
+			return sent, e.Forward(err)
+		}
+	}
+	return sent, nil
+}
+
+// Run calls Dispatch every interval until stop is closed. Errors are sent to
+// errs if it isn't nil.
+func (d *Dispatcher) Run(interval time.Duration, stop <-chan struct{}, errs chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, err := d.Dispatch()
+			if err != nil && errs != nil {
+				errs <- err
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+type testSink struct {
+	fail map[string]bool
+	got  []string
+}
+
+func (s *testSink) Send(m *Message) error {
+	if s.fail[string(m.Payload)] {
+		return e.New("send failed")
+	}
+	s.got = append(s.got, string(m.Payload))
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var failSeq uint64
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, p := range []string{"a", "b", "c"} {
+			err := Put(tx, []byte("test_bucket"), [][]byte{[]byte(p)}, []byte(p))
+			if err != nil {
+				return e.Forward(err)
+			}
+			seq, err := Enqueue(tx, "posts", []byte(p))
+			if err != nil {
+				return e.Forward(err)
+			}
+			if p == "b" {
+				failSeq = seq
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// A rolled back transaction doesn't leave messages behind.
+	db.Update(func(tx *bolt.Tx) error {
+		Enqueue(tx, "posts", []byte("rolled back"))
+		return e.New("rollback")
+	})
+
+	sink := &testSink{fail: map[string]bool{"b": true}}
+	d := &Dispatcher{DB: db, Sink: sink, MaxAttempts: 2}
+	for i := 0; i < 3; i++ {
+		_, err = d.Dispatch()
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	if len(sink.got) != 2 || sink.got[0] != "a" || sink.got[1] != "c" {
+		t.Fatal("wrong messages sent", sink.got)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		m, err := OutboxMessage(tx, failSeq)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if m.Status != MessageFailed || m.Attempts != 2 || m.LastError == "" {
+			return e.New("wrong status of the failed message %#v", m)
+		}
+		n, err := PurgeOutbox(tx, time.Now().Add(time.Second))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if n != 3 {
+			return e.New("wrong number of purged messages %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
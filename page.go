@@ -0,0 +1,51 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import "github.com/fcavani/e"
+
+// Page returns at most limit entries starting offset entries after the first
+// one, in the order given by Reverse and under the prefix of Init. The keys
+// and values are copied. The cursor stays on the last entry returned, so
+// Next continues after the page. A page past the end is empty.
+func (c *Cursor) Page(offset, limit uint64) ([]KV, error) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	defer c.slow("Page")()
+
+	if c.ls >= len(c.cursors) || c.cursors[c.ls] == nil {
+		return nil, newKeyError(ErrInvBucket, c.Bucket, -1, c.skip)
+	}
+
+	c.saveState()
+	var kvs []KV
+	for k, v := c.start(c.skipN(offset)); k != nil && uint64(len(kvs)) < limit; k, v = c.step(c.next, c.prev) {
+		kv := KV{Keys: copyKeys(k)}
+		if !c.keysOnly {
+			buf, err := decodeValue(c.Bucket, k, v)
+			if err != nil {
+				c.restoreState()
+				return nil, e.Push(err, e.New("%v at %v%v", ErrDecode, string(c.Bucket), Path(k)))
+			}
+			kv.Value = append([]byte{}, buf...)
+		}
+		kvs = append(kvs, kv)
+		if uint64(len(kvs)) == limit {
+			break
+		}
+	}
+	if len(kvs) == 0 {
+		c.restoreState()
+	}
+	if c.Debug {
+		c.report.EntriesReturned += uint64(len(kvs))
+	}
+	if c.err != nil {
+		err := c.err
+		c.err = nil
+		return nil, err
+	}
+	return kvs, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorPage(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// 2 languages, 3 years and 4 posts.
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, lang := range []string{"en", "pt-br"} {
+			for y := 2013; y < 2016; y++ {
+				for p := 0; p < 4; p++ {
+					keys := [][]byte{
+						[]byte(lang),
+						[]byte(strconv.Itoa(y)),
+						EncodeInt64(int64(p)),
+						[]byte("title"),
+					}
+					err := Put(tx, []byte("test_bucket"), keys, []byte(lang+strconv.Itoa(y)+strconv.Itoa(p)))
+					if err != nil {
+						return e.Forward(err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	for _, reverse := range []bool{false, true} {
+		for _, prefix := range [][][]byte{nil, {[]byte("pt-br")}, {[]byte("en"), []byte("2014")}} {
+			err = db.View(func(tx *bolt.Tx) error {
+				c := &Cursor{
+					Tx:      tx,
+					Bucket:  []byte("test_bucket"),
+					NumKeys: 4,
+					Reverse: reverse,
+				}
+				err := c.Init(prefix...)
+				if err != nil {
+					return e.Forward(err)
+				}
+				var all [][]byte
+				for k, v := c.First(); k != nil; k, v = c.Next() {
+					all = append(all, append([]byte{}, v...))
+				}
+				for offset := uint64(0); offset <= uint64(len(all))+1; offset++ {
+					for limit := uint64(0); limit <= 5; limit++ {
+						kvs, err := c.Page(offset, limit)
+						if err != nil {
+							return e.Forward(err)
+						}
+						want := all
+						if offset < uint64(len(want)) {
+							want = want[offset:]
+						} else {
+							want = nil
+						}
+						if uint64(len(want)) > limit {
+							want = want[:limit]
+						}
+						if len(kvs) != len(want) {
+							return e.New("reverse %v prefix %v page %v %v: wrong length %v", reverse, len(prefix), offset, limit, len(kvs))
+						}
+						for i := range kvs {
+							if !bytes.Equal(kvs[i].Value, want[i]) {
+								return e.New("reverse %v prefix %v page %v %v: wrong entry %v", reverse, len(prefix), offset, limit, string(kvs[i].Value))
+							}
+						}
+					}
+				}
+				// Skip agrees with Page.
+				k, v := c.Skip(uint64(len(all) - 1))
+				if k == nil || !bytes.Equal(v, all[len(all)-1]) {
+					return e.New("skip to the last entry failed")
+				}
+				k, _ = c.Skip(uint64(len(all)))
+				if k != nil {
+					return e.New("skip past the end returned an entry")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(e.Trace(e.Forward(err)))
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/fcavani/e"
+)
+
+const ErrArity = "wrong number of keys"
+
+// Path is a key path. It can be given to every function that accepts a
+// [][]byte key path.
+type Path [][]byte
+
+// NewPath builds a path from its keys.
+func NewPath(keys ...[]byte) Path {
+	return Path(keys)
+}
+
+// ParsePath parses a path formatted by Path.String.
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimPrefix(s, "/")
+	if s == "" {
+		return Path{}, nil
+	}
+	parts := strings.Split(s, "/")
+	p := make(Path, len(parts))
+	for i, part := range parts {
+		key, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		p[i] = []byte(key)
+	}
+	return p, nil
+}
+
+// String formats the path as slash separated keys. Each key is escaped so
+// the path can be parsed back by ParsePath.
+func (p Path) String() string {
+	parts := make([]string, len(p))
+	for i, key := range p {
+		parts[i] = url.PathEscape(string(key))
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Append returns a new path with keys added at the end.
+func (p Path) Append(keys ...[]byte) Path {
+	out := make(Path, 0, len(p)+len(keys))
+	out = append(out, p...)
+	return append(out, keys...)
+}
+
+// Validate checks if the path has arity keys and that no key is empty.
+func (p Path) Validate(arity int) error {
+	if len(p) != arity {
+		return e.New(ErrArity)
+	}
+	for _, key := range p {
+		if len(key) == 0 {
+			return e.New("empty key")
+		}
+	}
+	return nil
+}
+
+// Layout names the levels of a bucket, from the first to the last key.
+type Layout []string
+
+// Path builds a path checking its arity against the layout.
+func (l Layout) Path(keys ...[]byte) (Path, error) {
+	p := NewPath(keys...)
+	err := p.Validate(len(l))
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return p, nil
+}
+
+// Prefix builds a partial path, with at most as many keys as the layout.
+func (l Layout) Prefix(keys ...[]byte) (Path, error) {
+	if len(keys) > len(l) {
+		return nil, e.New(ErrArity)
+	}
+	return NewPath(keys...), nil
+}
+
+// Level returns the name of the level i or an empty string.
+func (l Layout) Level(i int) string {
+	if i < 0 || i >= len(l) {
+		return ""
+	}
+	return l[i]
+}
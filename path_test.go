@@ -0,0 +1,49 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fcavani/e"
+)
+
+func TestPath(t *testing.T) {
+	p := NewPath([]byte("pt-br"), []byte("2015"), []byte("a/b c"))
+	s := p.String()
+	if s != "/pt-br/2015/a%2Fb%20c" {
+		t.Fatal("wrong format", s)
+	}
+	p2, err := ParsePath(s)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(p2) != len(p) {
+		t.Fatal("wrong length", len(p2))
+	}
+	for i := range p {
+		if !bytes.Equal(p[i], p2[i]) {
+			t.Fatal("not equal", i, string(p2[i]))
+		}
+	}
+
+	layout := Layout{"lang", "year", "title"}
+	_, err = layout.Path([]byte("pt-br"), []byte("2015"))
+	if !e.Equal(err, ErrArity) {
+		t.Fatal("wrong arity accepted", err)
+	}
+	_, err = layout.Path(p...)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	_, err = layout.Prefix(p.Append([]byte("x"))...)
+	if !e.Equal(err, ErrArity) {
+		t.Fatal("wrong prefix accepted", err)
+	}
+	if layout.Level(1) != "year" || layout.Level(3) != "" {
+		t.Fatal("wrong level name")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrPoolClosed = "cursor pool closed"
+
+// CursorPool hands out initialized read-only cursors over one bucket. A
+// returned cursor keeps its transaction open for MaxAge, and the next Get
+// only pins the cursor to the new keys, skipping the cost of beginning a
+// transaction and of Init. Bolt transactions can't be shared between
+// goroutines, so each cursor has its own transaction and belongs to one
+// caller from Get until Put.
+type CursorPool struct {
+	DB      *bolt.DB
+	Bucket  []byte
+	NumKeys int
+	// MaxAge is how long the transaction of a cursor is reused. Old
+	// transactions keep the database from reclaiming pages and don't see
+	// the recent writes. Zero begins a new transaction for each Get.
+	MaxAge time.Duration
+	// MaxIdle is the maximum number of cursors waiting for a Get.
+	MaxIdle int
+
+	lck     sync.Mutex
+	idle    []*Cursor
+	started map[*Cursor]time.Time
+	closed  bool
+}
+
+// Get returns a cursor pinned to keys, like Cursor.Init. The cursor must be
+// given back with Put, never with Commit or Rollback.
+func (p *CursorPool) Get(keys ...[]byte) (*Cursor, error) {
+	p.lck.Lock()
+	if p.closed {
+		p.lck.Unlock()
+		return nil, e.New(ErrPoolClosed)
+	}
+	if p.started == nil {
+		p.started = make(map[*Cursor]time.Time)
+	}
+	var c *Cursor
+	for len(p.idle) > 0 && c == nil {
+		c = p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.expired(c) {
+			p.discard(c)
+			c = nil
+		}
+	}
+	p.lck.Unlock()
+
+	if c != nil {
+		c.Reverse = false
+		c.filters = nil
+		c.decoder = nil
+		c.err = nil
+		c.report = Report{}
+		err := c.pin(c.Transforms.Apply(keys))
+		if err != nil {
+			p.Put(c)
+			return nil, e.Forward(err)
+		}
+		return c, nil
+	}
+
+	tx, err := p.DB.Begin(false)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	c = &Cursor{
+		Tx:      tx,
+		Bucket:  p.Bucket,
+		NumKeys: p.NumKeys,
+	}
+	p.lck.Lock()
+	p.started[c] = time.Now()
+	p.lck.Unlock()
+	err = c.Init(keys...)
+	if err != nil {
+		p.Put(c)
+		return nil, e.Forward(err)
+	}
+	return c, nil
+}
+
+// Put gives back a cursor returned by Get. The cursor must not be used after.
+func (p *CursorPool) Put(c *Cursor) {
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	if p.closed || p.expired(c) || len(p.idle) >= p.MaxIdle {
+		p.discard(c)
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+// Close ends the transactions of the idle cursors. The cursors out of the
+// pool have their transactions ended by Put.
+func (p *CursorPool) Close() error {
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	p.closed = true
+	for _, c := range p.idle {
+		p.discard(c)
+	}
+	p.idle = nil
+	return nil
+}
+
+func (p *CursorPool) expired(c *Cursor) bool {
+	started, ok := p.started[c]
+	return !ok || time.Since(started) >= p.MaxAge
+}
+
+func (p *CursorPool) discard(c *Cursor) {
+	delete(p.started, c)
+	c.Tx.Rollback()
+}
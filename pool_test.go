@@ -0,0 +1,125 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorPool(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	pool := &CursorPool{
+		DB:      db,
+		Bucket:  []byte("test_bucket"),
+		NumKeys: 3,
+		MaxAge:  time.Minute,
+		MaxIdle: 4,
+	}
+
+	c, err := pool.Get([]byte("2014"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	tx := c.Tx
+	pool.Put(c)
+	c, err = pool.Get([]byte("2015"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if c.Tx != tx {
+		t.Fatal("transaction not reused")
+	}
+	k, v := c.First()
+	if k == nil || string(v) != "3" {
+		t.Fatal("wrong entry", string(v))
+	}
+	pool.Put(c)
+
+	_, err = pool.Get([]byte("2016"))
+	if !IsError(err, ErrKeyNotFound) {
+		t.Fatal("expected ErrKeyNotFound", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := pool.Get([]byte("2014"))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer pool.Put(c)
+			n := 0
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				n++
+			}
+			if n != 2 {
+				errs <- e.New("wrong number of entries %v", n)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = pool.Close()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	_, err = pool.Get()
+	if !IsError(err, ErrPoolClosed) {
+		t.Fatal("expected ErrPoolClosed", err)
+	}
+	// All the read transactions must be closed.
+	if n := db.Stats().OpenTxN; n != 0 {
+		t.Fatal("open transactions", n)
+	}
+}
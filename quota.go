@@ -0,0 +1,185 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// quotaBucket has the limits and the usage of the top buckets with quota.
+const quotaBucket = "__quota"
+
+const ErrQuotaExceeded = "quota exceeded"
+
+// Quota limits the leaves of a top bucket. A zero limit is unlimited.
+type Quota struct {
+	MaxEntries uint64
+	// MaxBytes limits the size of the last keys and values of the leaves,
+	// like Stats.Bytes.
+	MaxBytes uint64
+}
+
+// Usage is what a top bucket holds.
+type Usage struct {
+	Entries uint64
+	Bytes   uint64
+}
+
+// SetQuota sets the quota of bucket. The usage is counted now and then kept
+// updated by Put, PutBatch and Del, that fail with ErrQuotaExceeded when a
+// write goes over the limits. Writes that reduce the usage always succeed.
+func SetQuota(tx *bolt.Tx, bucket []byte, q Quota) error {
+	var u Usage
+	if b := tx.Bucket(bucket); b != nil {
+		s := treeStats(tx, b)
+		u = Usage{Entries: s.Entries, Bytes: s.Bytes}
+	}
+	return putQuota(tx, bucket, q, u)
+}
+
+// RemoveQuota removes the quota of bucket.
+func RemoveQuota(tx *bolt.Tx, bucket []byte) error {
+	b := tx.Bucket([]byte(quotaBucket))
+	if b == nil {
+		return nil
+	}
+	err := b.Delete(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(b) {
+		err = tx.DeleteBucket([]byte(quotaBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// QuotaUsage returns the quota and the usage of bucket. Without a quota the
+// usage is counted reading the whole tree.
+func QuotaUsage(tx *bolt.Tx, bucket []byte) (Quota, Usage, error) {
+	if q, u, ok := quotaOf(tx, bucket); ok {
+		return q, u, nil
+	}
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return Quota{}, Usage{}, newKeyError(ErrInvBucket, bucket, -1, nil)
+	}
+	s := treeStats(tx, b)
+	return Quota{}, Usage{Entries: s.Entries, Bytes: s.Bytes}, nil
+}
+
+func quotaOf(tx *bolt.Tx, bucket []byte) (Quota, Usage, bool) {
+	b := tx.Bucket([]byte(quotaBucket))
+	if b == nil {
+		return Quota{}, Usage{}, false
+	}
+	buf := b.Get(bucket)
+	if len(buf) != 32 {
+		return Quota{}, Usage{}, false
+	}
+	q := Quota{
+		MaxEntries: binary.BigEndian.Uint64(buf[0:]),
+		MaxBytes:   binary.BigEndian.Uint64(buf[8:]),
+	}
+	u := Usage{
+		Entries: binary.BigEndian.Uint64(buf[16:]),
+		Bytes:   binary.BigEndian.Uint64(buf[24:]),
+	}
+	return q, u, true
+}
+
+func putQuota(tx *bolt.Tx, bucket []byte, q Quota, u Usage) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(quotaBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	buf := make([]byte, 32)
+	binary.BigEndian.PutUint64(buf[0:], q.MaxEntries)
+	binary.BigEndian.PutUint64(buf[8:], q.MaxBytes)
+	binary.BigEndian.PutUint64(buf[16:], u.Entries)
+	binary.BigEndian.PutUint64(buf[24:], u.Bytes)
+	err = b.Put(bucket, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// charge adds the deltas to the usage of bucket. It fails if the usage grows
+// over the quota.
+func charge(tx *bolt.Tx, bucket []byte, keys [][]byte, entries, bytes int64) error {
+	q, u, ok := quotaOf(tx, bucket)
+	if !ok || entries == 0 && bytes == 0 {
+		return nil
+	}
+	u.Entries = addDelta(u.Entries, entries)
+	u.Bytes = addDelta(u.Bytes, bytes)
+	if entries > 0 && q.MaxEntries > 0 && u.Entries > q.MaxEntries ||
+		bytes > 0 && q.MaxBytes > 0 && u.Bytes > q.MaxBytes {
+		return newKeyError(ErrQuotaExceeded, bucket, len(keys)-1, keys)
+	}
+	return putQuota(tx, bucket, q, u)
+}
+
+func addDelta(n uint64, delta int64) uint64 {
+	if delta < 0 && uint64(-delta) > n {
+		return 0
+	}
+	return uint64(int64(n) + delta)
+}
+
+// leafSize returns the size of the leaf in the key path, as counted by the
+// quota, and if it exists.
+func leafSize(tx *bolt.Tx, bucket []byte, keys [][]byte) (int64, bool) {
+	b, err := prefixBucket(tx, bucket, keys[:len(keys)-1])
+	if err != nil {
+		return 0, false
+	}
+	last := keys[len(keys)-1]
+	v := b.Get(last)
+	if v == nil || subBucket(tx, v) != nil {
+		return 0, false
+	}
+	return int64(len(last) + len(v)), true
+}
+
+// quotaPut charges the write of data in the key path.
+func quotaPut(tx *bolt.Tx, bucket []byte, keys [][]byte, data []byte) error {
+	if _, _, ok := quotaOf(tx, bucket); !ok {
+		return nil
+	}
+	size := int64(len(keys[len(keys)-1]) + len(data))
+	old, exists := leafSize(tx, bucket, keys)
+	if exists {
+		return charge(tx, bucket, keys, 0, size-old)
+	}
+	return charge(tx, bucket, keys, 1, size)
+}
+
+// quotaDel releases the leaf in the key path.
+func quotaDel(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
+	if _, _, ok := quotaOf(tx, bucket); !ok {
+		return nil
+	}
+	old, exists := leafSize(tx, bucket, keys)
+	if !exists {
+		return nil
+	}
+	return charge(tx, bucket, keys, -1, -old)
+}
+
+// recountQuota counts again the usage of bucket, if it has a quota.
+func recountQuota(tx *bolt.Tx, bucket []byte) error {
+	q, _, ok := quotaOf(tx, bucket)
+	if !ok {
+		return nil
+	}
+	return SetQuota(tx, bucket, q)
+}
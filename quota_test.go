@@ -0,0 +1,109 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestQuota(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	bucket := []byte("test_quota")
+	key := func(s string) [][]byte {
+		return [][]byte{[]byte("tenant"), []byte(s)}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// 1 byte key and 4 bytes value.
+		err := Put(tx, bucket, key("a"), []byte("aaaa"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = SetQuota(tx, bucket, Quota{MaxEntries: 3, MaxBytes: 12})
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, u, err := QuotaUsage(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if u != (Usage{Entries: 1, Bytes: 5}) {
+			return e.New("wrong initial usage %#v", u)
+		}
+		err = Put(tx, bucket, key("b"), []byte("bbbb"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Over the bytes.
+		err = Put(tx, bucket, key("c"), []byte("cccc"))
+		if !IsError(err, ErrQuotaExceeded) {
+			return e.New("expected ErrQuotaExceeded %v", err)
+		}
+		// Shrinking is allowed.
+		err = Put(tx, bucket, key("a"), []byte("a"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = PutBatch(tx, bucket, []Item{{Keys: key("c"), Data: []byte("c")}})
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Over the entries.
+		err = Put(tx, bucket, key("d"), []byte{})
+		if !IsError(err, ErrQuotaExceeded) {
+			return e.New("expected ErrQuotaExceeded %v", err)
+		}
+		err = Del(tx, bucket, key("b"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		q, u, err := QuotaUsage(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if q.MaxEntries != 3 || u != (Usage{Entries: 2, Bytes: 4}) {
+			return e.New("wrong usage %#v", u)
+		}
+		err = DropBucket(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, u, _ = QuotaUsage(tx, bucket)
+		if u != (Usage{}) {
+			return e.New("usage not reset %#v", u)
+		}
+		err = RemoveQuota(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if tx.Bucket([]byte(quotaBucket)) != nil {
+			return e.New("empty quota bucket not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// rateLimitBucket maps the encoded key paths to the state of their token
+// buckets. It is written directly, without the hooks of Put, so the state
+// isn't in the changelog, the replication or the watchers.
+const rateLimitBucket = "__ratelimit"
+
+// Allow takes one token from the token bucket stored under keys and reports
+// if the token was available. The bucket refills at rate tokens per second
+// up to burst tokens. The state survives restarts because it is stored in
+// the database, tx must be writable.
+func Allow(tx *bolt.Tx, keys [][]byte, rate float64, burst int, now time.Time) (bool, error) {
+	if len(keys) == 0 {
+		return false, newKeyError(ErrNoKeys, []byte(rateLimitBucket), -1, nil)
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(rateLimitBucket))
+	if err != nil {
+		return false, e.Forward(err)
+	}
+	id := encodeKeys(keys...)
+	tokens := float64(burst)
+	last := now
+	if buf := b.Get(id); len(buf) == 16 {
+		tokens = math.Float64frombits(binary.BigEndian.Uint64(buf[:8]))
+		last = time.Unix(0, int64(binary.BigEndian.Uint64(buf[8:])))
+	}
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(burst), tokens+elapsed*rate)
+	}
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	state := make([]byte, 16)
+	binary.BigEndian.PutUint64(state[:8], math.Float64bits(tokens))
+	binary.BigEndian.PutUint64(state[8:], uint64(now.UnixNano()))
+	err = b.Put(id, state)
+	if err != nil {
+		return false, e.Forward(err)
+	}
+	return allowed, nil
+}
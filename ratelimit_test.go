@@ -0,0 +1,110 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestAllow(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	keys := [][]byte{[]byte("user1"), []byte("/posts")}
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		at      time.Duration
+		allowed bool
+	}{
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+		{500 * time.Millisecond, false},
+		{time.Second, true},
+		{time.Second, false},
+		{time.Hour, true},
+		{time.Hour, true},
+		{time.Hour, true},
+		{time.Hour, false},
+	}
+	for i, test := range tests {
+		err = db.Update(func(tx *bolt.Tx) error {
+			ok, err := Allow(tx, keys, 1, 3, now.Add(test.at))
+			if err != nil {
+				return e.Forward(err)
+			}
+			if ok != test.allowed {
+				return e.New("test %v: expected %v", i, test.allowed)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		ok, err := Allow(tx, [][]byte{[]byte("user2"), []byte("/posts")}, 1, 3, now)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !ok {
+			return e.New("other key path shares the bucket")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestAllowNoHooks(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	keys := [][]byte{[]byte("user"), []byte("/posts")}
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := EnableChangelog(tx, []byte(rateLimitBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+		_, err = Allow(tx, keys, 1, 3, time.Now())
+		if err != nil {
+			return e.Forward(err)
+		}
+		if seq := ChangelogSeq(tx); seq != 0 {
+			return e.New("state in the changelog: %v", seq)
+		}
+		// One flat bucket, without intermediate buckets.
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if isUUID(name) {
+				return e.New("intermediate bucket %v", string(name))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
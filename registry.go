@@ -0,0 +1,140 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// registryBucket maps the user buckets to their BucketInfo.
+const registryBucket = "__registry"
+
+const ErrNotRegistered = "bucket not registered"
+
+// BucketInfo describes how a user bucket is organized, so the code that reads
+// it doesn't need to repeat the settings.
+type BucketInfo struct {
+	// NumKeys is the number of levels of the tree.
+	NumKeys int
+	// Codecs names the codecs of the values, in the order they are applied.
+	Codecs []string `json:",omitempty"`
+	// Strict makes Put and PutBatch reject the key paths with other than
+	// NumKeys keys. Leaves at different depths are read by the cursors as
+	// intermediate buckets.
+	Strict bool `json:",omitempty"`
+}
+
+// Register records the description of bucket.
+func Register(tx *bolt.Tx, bucket []byte, info BucketInfo) error {
+	buf, err := json.Marshal(info)
+	if err != nil {
+		return e.Forward(err)
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(registryBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(bucket, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Unregister removes the description of bucket.
+func Unregister(tx *bolt.Tx, bucket []byte) error {
+	b := tx.Bucket([]byte(registryBucket))
+	if b == nil {
+		return nil
+	}
+	err := b.Delete(bucket)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(b) {
+		err = tx.DeleteBucket([]byte(registryBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the description of bucket or ErrNotRegistered.
+func Lookup(tx *bolt.Tx, bucket []byte) (BucketInfo, error) {
+	var info BucketInfo
+	b := tx.Bucket([]byte(registryBucket))
+	if b == nil {
+		return info, newKeyError(ErrNotRegistered, bucket, -1, nil)
+	}
+	buf := b.Get(bucket)
+	if buf == nil {
+		return info, newKeyError(ErrNotRegistered, bucket, -1, nil)
+	}
+	err := json.Unmarshal(buf, &info)
+	if err != nil {
+		return info, e.Forward(err)
+	}
+	return info, nil
+}
+
+// Registered returns the descriptions of all registered buckets.
+func Registered(tx *bolt.Tx) (map[string]BucketInfo, error) {
+	m := make(map[string]BucketInfo)
+	b := tx.Bucket([]byte(registryBucket))
+	if b == nil {
+		return m, nil
+	}
+	err := b.ForEach(func(k, v []byte) error {
+		var info BucketInfo
+		err := json.Unmarshal(v, &info)
+		if err != nil {
+			return e.Push(err, e.New("invalid registry entry for %v", string(k)))
+		}
+		m[string(k)] = info
+		return nil
+	})
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return m, nil
+}
+
+// writeInfo returns the description of bucket applied to the writes of its
+// leaves, the zero BucketInfo if it isn't registered. Put reads it once and
+// PutBatch once for each batch. The temporary bucket of migrate has the
+// Codecs of the bucket migrated.
+func writeInfo(tx *bolt.Tx, bucket []byte) (BucketInfo, error) {
+	if tx.Bucket([]byte(registryBucket)) == nil {
+		return BucketInfo{}, nil
+	}
+	if name := bytes.TrimPrefix(bucket, []byte(migratePrefix)); len(name) < len(bucket) {
+		info, err := writeInfo(tx, name)
+		if err != nil {
+			return BucketInfo{}, e.Forward(err)
+		}
+		return BucketInfo{Codecs: info.Codecs}, nil
+	}
+	info, err := Lookup(tx, bucket)
+	if IsError(err, ErrNotRegistered) {
+		return BucketInfo{}, nil
+	} else if err != nil {
+		return BucketInfo{}, e.Forward(err)
+	}
+	return info, nil
+}
+
+// checkArity returns ErrArity if info is Strict and keys doesn't have
+// NumKeys keys.
+func checkArity(info *BucketInfo, bucket []byte, keys [][]byte) error {
+	if info.Strict && len(keys) != info.NumKeys {
+		return newKeyError(ErrArity, bucket, -1, keys)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrInvPermutation = "invalid permutation"
+
+const ErrVerify = "verification failed"
+
+// MigrateChunk is the maximum number of leaves copied by each transaction in
+// the migrations.
+var MigrateChunk = 1000
+
+// migratePrefix names the temporary bucket of migrate. It is encrypted with
+// the key of the bucket migrated.
+const migratePrefix = "__migrate_"
+
+// ReorderLevels rewrites the tree of bucket with the key levels in a new
+// order. The level i of the new tree is the level permutation[i] of the old
+// one, so to move the second level to the top of a three levels tree use
+// []int{1, 0, 2}. Every leaf must have len(permutation) keys.
+//
+// The bucket is frozen while the new tree is built in MigrateChunk sized
+// transactions. The new tree is verified against the old one before it
+// replaces the bucket, in one transaction. The migration as a whole isn't
+// atomic: on an error the copy is dropped and the bucket unfrozen, but after
+// a crash the bucket, with its tree intact, stays frozen and the partial
+// copy stays in the bucket named by the prefix "__migrate_". Unfreeze the
+// bucket and call it again, the copy left is dropped before a new one is
+// made.
+func ReorderLevels(db *bolt.DB, bucket []byte, permutation []int) error {
+	err := checkPermutation(permutation)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = migrate(db, bucket, func(keys [][]byte) ([][]byte, error) {
+		if len(keys) != len(permutation) {
+			return nil, newKeyError(ErrArity, bucket, -1, keys)
+		}
+		out := make([][]byte, len(keys))
+		for i, p := range permutation {
+			out[i] = keys[p]
+		}
+		return out, nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+func checkPermutation(permutation []int) error {
+	if len(permutation) == 0 {
+		return e.New(ErrInvPermutation)
+	}
+	seen := make([]bool, len(permutation))
+	for _, p := range permutation {
+		if p < 0 || p >= len(permutation) || seen[p] {
+			return e.New(ErrInvPermutation)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// migrate copies every leaf of bucket to the key path returned by rewrite in
+// a temporary bucket, verifies the copy and replaces bucket with it. The
+// bucket stays frozen during the copy. rewrite must be a one to one mapping.
+// See ReorderLevels for what an interrupted migration leaves.
+func migrate(db *bolt.DB, bucket []byte, rewrite func(keys [][]byte) ([][]byte, error)) (err error) {
+	tmp := append([]byte(migratePrefix), bucket...)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucket) == nil {
+			return newKeyError(ErrInvBucket, bucket, -1, nil)
+		}
+		if IsFrozen(tx, bucket, nil) {
+			return newKeyError(ErrFrozen, bucket, 0, nil)
+		}
+		// Left by an interrupted migration.
+		if tx.Bucket(tmp) != nil {
+			err := DropBucket(tx, tmp)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		_, err := tx.CreateBucket(tmp)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Freeze(tx, bucket, nil)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		db.Update(func(tx *bolt.Tx) error {
+			if tx.Bucket(tmp) != nil {
+				DropBucket(tx, tmp)
+			}
+			return Unfreeze(tx, bucket, nil)
+		})
+	}()
+
+	var after [][]byte
+	for done := false; !done; {
+		err = db.Update(func(tx *bolt.Tx) error {
+			items, err := nextLeaves(tx, bucket, after, MigrateChunk)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if len(items) < MigrateChunk {
+				done = true
+			}
+			if len(items) == 0 {
+				return nil
+			}
+			after = items[len(items)-1].Keys
+			for i := range items {
+				items[i].Keys, err = rewrite(items[i].Keys)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return PutBatch(tx, tmp, items)
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		var n uint64
+		err := walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+			n++
+			nk, err := rewrite(keys)
+			if err != nil {
+				return e.Forward(err)
+			}
+			nv, err := Get(tx, tmp, nk)
+			if err != nil || !bytes.Equal(v, nv) {
+				return newKeyError(ErrVerify, bucket, -1, copyKeys(keys))
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if m := treeStats(tx, tx.Bucket(tmp)).Entries; m != n {
+			return e.New("%v: %v leaves in the bucket and %v in the copy", ErrVerify, n, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := Unfreeze(tx, bucket, nil)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return PromoteBucket(tx, tmp, bucket)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestReorderLevels(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("en"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014"), []byte("pt-br"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("en"), []byte("c")}, []byte("3")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("pt-br"), []byte("d")}, []byte("4")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015"), []byte("pt-br"), []byte("e")}, []byte("5")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = ReorderLevels(db, []byte("test_bucket"), []int{0, 0, 2})
+	if !IsError(err, ErrInvPermutation) {
+		t.Fatal("expected ErrInvPermutation", err)
+	}
+
+	old := MigrateChunk
+	MigrateChunk = 2
+	defer func() {
+		MigrateChunk = old
+	}()
+
+	err = ReorderLevels(db, []byte("test_bucket"), []int{1, 0, 2})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			keys := [][]byte{d.Keys[1], d.Keys[0], d.Keys[2]}
+			v, err := Get(tx, d.Bucket, keys)
+			if err != nil {
+				return e.Push(err, e.New("Fail to get %v", i))
+			}
+			if !bytes.Equal(v, d.Data) {
+				return e.New("not equal %v %v", i, string(v))
+			}
+			_, err = Get(tx, d.Bucket, d.Keys)
+			if !IsError(err, ErrKeyNotFound) {
+				return e.New("old key path %v still exists", i)
+			}
+		}
+		if IsFrozen(tx, []byte("test_bucket"), nil) {
+			return e.New("bucket still frozen")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		n := 0
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if bytes.HasPrefix(name, []byte("__")) && string(name) != bucketsBucket {
+				return e.New("bucket %v left behind", string(name))
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		// test_bucket, __buckets, en, en/2014, en/2015, pt-br,
+		// pt-br/2014 and pt-br/2015.
+		if n != 8 {
+			return e.New("wrong number of buckets %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// LostFoundKey is the key of the first level where RepairIndex links the
+// unreferenced subtrees.
+const LostFoundKey = "__lost+found"
+
+// ProblemOrphan is an intermediate bucket not referenced by any tree, linked
+// by RepairIndex under LostFoundKey.
+const ProblemOrphan = "unreferenced intermediate bucket"
+
+// RepairOptions are the optional repairs of RepairIndex.
+type RepairOptions struct {
+	// LostFound links the unreferenced intermediate buckets with keys
+	// under LostFoundKey, the key being the name of the bucket.
+	LostFound bool
+}
+
+// RepairIndex fixes the problems of the tree of bucket found by CheckIndex
+// that don't lose leaves: it deletes the dangling intermediate keys and the
+// empty intermediate buckets with their keys, until the buckets emptied by
+// the deletions are gone too. It returns the problems fixed. The other
+// problems are left to the application, CheckIndex still reports them.
+//
+// With LostFound the intermediate buckets that no tree of the database
+// references are linked under LostFoundKey, one level deeper than they were.
+// The orphans of all trees are linked, so use it in a database with one tree
+// or run GC for the others first. The ETags, the TTLs and the changelog
+// aren't changed. The leaves of an EncryptedBucket linked there can't be
+// decrypted, the key path they are bound to is lost.
+func RepairIndex(tx *bolt.Tx, bucket []byte, opts RepairOptions) ([]Problem, error) {
+	var fixed []Problem
+	for {
+		problems, err := CheckIndex(tx, bucket)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		n := len(fixed)
+		for _, p := range problems {
+			if p.Kind != ProblemDangling && p.Kind != ProblemEmpty {
+				continue
+			}
+			err = unlink(tx, bucket, p)
+			if err != nil {
+				return nil, e.Forward(err)
+			}
+			fixed = append(fixed, p)
+		}
+		if len(fixed) == n {
+			break
+		}
+	}
+	if opts.LostFound {
+		found, err := linkOrphans(tx, bucket)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		fixed = append(fixed, found...)
+	}
+	return fixed, nil
+}
+
+// unlink deletes the key of p and the empty intermediate bucket it names.
+func unlink(tx *bolt.Tx, bucket []byte, p Problem) error {
+	parent, err := prefixBucket(tx, bucket, p.Keys[:len(p.Keys)-1])
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = parent.Delete(p.Keys[len(p.Keys)-1])
+	if err != nil {
+		return e.Forward(err)
+	}
+	if p.Kind != ProblemEmpty {
+		return nil
+	}
+	err = tx.DeleteBucket(p.Value)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = countBuckets(tx, bucket, -1)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// linkOrphans links the roots of the unreferenced subtrees under
+// LostFoundKey. The empty ones are left to GC.
+func linkOrphans(tx *bolt.Tx, bucket []byte) ([]Problem, error) {
+	reachable := make(map[string]bool)
+	var ids [][]byte
+	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if isUUID(name) || isCompactID(name) {
+			ids = append(ids, append([]byte{}, name...))
+			return nil
+		}
+		mark(tx, b, reachable)
+		return nil
+	})
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	// The orphans referenced by other orphans come along with them.
+	inner := make(map[string]bool)
+	for _, id := range ids {
+		if !reachable[string(id)] {
+			mark(tx, tx.Bucket(id), inner)
+		}
+	}
+	var found []Problem
+	var lf *bolt.Bucket
+	for _, id := range ids {
+		if reachable[string(id)] || inner[string(id)] || empty(tx.Bucket(id)) {
+			continue
+		}
+		if lf == nil {
+			lf, err = child(tx, bucket, tx.Bucket(bucket), []byte(LostFoundKey))
+			if err != nil {
+				return nil, e.Forward(err)
+			}
+		}
+		lost := [][]byte{[]byte(LostFoundKey), id}
+		err = countMove(tx, bucket, lost, id)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		err = lf.Put(id, id)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		err = countBuckets(tx, bucket, int64(treeStats(tx, tx.Bucket(id)).Buckets)+1)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		found = append(found, Problem{
+			Kind:  ProblemOrphan,
+			Keys:  lost,
+			Value: id,
+		})
+	}
+	return found, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestRepairIndex(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := breakIndex(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		// A subtree lost by its key.
+		err = Put(tx, bucket, [][]byte{[]byte("lost"), []byte("1")}, []byte("found"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		lost := append([]byte{}, tx.Bucket(bucket).Get([]byte("lost"))...)
+		err = tx.Bucket(bucket).Delete([]byte("lost"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = Register(tx, bucket, BucketInfo{NumKeys: 2})
+		if err != nil {
+			return e.Forward(err)
+		}
+		live := liveBuckets(tx.Bucket([]byte(bucketsBucket)), bucket)
+
+		fixed, err := RepairIndex(tx, bucket, RepairOptions{})
+		if err != nil {
+			return e.Forward(err)
+		}
+		want := "dangling intermediate key at /dangling; " +
+			"empty intermediate bucket at /empty"
+		if got := problemsString(fixed); got != want {
+			return e.New("wrong repairs:\n%v\nwant\n%v", got, want)
+		}
+		problems, err := CheckIndex(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		want = "intermediate bucket shared at /shared2; " +
+			"leaf in the wrong level at /collision; " +
+			"leaf in the wrong level at /shallow; " +
+			"leaf value names a bucket at /a/2"
+		if got := problemsString(problems); got != want {
+			return e.New("wrong problems left:\n%v\nwant\n%v", got, want)
+		}
+		if tx.Bucket([]byte("00000000-0000-0000-0000-000000000001")) != nil {
+			return e.New("empty bucket not deleted")
+		}
+
+		fixed, err = RepairIndex(tx, bucket, RepairOptions{LostFound: true})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(fixed) != 1 || fixed[0].Kind != ProblemOrphan || !bytes.Equal(fixed[0].Value, lost) {
+			return e.New("wrong lost+found: %v", problemsString(fixed))
+		}
+		v, err := Get(tx, bucket, [][]byte{[]byte(LostFoundKey), lost, []byte("1")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "found" {
+			return e.New("wrong value in lost+found: %q", v)
+		}
+		// lost+found and lost came in.
+		if got := liveBuckets(tx.Bucket([]byte(bucketsBucket)), bucket); got != live+1 {
+			return e.New("wrong bucket count %v, want %v", got, live+1)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// An intermediate bucket left with only a dangling key is removed after
+	// the key.
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := []byte("test_cascade")
+		err := Put(tx, bucket, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, []byte("v"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		b, err := prefixBucket(tx, bucket, [][]byte{[]byte("a")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = b.Put([]byte("b"), []byte("00000000-0000-0000-0000-000000000002"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		fixed, err := RepairIndex(tx, bucket, RepairOptions{})
+		if err != nil {
+			return e.Forward(err)
+		}
+		want := "dangling intermediate key at /a/b; empty intermediate bucket at /a"
+		if got := problemsString(fixed); got != want {
+			return e.New("wrong repairs:\n%v\nwant\n%v", got, want)
+		}
+		if !empty(tx.Bucket(bucket)) {
+			return e.New("cascade not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
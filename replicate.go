@@ -0,0 +1,272 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// replicaBucket has the resume token of a follower database.
+const replicaBucket = "__replica"
+
+var replicaTokenKey = []byte("token")
+
+// Resolver decides if a change that conflicts with the follower is applied.
+// A Put conflicts if the follower has a different value in the key path and
+// a Del conflicts if the follower doesn't have the key path. current is the
+// value in the follower, nil if it doesn't exist.
+type Resolver func(ch *Change, current []byte) bool
+
+// KeepFollower is a Resolver that keeps the changes made in the follower.
+func KeepFollower(ch *Change, current []byte) bool {
+	return false
+}
+
+// Replicator copies the changes recorded in the changelog of Source, see
+// EnableChangelog, to a follower database or to a stream.
+type Replicator struct {
+	Source *bolt.DB
+	// Target is the follower database. Its resume token is stored in it, in
+	// the same transaction as the changes.
+	Target *bolt.DB
+	// Stream receives the changes, encoded by WriteChange, if Target is nil.
+	// The changes written before a failed write are sent again.
+	Stream io.Writer
+	// Token is the sequence number of the last change replicated. With a
+	// Stream the caller keeps it to resume.
+	Token uint64
+	// Resolve is called for the conflicting changes. Nil applies all
+	// changes.
+	Resolve Resolver
+	// Truncate removes the replicated changes from the changelog of Source.
+	// Use it only if there is one follower.
+	Truncate bool
+}
+
+// Replicate copies up to ChangesChunk changes and returns how many were
+// copied.
+func (r *Replicator) Replicate() (int, error) {
+	if r.Target != nil && r.Token == 0 {
+		err := r.Target.View(func(tx *bolt.Tx) error {
+			r.Token = ReplicaToken(tx)
+			return nil
+		})
+		if err != nil {
+			return 0, e.Forward(err)
+		}
+	}
+	var changes []Change
+	err := r.Source.View(func(tx *bolt.Tx) error {
+		var err error
+		changes, err = ReadChanges(tx, r.Token)
+		return err
+	})
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	last := changes[len(changes)-1].Seq
+	if r.Target != nil {
+		err = r.Target.Update(func(tx *bolt.Tx) error {
+			for i := range changes {
+				err := ApplyChange(tx, &changes[i], r.Resolve)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return setReplicaToken(tx, last)
+		})
+	} else {
+		for i := range changes {
+			err = WriteChange(r.Stream, &changes[i])
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return 0, e.Forward(err)
+	}
+	r.Token = last
+	if r.Truncate {
+		err = r.Source.Update(func(tx *bolt.Tx) error {
+			_, err := TruncateChanges(tx, last)
+			return err
+		})
+		if err != nil {
+			return len(changes), e.Forward(err)
+		}
+	}
+	return len(changes), nil
+}
+
+// Run calls Replicate every interval until stop is closed. Errors are sent
+// to errs if it isn't nil.
+func (r *Replicator) Run(interval time.Duration, stop <-chan struct{}, errs chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for {
+				n, err := r.Replicate()
+				if err != nil && errs != nil {
+					errs <- err
+				}
+				if err != nil || n < ChangesChunk {
+					break
+				}
+			}
+		}
+	}
+}
+
+// ReplicaToken returns the sequence number of the last change applied to a
+// follower database.
+func ReplicaToken(tx *bolt.Tx) uint64 {
+	b := tx.Bucket([]byte(replicaBucket))
+	if b == nil {
+		return 0
+	}
+	buf := b.Get(replicaTokenKey)
+	if len(buf) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+func setReplicaToken(tx *bolt.Tx, token uint64) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(replicaBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = b.Put(replicaTokenKey, seqKey(token))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// ApplyChange makes the change ch in tx. resolve, if not nil, decides the
+// conflicting changes.
+func ApplyChange(tx *bolt.Tx, ch *Change, resolve Resolver) error {
+	switch ch.Op {
+	case EventPut:
+		current, err := Get(tx, ch.Bucket, ch.Keys)
+		if err == nil && resolve != nil && !bytes.Equal(current, ch.Value) && !resolve(ch, current) {
+			return nil
+		}
+		err = Put(tx, ch.Bucket, ch.Keys, ch.Value)
+		if err != nil {
+			return e.Forward(err)
+		}
+	case EventDel:
+		return applyDel(tx, ch, resolve)
+	case EventDrop:
+		if tx.Bucket(ch.Bucket) == nil {
+			return nil
+		}
+		err := DropBucket(tx, ch.Bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+	default:
+		return e.New(ErrInvChange)
+	}
+	return nil
+}
+
+// applyDel removes the key path of ch. The changelog records a subtree moved
+// away as a Del of its key path, so a subtree is removed with everything
+// under it.
+func applyDel(tx *bolt.Tx, ch *Change, resolve Resolver) error {
+	if len(ch.Keys) == 0 {
+		return e.New(ErrInvChange)
+	}
+	parent, err := prefixBucket(tx, ch.Bucket, ch.Keys[:len(ch.Keys)-1])
+	var v []byte
+	if err == nil {
+		v = parent.Get(ch.Keys[len(ch.Keys)-1])
+	}
+	if v == nil {
+		if resolve != nil {
+			resolve(ch, nil)
+		}
+		return nil
+	}
+	v = append([]byte{}, v...)
+	// Del counts the leaves of the subtree before it is dropped.
+	err = Del(tx, ch.Bucket, ch.Keys)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if sub := subBucket(tx, v); sub != nil {
+		var n int64
+		err = dropTree(tx, sub, &n)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = tx.DeleteBucket(v)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = countBuckets(tx, ch.Bucket, -(n + 1))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// WriteChange writes ch to w prefixed by its size. The value is written as
+// it is in ch, decoded by ReadChanges, so the values of an EncryptedBucket
+// go in clear.
+func WriteChange(w io.Writer, ch *Change) error {
+	rec := encodeChange(ch)
+	buf := make([]byte, 12, 12+len(rec))
+	binary.BigEndian.PutUint32(buf, uint32(8+len(rec)))
+	binary.BigEndian.PutUint64(buf[4:], ch.Seq)
+	_, err := w.Write(append(buf, rec...))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// ReadChange reads a change written by WriteChange. It returns io.EOF at the
+// end of r.
+func ReadChange(r io.Reader) (*Change, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	if len(buf) < 8 {
+		return nil, e.New(ErrInvChange)
+	}
+	ch, err := decodeChange(buf[:8], buf[8:])
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return &ch, nil
+}
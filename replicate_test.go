@@ -0,0 +1,173 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func openTestDB(t testing.TB) *bolt.DB {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	return db
+}
+
+// dumpBucket returns the leaves of bucket as path=value lines.
+func dumpBucket(t *testing.T, db *bolt.DB, bucket []byte) string {
+	var buf bytes.Buffer
+	err := db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucket) == nil {
+			return nil
+		}
+		return walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+			buf.WriteString(Path(keys).String() + "=" + string(v) + "\n")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	return buf.String()
+}
+
+func TestReplicator(t *testing.T) {
+	src := openTestDB(t)
+	dst := openTestDB(t)
+	bucket := []byte("test_replica")
+	key := func(keys ...string) [][]byte {
+		var out [][]byte
+		for _, k := range keys {
+			out = append(out, []byte(k))
+		}
+		return out
+	}
+
+	err := src.Update(func(tx *bolt.Tx) error {
+		err := EnableChangelog(tx, bucket)
+		if err != nil {
+			return e.Forward(err)
+		}
+		for _, k := range []string{"a", "b", "c"} {
+			err = Put(tx, bucket, key("en", "2015", k), []byte(k))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		err = Del(tx, bucket, key("en", "2015", "b"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return MoveSubtree(tx, bucket, key("en", "2015"), key("pt", "2015"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var stream bytes.Buffer
+	sr := &Replicator{Source: src, Stream: &stream}
+	r := &Replicator{Source: src, Target: dst}
+	for _, rep := range []*Replicator{r, sr} {
+		_, err = rep.Replicate()
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	if got, want := dumpBucket(t, dst, bucket), dumpBucket(t, src, bucket); got != want {
+		t.Fatalf("follower differs:\n%v\n%v", got, want)
+	}
+	orphans, err := Verify(dst, [][]byte{bucket})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(orphans) != 0 {
+		t.Fatal("follower has orphan buckets", len(orphans))
+	}
+
+	// The stream rebuilds the bucket too.
+	other := openTestDB(t)
+	err = other.Update(func(tx *bolt.Tx) error {
+		for {
+			ch, err := ReadChange(&stream)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = ApplyChange(tx, ch, nil)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if got, want := dumpBucket(t, other, bucket), dumpBucket(t, src, bucket); got != want {
+		t.Fatalf("stream differs:\n%v\n%v", got, want)
+	}
+
+	// A new replicator resumes from the token in the follower and keeps
+	// the follower changes.
+	err = dst.Update(func(tx *bolt.Tx) error {
+		return Put(tx, bucket, key("pt", "2015", "a"), []byte("local"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = src.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, key("pt", "2015", "a"), []byte("remote"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Put(tx, bucket, key("pt", "2015", "d"), []byte("d"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	r = &Replicator{Source: src, Target: dst, Resolve: KeepFollower, Truncate: true}
+	n, err := r.Replicate()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 2 {
+		t.Fatal("wrong number of changes replicated", n)
+	}
+	want := "/pt/2015/a=local\n/pt/2015/c=c\n/pt/2015/d=d\n"
+	if got := dumpBucket(t, dst, bucket); got != want {
+		t.Fatalf("wrong follower:\n%v", got)
+	}
+	err = src.View(func(tx *bolt.Tx) error {
+		changes, err := ReadChanges(tx, 0)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if len(changes) != 0 {
+			return e.New("changes not truncated %v", len(changes))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+// Report is the execution report of a Cursor with Debug enabled. It shows
+// how much of the tree was read to answer the queries.
+type Report struct {
+	// BucketsOpened is the number of buckets opened, including the top
+	// bucket.
+	BucketsOpened uint64
+	// EntriesVisited is the number of entries read in all levels.
+	EntriesVisited uint64
+	// LeavesVisited is the number of entries read in the last level.
+	LeavesVisited uint64
+	// EntriesReturned is the number of leaves returned to the caller.
+	EntriesReturned uint64
+	// BytesVisited is the size of the keys and values read.
+	BytesVisited uint64
+	// PagesEstimated is an estimate of the pages touched.
+	PagesEstimated uint64
+}
+
+// EntriesSkipped is the number of leaves read but not returned.
+func (r Report) EntriesSkipped() uint64 {
+	if r.LeavesVisited < r.EntriesReturned {
+		return 0
+	}
+	return r.LeavesVisited - r.EntriesReturned
+}
+
+// Report returns the execution report since Init or the last ResetReport.
+// The cursor must have Debug set to true.
+func (c *Cursor) Report() Report {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	r := c.report
+	pageSize := uint64(c.Tx.DB().Info().PageSize)
+	if pageSize > 0 {
+		// Each bucket costs at least one page, the entries fill the others.
+		r.PagesEstimated = r.BucketsOpened + r.BytesVisited/pageSize
+	}
+	return r
+}
+
+// ResetReport zeros the execution report.
+func (c *Cursor) ResetReport() {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.report = Report{}
+}
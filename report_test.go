@@ -0,0 +1,100 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestCursorReport(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key1")}, []byte("11")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key2")}, []byte("12")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key1"), []byte("key3")}, []byte("13")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key2"), []byte("key1")}, []byte("21")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key2"), []byte("key2")}, []byte("22")},
+		{[]byte("test_bucket"), [][]byte{[]byte("key3"), []byte("key1")}, []byte("31")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_bucket"),
+			NumKeys: 2,
+			Debug:   true,
+		}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = skip(4, c, data)
+		if err != nil {
+			return e.Forward(err)
+		}
+		r := c.Report()
+		if r.EntriesReturned != 1 {
+			return e.New("wrong number of returned entries %v", r.EntriesReturned)
+		}
+		if r.LeavesVisited != 5 {
+			return e.New("wrong number of visited leaves %v", r.LeavesVisited)
+		}
+		if r.EntriesSkipped() != 4 {
+			return e.New("wrong number of skipped entries %v", r.EntriesSkipped())
+		}
+		// At least the top bucket and the buckets of key1 and key2.
+		if r.BucketsOpened < 3 {
+			return e.New("wrong number of opened buckets %v", r.BucketsOpened)
+		}
+		if r.PagesEstimated < r.BucketsOpened {
+			return e.New("wrong pages estimation %v", r.PagesEstimated)
+		}
+
+		c.ResetReport()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		}
+		r = c.Report()
+		if r.EntriesReturned != uint64(len(data)) {
+			return e.New("wrong number of returned entries %v", r.EntriesReturned)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/boltdb/bolt"
+)
+
+// PanicError is returned by SafeUpdate and SafeView when the closure panics.
+type PanicError struct {
+	// Value is the value given to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v\n%s", p.Value, p.Stack)
+}
+
+// safe calls fn and converts a panic into a PanicError.
+func safe(fn func(*bolt.Tx) error) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		return fn(tx)
+	}
+}
+
+// SafeUpdate is like bolt.DB.Update but a panic inside fn rolls back the
+// transaction and is returned as a *PanicError.
+func SafeUpdate(db *bolt.DB, fn func(*bolt.Tx) error) error {
+	return db.Update(safe(fn))
+}
+
+// SafeView is like bolt.DB.View but a panic inside fn is returned as a
+// *PanicError.
+func SafeView(db *bolt.DB, fn func(*bolt.Tx) error) error {
+	return db.View(safe(fn))
+}
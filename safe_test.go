@@ -0,0 +1,70 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestSafeUpdate(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	keys := [][]byte{[]byte("key1"), []byte("key2")}
+	err = SafeUpdate(db, func(tx *bolt.Tx) error {
+		err := Put(tx, []byte("test_bucket"), keys, []byte("data"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		var m map[string]int
+		m["boom"] = 1
+		return nil
+	})
+	p, ok := err.(*PanicError)
+	if !ok {
+		t.Fatal("panic not returned", err)
+	}
+	if len(p.Stack) == 0 {
+		t.Fatal("no stack trace")
+	}
+
+	err = SafeView(db, func(tx *bolt.Tx) error {
+		_, err := Get(tx, []byte("test_bucket"), keys)
+		if err == nil {
+			return e.New("transaction not rolled back")
+		}
+		panic("view")
+	})
+	p, ok = err.(*PanicError)
+	if !ok || p.Value != "view" {
+		t.Fatal("wrong error", err)
+	}
+
+	// The database is still usable.
+	err = SafeUpdate(db, func(tx *bolt.Tx) error {
+		return Put(tx, []byte("test_bucket"), keys, []byte("data"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
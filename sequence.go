@@ -0,0 +1,37 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// seqBucket maps the prefixes to the last number given by NextSequence.
+const seqBucket = "__seq"
+
+// NextSequence returns the next number of the counter of the partial key path
+// prefix, starting at 1, and the number encoded as a key that sorts in the
+// order of the numbers, to be used as the leaf key under prefix. The counters
+// of different prefixes are independent and never go back, even if the
+// leaves are deleted.
+func NextSequence(tx *bolt.Tx, bucket []byte, prefix [][]byte) (uint64, []byte, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(seqBucket))
+	if err != nil {
+		return 0, nil, e.Forward(err)
+	}
+	k := encodeKeys(append([][]byte{bucket}, prefix...)...)
+	var seq uint64
+	if buf := b.Get(k); len(buf) == 8 {
+		seq = DecodeUint64(buf)
+	}
+	seq++
+	key := EncodeUint64(seq)
+	err = b.Put(k, key)
+	if err != nil {
+		return 0, nil, e.Forward(err)
+	}
+	return seq, key, nil
+}
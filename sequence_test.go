@@ -0,0 +1,81 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestNextSequence(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	day := [][]byte{[]byte("2015"), []byte("12"), []byte("23")}
+	other := [][]byte{[]byte("2015"), []byte("12"), []byte("24")}
+	err := db.Update(func(tx *bolt.Tx) error {
+		var last []byte
+		for i := uint64(1); i <= 300; i++ {
+			seq, key, err := NextSequence(tx, bucket, day)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if seq != i {
+				return e.New("wrong sequence %v, want %v", seq, i)
+			}
+			if bytes.Compare(last, key) >= 0 {
+				return e.New("key %x doesn't sort after %x", key, last)
+			}
+			last = key
+			err = Put(tx, bucket, append(day, key), []byte("x"))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		seq, _, err := NextSequence(tx, bucket, other)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if seq != 1 {
+			return e.New("prefixes share the counter: %v", seq)
+		}
+		seq, _, err = NextSequence(tx, []byte("test_other"), day)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if seq != 1 {
+			return e.New("buckets share the counter: %v", seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The counter survives the transaction and the leaves.
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := DeleteWhere(tx, bucket, day[:1], func(keys [][]byte, v []byte) bool {
+			return true
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		seq, _, err := NextSequence(tx, bucket, day)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if seq != 301 {
+			return e.New("wrong sequence after the commit %v", seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// SitemapMaxURLs is the maximum number of URLs in one sitemap file.
+var SitemapMaxURLs = 50000
+
+// SitemapURL is one entry of the sitemap.
+type SitemapURL struct {
+	Loc     string
+	LastMod time.Time
+	// ChangeFreq is always, hourly, daily, weekly, monthly, yearly or
+	// never. Empty leaves it out.
+	ChangeFreq string
+	// Priority goes from 0.0 to 1.0. Zero leaves it out.
+	Priority float64
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// URLFunc converts a leaf in a sitemap entry. A nil entry leaves the leaf out
+// of the sitemap, e.g. if it isn't published.
+type URLFunc func(keys [][]byte, v []byte) (*SitemapURL, error)
+
+// SitemapFiles creates the files of the sitemap.
+type SitemapFiles struct {
+	// Base is the URL of the directory where the files are served. It is
+	// used by the index file.
+	Base string
+	// Create opens the file with the name for writing.
+	Create func(name string) (io.WriteCloser, error)
+}
+
+// Sitemap writes the sitemap of the leaves of bucket. Up to SitemapMaxURLs
+// entries it is one file named sitemap.xml. Beyond that the entries are split
+// in sitemap-1.xml, sitemap-2.xml and so on, and sitemap.xml is the index
+// pointing to them.
+func Sitemap(tx *bolt.Tx, bucket []byte, urlFn URLFunc, files *SitemapFiles) error {
+	n := 0
+	err := walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+		u, err := urlFn(keys, v)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if u != nil {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+
+	if n <= SitemapMaxURLs {
+		w, err := files.Create("sitemap.xml")
+		if err != nil {
+			return e.Forward(err)
+		}
+		sw, err := newSitemapWriter(w)
+		if err != nil {
+			w.Close()
+			return e.Forward(err)
+		}
+		err = walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+			u, err := urlFn(keys, v)
+			if err != nil || u == nil {
+				return err
+			}
+			return sw.write(u)
+		})
+		if err != nil {
+			w.Close()
+			return e.Forward(err)
+		}
+		return sw.close()
+	}
+
+	parts := (n + SitemapMaxURLs - 1) / SitemapMaxURLs
+	err = writeSitemapIndex(files, parts)
+	if err != nil {
+		return e.Forward(err)
+	}
+	var sw *sitemapWriter
+	part, count := 0, 0
+	err = walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+		u, err := urlFn(keys, v)
+		if err != nil || u == nil {
+			return err
+		}
+		if sw == nil || count == SitemapMaxURLs {
+			if sw != nil {
+				err = sw.close()
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			part++
+			count = 0
+			w, err := files.Create(sitemapPart(part))
+			if err != nil {
+				return e.Forward(err)
+			}
+			sw, err = newSitemapWriter(w)
+			if err != nil {
+				w.Close()
+				return e.Forward(err)
+			}
+		}
+		count++
+		return sw.write(u)
+	})
+	if err != nil {
+		if sw != nil {
+			sw.w.Close()
+		}
+		return e.Forward(err)
+	}
+	if sw != nil {
+		return sw.close()
+	}
+	return nil
+}
+
+func sitemapPart(i int) string {
+	return fmt.Sprintf("sitemap-%d.xml", i)
+}
+
+func writeSitemapIndex(files *SitemapFiles, parts int) error {
+	w, err := files.Create("sitemap.xml")
+	if err != nil {
+		return e.Forward(err)
+	}
+	type loc struct {
+		Loc string `xml:"loc"`
+	}
+	index := struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []loc    `xml:"sitemap"`
+	}{}
+	base := strings.TrimSuffix(files.Base, "/")
+	for i := 1; i <= parts; i++ {
+		index.Sitemaps = append(index.Sitemaps, loc{Loc: base + "/" + sitemapPart(i)})
+	}
+	_, err = io.WriteString(w, xml.Header)
+	if err == nil {
+		err = xml.NewEncoder(w).Encode(index)
+	}
+	if err != nil {
+		w.Close()
+		return e.Forward(err)
+	}
+	err = w.Close()
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// sitemapWriter streams the entries of one sitemap file.
+type sitemapWriter struct {
+	w   io.WriteCloser
+	enc *xml.Encoder
+}
+
+func newSitemapWriter(w io.WriteCloser) (*sitemapWriter, error) {
+	_, err := io.WriteString(w, xml.Header+`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return &sitemapWriter{w: w, enc: xml.NewEncoder(w)}, nil
+}
+
+func (sw *sitemapWriter) write(u *SitemapURL) error {
+	url := sitemapURL{
+		Loc:        u.Loc,
+		ChangeFreq: u.ChangeFreq,
+	}
+	if !u.LastMod.IsZero() {
+		url.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+	}
+	if u.Priority > 0 {
+		url.Priority = fmt.Sprintf("%.1f", u.Priority)
+	}
+	err := sw.enc.EncodeElement(url, xml.StartElement{Name: xml.Name{Local: "url"}})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+func (sw *sitemapWriter) close() error {
+	err := sw.enc.Flush()
+	if err == nil {
+		_, err = io.WriteString(sw.w, "</urlset>\n")
+	}
+	if err != nil {
+		sw.w.Close()
+		return e.Forward(err)
+	}
+	err = sw.w.Close()
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
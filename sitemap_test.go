@@ -0,0 +1,114 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+type memFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (m *memFile) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestSitemap(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 7; i++ {
+			status := "pub"
+			if i == 3 {
+				status = "draft"
+			}
+			keys := [][]byte{[]byte("2015"), []byte("post" + strconv.Itoa(i))}
+			err := Put(tx, []byte("blog"), keys, []byte(status))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	urlFn := func(keys [][]byte, v []byte) (*SitemapURL, error) {
+		if string(v) != "pub" {
+			return nil, nil
+		}
+		return &SitemapURL{Loc: "http://example.com/" + string(keys[0]) + "/" + string(keys[1]), Priority: 0.5}, nil
+	}
+
+	for _, max := range []int{10, 4} {
+		out := make(map[string]*memFile)
+		files := &SitemapFiles{
+			Base: "http://example.com/",
+			Create: func(name string) (io.WriteCloser, error) {
+				f := new(memFile)
+				out[name] = f
+				return f, nil
+			},
+		}
+		old := SitemapMaxURLs
+		SitemapMaxURLs = max
+		err = db.View(func(tx *bolt.Tx) error {
+			return Sitemap(tx, []byte("blog"), urlFn, files)
+		})
+		SitemapMaxURLs = old
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		for name, f := range out {
+			if !f.closed {
+				t.Fatal("file not closed", name)
+			}
+		}
+		switch max {
+		case 10:
+			s := out["sitemap.xml"].String()
+			if len(out) != 1 || strings.Count(s, "<url>") != 6 || strings.Contains(s, "post3") || !strings.Contains(s, "<priority>0.5</priority>") {
+				t.Fatal("wrong sitemap", len(out), s)
+			}
+		case 4:
+			if len(out) != 3 {
+				t.Fatal("wrong number of files", len(out))
+			}
+			if !strings.Contains(out["sitemap.xml"].String(), "<loc>http://example.com/sitemap-2.xml</loc>") {
+				t.Fatal("wrong index", out["sitemap.xml"].String())
+			}
+			if strings.Count(out["sitemap-1.xml"].String(), "<url>") != 4 || strings.Count(out["sitemap-2.xml"].String(), "<url>") != 2 {
+				t.Fatal("wrong split")
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import "time"
+
+// SlowOp describes a cursor operation that took longer than SlowThreshold.
+type SlowOp struct {
+	// Op is the name of the cursor method.
+	Op     string
+	Bucket []byte
+	// Prefix is the key path the cursor is pinned to.
+	Prefix   Path
+	Duration time.Duration
+	// Entries is the number of entries read in all levels.
+	Entries uint64
+}
+
+// SlowLog, if not nil, receives the cursor operations that took at least
+// SlowThreshold. With a zero threshold it receives every operation, like an
+// access log. Set both before using the cursors.
+var SlowLog func(op SlowOp)
+
+// SlowThreshold is the minimum duration of the operations sent to SlowLog.
+var SlowThreshold time.Duration
+
+func nop() {}
+
+// slow starts timing the operation op. The returned function sends it to
+// SlowLog if it was slow, and to the metrics of the cursor.
+func (c *Cursor) slow(op string) func() {
+	log := SlowLog
+	m := c.metrics
+	if log == nil && m == nil {
+		return nop
+	}
+	threshold := SlowThreshold
+	start := time.Now()
+	scanned := c.scanned
+	err := c.err
+	return func() {
+		d := time.Since(start)
+		if m != nil {
+			c.observe(m, op, d, err)
+		}
+		if log == nil || d < threshold {
+			return
+		}
+		log(SlowOp{
+			Op:       op,
+			Bucket:   c.Bucket,
+			Prefix:   Path(copyKeys(c.skip)),
+			Duration: d,
+			Entries:  c.scanned - scanned,
+		})
+	}
+}
+
+// observe sends the operation op to m, with the error of the cursor if it
+// isn't prev, the error before the operation.
+func (c *Cursor) observe(m Metrics, op string, d time.Duration, prev error) {
+	o := Op{
+		Name:     op,
+		Bucket:   c.Bucket,
+		Depth:    c.NumKeys,
+		Duration: d,
+	}
+	if len(c.skip) > 0 {
+		o.Prefix = c.skip[0]
+	}
+	if c.err != prev {
+		o.Err = c.err
+	}
+	m.Observe(o)
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestSlowLog(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 10; j++ {
+				keys := [][]byte{[]byte(fmt.Sprint(i)), []byte(fmt.Sprint(j))}
+				err := Put(tx, []byte("test_slow"), keys, []byte("data"))
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var ops []SlowOp
+	SlowLog = func(op SlowOp) {
+		ops = append(ops, op)
+	}
+	SlowThreshold = 0
+	defer func() {
+		SlowLog = nil
+		SlowThreshold = 0
+	}()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &Cursor{
+			Tx:      tx,
+			Bucket:  []byte("test_slow"),
+			NumKeys: 2,
+		}
+		err := c.Init([]byte("1"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		c.Skip(5)
+		if len(ops) != 1 {
+			return e.New("wrong number of operations logged %v", len(ops))
+		}
+		op := ops[0]
+		if op.Op != "Skip" || string(op.Bucket) != "test_slow" || op.Prefix.String() != "/1" {
+			return e.New("wrong operation %#v", op)
+		}
+		if op.Entries != 6 {
+			return e.New("wrong entries scanned %v", op.Entries)
+		}
+
+		SlowThreshold = time.Hour
+		c.Next()
+		if len(ops) != 1 {
+			return e.New("fast operation logged")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
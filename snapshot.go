@@ -0,0 +1,141 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrSnapClosed = "snapshot closed"
+
+const ErrSnapLeaked = "snapshot open for too long"
+
+// SnapLeak describes a snapshot open for longer than SnapMaxAge.
+type SnapLeak struct {
+	Opened time.Time
+	// Cursors is the number of cursors given by the snapshot.
+	Cursors int
+	// Stack is the stack of the goroutine that called Snapshot.
+	Stack []byte
+}
+
+// SnapMaxAge is how long a snapshot can be open before it is reported as
+// leaked. Zero disables the detection. Set it before calling Snapshot.
+var SnapMaxAge = time.Minute
+
+// SnapLeakLog, if not nil, receives the snapshots open for SnapMaxAge, once
+// for each snapshot. If it is nil the leak is reported by Close, that returns
+// ErrSnapLeaked after ending the transaction.
+var SnapLeakLog func(leak SnapLeak)
+
+// Snap is a read transaction kept open to give cursors that see the same
+// version of the database. Old transactions keep the database from
+// reclaiming pages, and the writes that grow the database file wait for them
+// unless bolt.Options.InitialMmapSize is big enough, so Close must be called
+// as soon as the cursors aren't needed. The cursors share the transaction
+// and, like it, can't be used by more than one goroutine at the same time.
+type Snap struct {
+	tx      *bolt.Tx
+	lck     sync.Mutex
+	opened  time.Time
+	stack   []byte
+	timer   *time.Timer
+	cursors []*Cursor
+	leaked  bool
+	closed  bool
+}
+
+// Snapshot begins a read transaction in db for the cursors of the snapshot.
+func Snapshot(db *bolt.DB) (*Snap, error) {
+	tx, err := db.Begin(false)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	s := &Snap{
+		tx:     tx,
+		opened: time.Now(),
+	}
+	if maxAge := SnapMaxAge; maxAge > 0 {
+		buf := make([]byte, 4096)
+		s.stack = buf[:runtime.Stack(buf, false)]
+		s.timer = time.AfterFunc(maxAge, s.leak)
+	}
+	return s, nil
+}
+
+// Tx returns the transaction of the snapshot.
+func (s *Snap) Tx() *bolt.Tx {
+	return s.tx
+}
+
+// Cursor returns a cursor over bucket pinned to keys, like Cursor.Init. The
+// cursor must not be committed or rolled back, Close ends its transaction.
+func (s *Snap) Cursor(bucket []byte, numKeys int, keys ...[]byte) (*Cursor, error) {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	if s.closed {
+		return nil, e.New(ErrSnapClosed)
+	}
+	c := &Cursor{
+		Tx:      s.tx,
+		Bucket:  bucket,
+		NumKeys: numKeys,
+		// The transaction belongs to the snapshot.
+		rollback: true,
+	}
+	err := c.Init(keys...)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	s.cursors = append(s.cursors, c)
+	return c, nil
+}
+
+// Close ends the transaction of the snapshot. The cursors of the snapshot
+// can't be used after it.
+func (s *Snap) Close() error {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	if s.closed {
+		return e.New(ErrSnapClosed)
+	}
+	s.closed = true
+	s.cursors = nil
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	err := s.tx.Rollback()
+	if err != nil {
+		return e.Forward(err)
+	}
+	if s.leaked && SnapLeakLog == nil {
+		return e.New("%v: opened at %v", ErrSnapLeaked, s.opened)
+	}
+	return nil
+}
+
+// leak is called by the timer when the snapshot is open for SnapMaxAge.
+func (s *Snap) leak() {
+	s.lck.Lock()
+	if s.closed {
+		s.lck.Unlock()
+		return
+	}
+	s.leaked = true
+	leak := SnapLeak{
+		Opened:  s.opened,
+		Cursors: len(s.cursors),
+		Stack:   s.stack,
+	}
+	s.lck.Unlock()
+	if log := SnapLeakLog; log != nil {
+		log(leak)
+	}
+}
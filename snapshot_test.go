@@ -0,0 +1,158 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestSnapshot(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// Writes that remap the file wait for the open snapshot.
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, &bolt.Options{
+		InitialMmapSize: 1 << 20,
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+
+	bucket := []byte("test_snap")
+	put := func(k1, k2, v string) {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return Put(tx, bucket, [][]byte{[]byte(k1), []byte(k2)}, []byte(v))
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	put("a", "1", "a1")
+	put("b", "1", "b1")
+
+	s, err := Snapshot(db)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	c1, err := s.Cursor(bucket, 2)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	put("a", "2", "a2")
+
+	c2, err := s.Cursor(bucket, 2, []byte("a"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	got := ""
+	for k, v := c1.First(); k != nil; k, v = c1.Next() {
+		got += string(v)
+	}
+	if got != "a1b1" {
+		t.Fatal("wrong first cursor", got)
+	}
+	got = ""
+	for k, v := c2.First(); k != nil; k, v = c2.Next() {
+		got += string(v)
+	}
+	if got != "a1" {
+		t.Fatal("second cursor sees the new write", got)
+	}
+
+	err = c1.Commit()
+	if err == nil {
+		t.Fatal("the cursor ended the transaction of the snapshot")
+	}
+	_, err = s.Cursor(bucket, 2, []byte("c"))
+	if !IsError(err, ErrKeyNotFound) {
+		t.Fatal("expected key not found, got", err)
+	}
+
+	err = s.Close()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = s.Close()
+	if !e.Equal(err, ErrSnapClosed) {
+		t.Fatal("expected snapshot closed, got", err)
+	}
+	_, err = s.Cursor(bucket, 2)
+	if !e.Equal(err, ErrSnapClosed) {
+		t.Fatal("expected snapshot closed, got", err)
+	}
+}
+
+func TestSnapshotLeak(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	defer func(maxAge time.Duration) {
+		SnapMaxAge = maxAge
+		SnapLeakLog = nil
+	}(SnapMaxAge)
+	SnapMaxAge = 10 * time.Millisecond
+
+	// Without a log Close reports the leak.
+	s, err := Snapshot(db)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	time.Sleep(50 * time.Millisecond)
+	err = s.Close()
+	if !e.Contains(err, ErrSnapLeaked) {
+		t.Fatal("expected leak, got", err)
+	}
+
+	leaks := make(chan SnapLeak, 1)
+	SnapLeakLog = func(leak SnapLeak) {
+		leaks <- leak
+	}
+	s, err = Snapshot(db)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	select {
+	case leak := <-leaks:
+		if len(leak.Stack) == 0 || leak.Opened.IsZero() {
+			t.Fatal("incomplete leak report", leak)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("leak not reported")
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// Snapshots closed in time aren't reported.
+	s, err = Snapshot(db)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case leak := <-leaks:
+		t.Fatal("closed snapshot reported", leak)
+	default:
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Command boltsoak runs the soak test in a database file:
+//
+//	boltsoak -db /tmp/soak.db -duration 24h -check 5m
+//
+// It prints a report after each invariant check and exits with status 1 on
+// the first violation.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils/soak"
+	"github.com/fcavani/e"
+)
+
+func main() {
+	path := flag.String("db", "soak.db", "database file, created if missing")
+	bucket := flag.String("bucket", "soak", "bucket under test, wiped at the start")
+	numKeys := flag.Int("levels", 3, "levels of the tree")
+	fanout := flag.Int("fanout", 8, "distinct keys of each level")
+	duration := flag.Duration("duration", time.Hour, "length of the run")
+	check := flag.Duration("check", time.Minute, "interval between the invariant checks")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the random operations")
+	flag.Parse()
+
+	db, err := bolt.Open(*path, 0600, nil)
+	if err != nil {
+		log.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	log.Printf("seed %v", *seed)
+	r, err := soak.Run(ctx, soak.Config{
+		DB:         db,
+		Bucket:     []byte(*bucket),
+		NumKeys:    *numKeys,
+		Fanout:     *fanout,
+		Duration:   *duration,
+		CheckEvery: *check,
+		Seed:       *seed,
+		Log: func(r soak.Report) {
+			log.Print(r)
+		},
+	})
+	if err != nil {
+		db.Close()
+		log.Fatalf("%v\nseed %v, %v", e.Trace(e.Forward(err)), *seed, r)
+	}
+	log.Print("done: ", r)
+}
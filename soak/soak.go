@@ -0,0 +1,420 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+// Package soak runs long stability tests of boltdbutils. It interleaves
+// writes, deletes, reads, scans, expirations and compactions on one tree and
+// periodically checks the tree against a model kept in memory, so the
+// subsystems are exercised together for hours before going to production.
+package soak
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/boltdbutils"
+	"github.com/fcavani/e"
+)
+
+const ErrInvariant = "invariant violated"
+
+// Config configures a run. The zero values are replaced by the defaults.
+type Config struct {
+	// DB is the database under test. It must not have other trees, their
+	// intermediate buckets are seen as orphans.
+	DB *bolt.DB
+	// TempDir is where the compactions are written, os.TempDir() if empty.
+	TempDir string
+	// Bucket is the tree under test, "soak" by default. It is wiped at the
+	// start.
+	Bucket []byte
+	// NumKeys is the number of levels of the tree, 3 by default.
+	NumKeys int
+	// Fanout is the number of distinct keys of each level, 8 by default.
+	Fanout int
+	// Duration is how long the run takes, one minute by default.
+	Duration time.Duration
+	// CheckEvery is the interval between the invariant checks, 10 seconds
+	// by default. The invariants are checked at the end too.
+	CheckEvery time.Duration
+	// Seed seeds the random operations, so a failure can be repeated.
+	Seed int64
+	// Log, if not nil, receives the report after each check.
+	Log func(r Report)
+}
+
+// Report counts the operations done so far.
+type Report struct {
+	Elapsed     time.Duration
+	Puts        uint64
+	Dels        uint64
+	Gets        uint64
+	Scans       uint64
+	Expirations uint64
+	Compactions uint64
+	Checks      uint64
+	// Leaves is the number of leaves in the tree at the last check.
+	Leaves int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%v: %v leaves, %v puts, %v dels, %v gets, %v scans, %v expirations, %v compactions, %v checks",
+		r.Elapsed.Truncate(time.Second), r.Leaves, r.Puts, r.Dels, r.Gets, r.Scans, r.Expirations, r.Compactions, r.Checks)
+}
+
+// runner holds the state of a run. live has the permanent leaves and
+// expiring the leaves put already expired, that the next Expire removes.
+type runner struct {
+	Config
+	rnd      *rand.Rand
+	live     map[string][]byte
+	expiring map[string][]byte
+	report   Report
+}
+
+// Run runs the soak test until cfg.Duration passes or ctx is done. It returns
+// the first invariant violated, or an unexpected error of an operation.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	r := &runner{
+		Config:   cfg,
+		live:     make(map[string][]byte),
+		expiring: make(map[string][]byte),
+	}
+	if r.DB == nil {
+		return r.report, e.New("no database")
+	}
+	if len(r.Bucket) == 0 {
+		r.Bucket = []byte("soak")
+	}
+	if r.NumKeys <= 0 {
+		r.NumKeys = 3
+	}
+	if r.Fanout <= 0 {
+		r.Fanout = 8
+	}
+	if r.Duration <= 0 {
+		r.Duration = time.Minute
+	}
+	if r.CheckEvery <= 0 {
+		r.CheckEvery = 10 * time.Second
+	}
+	r.rnd = rand.New(rand.NewSource(r.Seed))
+
+	err := r.reset()
+	if err != nil {
+		return r.report, e.Forward(err)
+	}
+	start := time.Now()
+	end := start.Add(r.Duration)
+	check := start.Add(r.CheckEvery)
+	for now := start; now.Before(end); now = time.Now() {
+		if ctx.Err() != nil {
+			break
+		}
+		if !now.Before(check) {
+			err = r.check(start)
+			if err != nil {
+				return r.report, e.Forward(err)
+			}
+			check = now.Add(r.CheckEvery)
+		}
+		err = r.step()
+		if err != nil {
+			return r.report, e.Forward(err)
+		}
+	}
+	err = r.check(start)
+	if err != nil {
+		return r.report, e.Forward(err)
+	}
+	return r.report, nil
+}
+
+// reset wipes the bucket under test.
+func (r *runner) reset() error {
+	exists := false
+	err := r.DB.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(r.Bucket) != nil
+		return nil
+	})
+	if err != nil || !exists {
+		return err
+	}
+	err = boltdbutils.Wipe(r.DB, r.Bucket, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// step does one random operation.
+func (r *runner) step() error {
+	switch n := r.rnd.Intn(1000); {
+	case n < 450:
+		return r.put(false)
+	case n < 550:
+		return r.put(true)
+	case n < 800:
+		return r.del()
+	case n < 950:
+		return r.get()
+	case n < 990:
+		return r.scan()
+	case n < 998:
+		return r.expire()
+	default:
+		return r.compact()
+	}
+}
+
+func (r *runner) keys() ([][]byte, string) {
+	keys := make([][]byte, r.NumKeys)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("k%03d", r.rnd.Intn(r.Fanout)))
+	}
+	return keys, string(bytes.Join(keys, []byte("/")))
+}
+
+func (r *runner) put(expired bool) error {
+	keys, path := r.keys()
+	value := []byte(fmt.Sprintf("%v@%v", path, r.report.Puts))
+	err := r.DB.Update(func(tx *bolt.Tx) error {
+		if expired {
+			return boltdbutils.PutTTL(tx, r.Bucket, keys, value, time.Now().Add(-time.Second))
+		}
+		return boltdbutils.Put(tx, r.Bucket, keys, value)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	r.report.Puts++
+	delete(r.live, path)
+	delete(r.expiring, path)
+	if expired {
+		r.expiring[path] = value
+	} else {
+		r.live[path] = value
+	}
+	return nil
+}
+
+func (r *runner) del() error {
+	keys, path := r.keys()
+	err := r.DB.Update(func(tx *bolt.Tx) error {
+		return boltdbutils.Del(tx, r.Bucket, keys)
+	})
+	_, live := r.live[path]
+	_, expiring := r.expiring[path]
+	switch {
+	case err == nil && !live && !expiring:
+		return e.New("%v: deleted the missing leaf %v", ErrInvariant, path)
+	case boltdbutils.IsError(err, boltdbutils.ErrKeyNotFound) || boltdbutils.IsError(err, boltdbutils.ErrInvBucket):
+		if live || expiring {
+			return e.New("%v: leaf %v not found", ErrInvariant, path)
+		}
+	case err != nil:
+		return e.Forward(err)
+	}
+	r.report.Dels++
+	delete(r.live, path)
+	delete(r.expiring, path)
+	return nil
+}
+
+func (r *runner) get() error {
+	keys, path := r.keys()
+	var v []byte
+	err := r.DB.View(func(tx *bolt.Tx) error {
+		buf, err := boltdbutils.Get(tx, r.Bucket, keys)
+		v = append([]byte{}, buf...)
+		return err
+	})
+	r.report.Gets++
+	want, live := r.live[path]
+	_, expiring := r.expiring[path]
+	switch {
+	case live:
+		if err != nil {
+			return e.Push(err, e.New("%v: get %v", ErrInvariant, path))
+		}
+		if !bytes.Equal(v, want) {
+			return e.New("%v: get %v returned %q, want %q", ErrInvariant, path, v, want)
+		}
+	case expiring:
+		if !boltdbutils.IsError(err, boltdbutils.ErrExpired) {
+			return e.New("%v: get of the expired %v returned %v", ErrInvariant, path, err)
+		}
+	case err == nil:
+		return e.New("%v: got the missing leaf %v", ErrInvariant, path)
+	}
+	return nil
+}
+
+// scan reads the leaves under a random prefix with a cursor and compares
+// them with the model.
+func (r *runner) scan() error {
+	keys, _ := r.keys()
+	prefix := keys[:r.rnd.Intn(r.NumKeys)]
+	p := string(bytes.Join(prefix, []byte("/")))
+	if len(prefix) > 0 {
+		p += "/"
+	}
+	want := 0
+	for path := range r.live {
+		if strings.HasPrefix(path, p) {
+			want++
+		}
+	}
+	for path := range r.expiring {
+		if strings.HasPrefix(path, p) {
+			want++
+		}
+	}
+	got := 0
+	err := r.DB.View(func(tx *bolt.Tx) error {
+		c := &boltdbutils.Cursor{
+			Tx:      tx,
+			Bucket:  r.Bucket,
+			NumKeys: r.NumKeys,
+		}
+		err := c.Init(prefix...)
+		if boltdbutils.IsError(err, boltdbutils.ErrKeyNotFound) || boltdbutils.IsError(err, boltdbutils.ErrInvBucket) {
+			return nil
+		} else if err != nil {
+			return e.Forward(err)
+		}
+		var last [][]byte
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			path := string(bytes.Join(k, []byte("/")))
+			if lv, ok := r.live[path]; ok && !bytes.Equal(v, lv) {
+				return e.New("%v: scan of %v returned %q, want %q", ErrInvariant, path, v, lv)
+			}
+			if last != nil && bytes.Compare(bytes.Join(last, []byte("/")), []byte(path)) >= 0 {
+				return e.New("%v: scan out of order at %v", ErrInvariant, path)
+			}
+			last = append(last[:0], []byte(path))
+			got++
+		}
+		return c.Err()
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	r.report.Scans++
+	if got != want {
+		return e.New("%v: scan of %q returned %v leaves, want %v", ErrInvariant, p, got, want)
+	}
+	return nil
+}
+
+func (r *runner) expire() error {
+	n, err := boltdbutils.Expire(r.DB)
+	if err != nil {
+		return e.Forward(err)
+	}
+	r.report.Expirations++
+	if n != len(r.expiring) {
+		return e.New("%v: expired %v leaves, want %v", ErrInvariant, n, len(r.expiring))
+	}
+	r.expiring = make(map[string][]byte)
+	return nil
+}
+
+// compact copies the tree to a temporary database and compares the number of
+// leaves.
+func (r *runner) compact() error {
+	dir, err := ioutil.TempDir(r.TempDir, "soak-")
+	if err != nil {
+		return e.Forward(err)
+	}
+	defer os.RemoveAll(dir)
+	dst, err := bolt.Open(filepath.Join(dir, "compact.db"), 0600, nil)
+	if err != nil {
+		return e.Forward(err)
+	}
+	defer dst.Close()
+	var want boltdbutils.Stats
+	err = r.DB.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(r.Bucket) == nil {
+			return nil
+		}
+		var err error
+		want, err = boltdbutils.SubtreeStats(tx, r.Bucket, nil)
+		return err
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	if want.Entries == 0 {
+		return nil
+	}
+	err = boltdbutils.Compact(r.DB, dst, [][]byte{r.Bucket})
+	if err != nil {
+		return e.Forward(err)
+	}
+	var got boltdbutils.Stats
+	err = dst.View(func(tx *bolt.Tx) error {
+		var err error
+		got, err = boltdbutils.SubtreeStats(tx, r.Bucket, nil)
+		return err
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	r.report.Compactions++
+	if got.Entries != want.Entries || got.Buckets != want.Buckets {
+		return e.New("%v: compaction has %+v, want %+v", ErrInvariant, got, want)
+	}
+	return nil
+}
+
+// check verifies the whole tree: the leaves, the bucket counter and the
+// orphan buckets.
+func (r *runner) check(start time.Time) error {
+	leaves := len(r.live) + len(r.expiring)
+	err := r.DB.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(r.Bucket) == nil {
+			if leaves != 0 {
+				return e.New("%v: the bucket is missing", ErrInvariant)
+			}
+			return nil
+		}
+		s, err := boltdbutils.SubtreeStats(tx, r.Bucket, nil)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if s.Entries != uint64(leaves) {
+			return e.New("%v: the tree has %v leaves, want %v", ErrInvariant, s.Entries, leaves)
+		}
+		counters := boltdbutils.BucketMetrics(tx)[string(r.Bucket)]
+		if counters.Live != s.Buckets {
+			return e.New("%v: the counter has %v buckets, the tree has %v", ErrInvariant, counters.Live, s.Buckets)
+		}
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	orphans, err := boltdbutils.Verify(r.DB, [][]byte{r.Bucket})
+	if err != nil && !boltdbutils.IsError(err, boltdbutils.ErrInvBucket) {
+		return e.Forward(err)
+	}
+	if len(orphans) > 0 {
+		return e.New("%v: %v orphan buckets", ErrInvariant, len(orphans))
+	}
+	r.report.Checks++
+	r.report.Leaves = leaves
+	r.report.Elapsed = time.Since(start)
+	if r.Log != nil {
+		r.Log(r.report)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package soak
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "soak-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "soak.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+
+	cfg := Config{
+		DB:         db,
+		TempDir:    dir,
+		NumKeys:    2,
+		Fanout:     6,
+		Duration:   500 * time.Millisecond,
+		CheckEvery: 100 * time.Millisecond,
+		Seed:       1,
+	}
+	checks := 0
+	cfg.Log = func(r Report) {
+		checks++
+	}
+	r, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)), r)
+	}
+	if r.Puts == 0 || r.Dels == 0 || r.Scans == 0 || r.Expirations == 0 || r.Compactions == 0 {
+		t.Fatal("operations missing", r)
+	}
+	if checks < 2 || r.Checks != uint64(checks) {
+		t.Fatal("wrong number of checks", checks, r)
+	}
+
+	// A second run starts with the bucket wiped.
+	cfg.Duration = 50 * time.Millisecond
+	cfg.Log = nil
+	r, err = Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)), r)
+	}
+}
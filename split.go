@@ -0,0 +1,77 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrInvLevel = "invalid level"
+
+// SplitLevel rewrites the tree of bucket replacing the key of level by the
+// keys returned by splitter, e.g. "2015-12" by "2015" and "12". It runs like
+// ReorderLevels, in chunks and with verification, and is recovered like it
+// if interrupted.
+func SplitLevel(db *bolt.DB, bucket []byte, level int, splitter func(key []byte) ([][]byte, error)) error {
+	if level < 0 {
+		return e.New(ErrInvLevel)
+	}
+	err := migrate(db, bucket, func(keys [][]byte) ([][]byte, error) {
+		if level >= len(keys) {
+			return nil, newKeyError(ErrInvLevel, bucket, level, keys)
+		}
+		parts, err := splitter(keys[level])
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		if len(parts) == 0 {
+			return nil, newKeyError(ErrNoKeys, bucket, level, keys)
+		}
+		out := make([][]byte, 0, len(keys)+len(parts)-1)
+		out = append(out, keys[:level]...)
+		out = append(out, parts...)
+		out = append(out, keys[level+1:]...)
+		return out, nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// MergeLevels rewrites the tree of bucket joining the keys of the levels a
+// and b in one key. The key returned by joiner takes the place of level a and
+// level b is removed. It runs like ReorderLevels, in chunks and with
+// verification, and is recovered like it if interrupted.
+func MergeLevels(db *bolt.DB, bucket []byte, a, b int, joiner func(a, b []byte) ([]byte, error)) error {
+	if a < 0 || b < 0 || a == b {
+		return e.New(ErrInvLevel)
+	}
+	err := migrate(db, bucket, func(keys [][]byte) ([][]byte, error) {
+		if a >= len(keys) || b >= len(keys) {
+			return nil, newKeyError(ErrInvLevel, bucket, -1, keys)
+		}
+		key, err := joiner(keys[a], keys[b])
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		out := make([][]byte, 0, len(keys)-1)
+		for i, k := range keys {
+			switch i {
+			case a:
+				out = append(out, key)
+			case b:
+			default:
+				out = append(out, k)
+			}
+		}
+		return out, nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
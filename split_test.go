@@ -0,0 +1,113 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestSplitMergeLevels(t *testing.T) {
+	data := []testData{
+		{[]byte("test_bucket"), [][]byte{[]byte("2014-01"), []byte("a")}, []byte("1")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2014-02"), []byte("b")}, []byte("2")},
+		{[]byte("test_bucket"), [][]byte{[]byte("2015-01"), []byte("c")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	check := func(keys func(d testData) [][]byte) {
+		err := db.View(func(tx *bolt.Tx) error {
+			for i, d := range data {
+				v, err := Get(tx, d.Bucket, keys(d))
+				if err != nil {
+					return e.Push(err, e.New("Fail to get %v", i))
+				}
+				if !bytes.Equal(v, d.Data) {
+					return e.New("not equal %v %v", i, string(v))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+
+	err = SplitLevel(db, []byte("test_bucket"), 0, func(key []byte) ([][]byte, error) {
+		return bytes.SplitN(key, []byte("-"), 2), nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check(func(d testData) [][]byte {
+		return append(bytes.SplitN(d.Keys[0], []byte("-"), 2), d.Keys[1])
+	})
+
+	err = MergeLevels(db, []byte("test_bucket"), 0, 1, func(a, b []byte) ([]byte, error) {
+		return []byte(string(a) + "-" + string(b)), nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	check(func(d testData) [][]byte {
+		return d.Keys
+	})
+
+	// Two keys that collide fail the verification.
+	err = SplitLevel(db, []byte("test_bucket"), 0, func(key []byte) ([][]byte, error) {
+		return [][]byte{key[:4], []byte("x")}, nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = MergeLevels(db, []byte("test_bucket"), 1, 2, func(a, b []byte) ([]byte, error) {
+		return a, nil
+	})
+	if !IsError(err, ErrVerify) {
+		t.Fatal("expected ErrVerify", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("__migrate_test_bucket")) != nil {
+			return e.New("migration bucket left behind")
+		}
+		return Put(tx, []byte("test_bucket"), [][]byte{[]byte("2016"), []byte("x"), []byte("d")}, []byte("4"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// StaticExt is the extension of the files written by ExportStatic.
+var StaticExt = ".html"
+
+// Renderer renders a leaf in the contents of its file. A nil content skips
+// the leaf, e.g. if it isn't published. The key path and the value are only
+// valid during the call.
+type Renderer func(keys [][]byte, v []byte) ([]byte, error)
+
+// ExportStatic writes one file for each leaf of bucket rendered by renderer.
+// Each level of the key path is a directory under dir and the last key is the
+// file name, with StaticExt appended, so a/b/c becomes dir/a/b/c.html. The
+// keys are escaped like the URL path segments. It returns the number of files
+// written.
+func ExportStatic(db *bolt.DB, bucket []byte, renderer Renderer, dir string) (int, error) {
+	n := 0
+	err := db.View(func(tx *bolt.Tx) error {
+		return walk(tx, bucket, nil, func(keys [][]byte, v []byte) error {
+			content, err := renderer(keys, v)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if content == nil {
+				return nil
+			}
+			parts := make([]string, len(keys)+1)
+			parts[0] = dir
+			for i, k := range keys {
+				parts[i+1] = staticName(k)
+			}
+			parts[len(parts)-1] += StaticExt
+			name := filepath.Join(parts...)
+			err = os.MkdirAll(filepath.Dir(name), 0755)
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = ioutil.WriteFile(name, content, 0644)
+			if err != nil {
+				return e.Forward(err)
+			}
+			n++
+			return nil
+		})
+	})
+	if err != nil {
+		return n, e.Forward(err)
+	}
+	return n, nil
+}
+
+// staticName escapes a key to be used as a file name.
+func staticName(key []byte) string {
+	name := url.PathEscape(string(key))
+	switch name {
+	case "":
+		// An invalid escape, no key is escaped to it.
+		return "%"
+	case ".", "..":
+		return strings.Replace(name, ".", "%2E", -1)
+	}
+	return name
+}
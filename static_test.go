@@ -0,0 +1,83 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestExportStatic(t *testing.T) {
+	data := []testData{
+		{[]byte("blog"), [][]byte{[]byte("en"), []byte("2015"), []byte("hello world")}, []byte("pub")},
+		{[]byte("blog"), [][]byte{[]byte("en"), []byte(".."), []byte("a/b")}, []byte("pub")},
+		{[]byte("blog"), [][]byte{[]byte("pt-br"), []byte("2015"), []byte("rascunho")}, []byte("draft")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	out := filepath.Join(dir, "site")
+	n, err := ExportStatic(db, []byte("blog"), func(keys [][]byte, v []byte) ([]byte, error) {
+		if string(v) != "pub" {
+			return nil, nil
+		}
+		return []byte("<h1>" + string(keys[2]) + "</h1>"), nil
+	}, out)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 2 {
+		t.Fatal("wrong number of files", n)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(out, "en", "2015", "hello%20world.html"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if string(buf) != "<h1>hello world</h1>" {
+		t.Fatal("wrong content", string(buf))
+	}
+	_, err = ioutil.ReadFile(filepath.Join(out, "en", "%2E%2E", "a%2Fb.html"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	files, err := ioutil.ReadDir(out)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if len(files) != 1 {
+		t.Fatal("unpublished leaf exported")
+	}
+}
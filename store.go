@@ -0,0 +1,333 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package boltdbutils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrInvTag = "invalid bolt tag"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Store keeps structs of type T in a tree. The fields tagged with
+// `bolt:"key,N"` are the key of the level N, starting at 1, and the other
+// exported fields are the value, encoded in JSON. The fields tagged with
+// `bolt:"-"` aren't saved. The keys can be strings, byte slices, integers,
+// floats and time.Time, encoded to sort in their natural order:
+//
+//	type Post struct {
+//		Lang  string    `bolt:"key,1"`
+//		Date  time.Time `bolt:"key,2"`
+//		Title string    `bolt:"key,3"`
+//		Text  string
+//	}
+type Store[T any] struct {
+	Bucket []byte
+	// keys are the indexes of the key fields, by level.
+	keys []int
+	// values are the indexes of the other exported fields.
+	values []int
+}
+
+// NewStore checks the tags of T and returns its Store in bucket.
+func NewStore[T any](bucket []byte) (*Store[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, e.New("%v: %v isn't a struct", ErrInvTag, t)
+	}
+	s := &Store[T]{Bucket: bucket}
+	levels := make(map[int]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, ok := f.Tag.Lookup("bolt")
+		if !ok || tag == "-" {
+			if !ok {
+				s.values = append(s.values, i)
+			}
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if len(parts) != 2 || parts[0] != "key" {
+			return nil, e.New("%v: %v in %v", ErrInvTag, tag, f.Name)
+		}
+		level, err := strconv.Atoi(parts[1])
+		if err != nil || level < 1 {
+			return nil, e.New("%v: %v in %v", ErrInvTag, tag, f.Name)
+		}
+		if _, dup := levels[level]; dup {
+			return nil, e.New("%v: level %v repeated in %v", ErrInvTag, level, f.Name)
+		}
+		if keyClass(f.Type) == "" {
+			return nil, e.New("%v: %v can't be a key", ErrInvTag, f.Type)
+		}
+		levels[level] = i
+	}
+	if len(levels) == 0 {
+		return nil, e.New("%v: %v has no keys", ErrInvTag, t)
+	}
+	s.keys = make([]int, len(levels))
+	for level := range s.keys {
+		i, ok := levels[level+1]
+		if !ok {
+			return nil, e.New("%v: level %v missing", ErrInvTag, level+1)
+		}
+		s.keys[level] = i
+	}
+	return s, nil
+}
+
+// NumKeys returns the number of levels of the tree.
+func (s *Store[T]) NumKeys() int {
+	return len(s.keys)
+}
+
+// Save writes obj with Put.
+func (s *Store[T]) Save(tx *bolt.Tx, obj *T) error {
+	v := reflect.ValueOf(obj).Elem()
+	buf, err := s.encodeValue(v)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = Put(tx, s.Bucket, s.keyPath(v), buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Load reads the value of the object with the keys in the key fields of obj
+// into the other fields.
+func (s *Store[T]) Load(tx *bolt.Tx, obj *T) error {
+	v := reflect.ValueOf(obj).Elem()
+	buf, err := Get(tx, s.Bucket, s.keyPath(v))
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = s.decodeValue(v, buf)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// DeleteObj deletes the object with the keys in the key fields of obj, with
+// Del.
+func (s *Store[T]) DeleteObj(tx *bolt.Tx, obj *T) error {
+	err := Del(tx, s.Bucket, s.keyPath(reflect.ValueOf(obj).Elem()))
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// Query returns a cursor over the objects with the first key fields equal to
+// prefix. The values of prefix have the types of the key fields.
+func (s *Store[T]) Query(tx *bolt.Tx, prefix ...interface{}) (*TypedCursor[T], error) {
+	if len(prefix) >= len(s.keys) {
+		return nil, newKeyError(ErrArity, s.Bucket, -1, nil)
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	keys := make([][]byte, len(prefix))
+	for i, p := range prefix {
+		ft := t.Field(s.keys[i]).Type
+		pv := reflect.ValueOf(p)
+		if !pv.IsValid() || keyClass(pv.Type()) != keyClass(ft) || !pv.Type().ConvertibleTo(ft) {
+			return nil, e.New("%v: prefix %v isn't a %v", ErrInvTag, i, ft)
+		}
+		keys[i] = encodeKey(pv.Convert(ft))
+	}
+	c := &Cursor{
+		Tx:      tx,
+		Bucket:  s.Bucket,
+		NumKeys: len(s.keys),
+	}
+	err := c.Init(keys...)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return &TypedCursor[T]{Cursor: c, store: s}, nil
+}
+
+// TypedCursor is a Cursor that returns the objects of a Store.
+type TypedCursor[T any] struct {
+	// Cursor is the underlying cursor, to set Reverse or the filters.
+	Cursor *Cursor
+	store  *Store[T]
+	err    error
+}
+
+// First returns the first object or nil.
+func (tc *TypedCursor[T]) First() *T {
+	return tc.object(tc.Cursor.First())
+}
+
+// Last returns the last object or nil.
+func (tc *TypedCursor[T]) Last() *T {
+	return tc.object(tc.Cursor.Last())
+}
+
+// Next returns the next object or nil at the end.
+func (tc *TypedCursor[T]) Next() *T {
+	return tc.object(tc.Cursor.Next())
+}
+
+// Prev returns the previous object or nil at the start.
+func (tc *TypedCursor[T]) Prev() *T {
+	return tc.object(tc.Cursor.Prev())
+}
+
+// Err returns the first error of the cursor or of the decoding of the
+// objects, and clears it.
+func (tc *TypedCursor[T]) Err() error {
+	err := tc.Cursor.Err()
+	if tc.err != nil {
+		err = tc.err
+		tc.err = nil
+	}
+	return err
+}
+
+func (tc *TypedCursor[T]) object(keys [][]byte, buf []byte) *T {
+	if keys == nil {
+		return nil
+	}
+	obj := new(T)
+	v := reflect.ValueOf(obj).Elem()
+	err := tc.store.decodeKeys(v, keys)
+	if err == nil {
+		err = tc.store.decodeValue(v, buf)
+	}
+	if err != nil && tc.err == nil {
+		tc.err = e.Push(err, e.New("%v at %v%v", ErrDecode, string(tc.store.Bucket), Path(keys)))
+	}
+	return obj
+}
+
+func (s *Store[T]) keyPath(v reflect.Value) [][]byte {
+	keys := make([][]byte, len(s.keys))
+	for level, i := range s.keys {
+		keys[level] = encodeKey(v.Field(i))
+	}
+	return keys
+}
+
+func (s *Store[T]) decodeKeys(v reflect.Value, keys [][]byte) error {
+	for level, i := range s.keys {
+		err := decodeKey(v.Field(i), keys[level])
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// encodeValue encodes the value fields in a JSON object by field name.
+func (s *Store[T]) encodeValue(v reflect.Value) ([]byte, error) {
+	m := make(map[string]interface{}, len(s.values))
+	for _, i := range s.values {
+		m[v.Type().Field(i).Name] = v.Field(i).Interface()
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+func (s *Store[T]) decodeValue(v reflect.Value, buf []byte) error {
+	var m map[string]json.RawMessage
+	err := json.Unmarshal(buf, &m)
+	if err != nil {
+		return e.Forward(err)
+	}
+	for _, i := range s.values {
+		raw, ok := m[v.Type().Field(i).Name]
+		if !ok {
+			continue
+		}
+		err = json.Unmarshal(raw, v.Field(i).Addr().Interface())
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// keyClass groups the types of the keys that convert to each other, or
+// returns "" if t can't be a key.
+func keyClass(t reflect.Type) string {
+	if t == timeType {
+		return "time"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+	}
+	return ""
+}
+
+// encodeKey encodes the key field v. Its type was checked by keyClass.
+func encodeKey(v reflect.Value) []byte {
+	if v.Type() == timeType {
+		return EncodeTimeKey(v.Interface().(time.Time))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return EncodeInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return EncodeUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return EncodeFloat64(v.Float())
+	}
+	return append([]byte{}, v.Bytes()...)
+}
+
+func decodeKey(v reflect.Value, buf []byte) error {
+	if v.Type() == timeType || (v.Kind() != reflect.String && v.Kind() != reflect.Slice) {
+		if len(buf) != 8 {
+			return e.New(ErrInvEncoding)
+		}
+	}
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(DecodeTimeKey(buf)))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(buf))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(DecodeInt64(buf))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(DecodeUint64(buf))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(DecodeFloat64(buf))
+	default:
+		v.SetBytes(append([]byte{}, buf...))
+	}
+	return nil
+}
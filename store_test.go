@@ -0,0 +1,149 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package boltdbutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+type testPost struct {
+	Lang  string    `bolt:"key,1"`
+	Date  time.Time `bolt:"key,2"`
+	Seq   int       `bolt:"key,3"`
+	Title string
+	Tags  []string
+	Draft bool `bolt:"-"`
+	text  string
+}
+
+func TestStore(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	s, err := NewStore[testPost]([]byte("test_posts"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if s.NumKeys() != 3 {
+		t.Fatal("wrong number of keys", s.NumKeys())
+	}
+	day := time.Date(2015, 12, 2, 0, 0, 0, 0, time.UTC)
+	posts := []testPost{
+		{Lang: "en", Date: day, Seq: 2, Title: "second", Tags: []string{"b"}},
+		{Lang: "en", Date: day, Seq: 12, Title: "twelfth"},
+		{Lang: "en", Date: day.AddDate(0, 0, -1), Seq: 1, Title: "before"},
+		{Lang: "pt", Date: day, Seq: 1, Title: "primeiro", Draft: true, text: "x"},
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i := range posts {
+			err := s.Save(tx, &posts[i])
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		p := &testPost{Lang: "en", Date: day, Seq: 2}
+		err := s.Load(tx, p)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if p.Title != "second" || len(p.Tags) != 1 || p.Tags[0] != "b" {
+			return e.New("wrong post %+v", p)
+		}
+		p = &testPost{Lang: "pt", Date: day, Seq: 1}
+		err = s.Load(tx, p)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if p.Draft || p.text != "" {
+			return e.New("untagged fields saved %+v", p)
+		}
+
+		q, err := s.Query(tx, "en")
+		if err != nil {
+			return e.Forward(err)
+		}
+		got := ""
+		for p := q.First(); p != nil; p = q.Next() {
+			if p.Lang != "en" {
+				return e.New("wrong key %+v", p)
+			}
+			got += p.Title + " "
+		}
+		if err := q.Err(); err != nil {
+			return e.Forward(err)
+		}
+		// The numbers and dates sort in their order.
+		if got != "before second twelfth " {
+			return e.New("wrong query %v", got)
+		}
+		q, err = s.Query(tx, "en", day)
+		if err != nil {
+			return e.Forward(err)
+		}
+		p = q.Last()
+		if p == nil || p.Title != "twelfth" || !p.Date.Equal(day) || p.Seq != 12 {
+			return e.New("wrong last %+v", p)
+		}
+		_, err = s.Query(tx, 1)
+		if !e.Contains(err, ErrInvTag) {
+			return e.New("expected invalid prefix, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return s.DeleteObj(tx, &testPost{Lang: "pt", Date: day, Seq: 1})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		err := s.Load(tx, &testPost{Lang: "pt", Date: day, Seq: 1})
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("expected key not found, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	type noKeys struct{ A string }
+	_, err = NewStore[noKeys]([]byte("x"))
+	if !e.Contains(err, ErrInvTag) {
+		t.Fatal("expected invalid tag", err)
+	}
+	type gap struct {
+		A string `bolt:"key,1"`
+		B string `bolt:"key,3"`
+	}
+	_, err = NewStore[gap]([]byte("x"))
+	if !e.Contains(err, ErrInvTag) {
+		t.Fatal("expected invalid tag", err)
+	}
+	type badKey struct {
+		A []string `bolt:"key,1"`
+	}
+	_, err = NewStore[badKey]([]byte("x"))
+	if !e.Contains(err, ErrInvTag) {
+		t.Fatal("expected invalid tag", err)
+	}
+}
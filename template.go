@@ -0,0 +1,92 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"html/template"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// TemplateEntry is a leaf returned by the list template function.
+type TemplateEntry struct {
+	Keys  []string
+	Value string
+}
+
+// TemplateFuncs returns the template functions that read from db, each one in
+// its own read-only transaction:
+//
+//	get bucket key...          the value of the key path
+//	list bucket limit key...   the TemplateEntry under the partial key path,
+//	                           at most limit or all if limit is zero
+//	count bucket key...        the number of leaves under the partial key path
+//
+// For example: {{range list "posts" 10 "en"}}{{.Value}}{{end}}.
+func TemplateFuncs(db *bolt.DB) template.FuncMap {
+	return template.FuncMap{
+		"get": func(bucket string, keys ...string) (string, error) {
+			var s string
+			err := db.View(func(tx *bolt.Tx) error {
+				buf, err := Get(tx, []byte(bucket), stringKeys(keys))
+				if err != nil {
+					return e.Forward(err)
+				}
+				s = string(buf)
+				return nil
+			})
+			if err != nil {
+				return "", e.Forward(err)
+			}
+			return s, nil
+		},
+		"list": func(bucket string, limit int, keys ...string) ([]TemplateEntry, error) {
+			var entries []TemplateEntry
+			err := db.View(func(tx *bolt.Tx) error {
+				kvs, err := GetAll(tx, []byte(bucket), stringKeys(keys), limit)
+				if err != nil {
+					return e.Forward(err)
+				}
+				entries = make([]TemplateEntry, len(kvs))
+				for i, kv := range kvs {
+					entries[i].Keys = make([]string, len(kv.Keys))
+					for j, k := range kv.Keys {
+						entries[i].Keys[j] = string(k)
+					}
+					entries[i].Value = string(kv.Value)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, e.Forward(err)
+			}
+			return entries, nil
+		},
+		"count": func(bucket string, keys ...string) (uint64, error) {
+			var n uint64
+			err := db.View(func(tx *bolt.Tx) error {
+				b, err := prefixBucket(tx, []byte(bucket), stringKeys(keys))
+				if err != nil {
+					return e.Forward(err)
+				}
+				n = treeStats(tx, b).Entries
+				return nil
+			})
+			if err != nil {
+				return 0, e.Forward(err)
+			}
+			return n, nil
+		},
+	}
+}
+
+func stringKeys(keys []string) [][]byte {
+	out := make([][]byte, len(keys))
+	for i, k := range keys {
+		out[i] = []byte(k)
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	data := []testData{
+		{[]byte("posts"), [][]byte{[]byte("en"), []byte("2014"), []byte("a")}, []byte("<b>1</b>")},
+		{[]byte("posts"), [][]byte{[]byte("en"), []byte("2015"), []byte("b")}, []byte("2")},
+		{[]byte("posts"), [][]byte{[]byte("pt-br"), []byte("2015"), []byte("c")}, []byte("3")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			err := Put(tx, d.Bucket, d.Keys, d.Data)
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	tmpl, err := template.New("test").Funcs(TemplateFuncs(db)).Parse(
+		`{{get "posts" "pt-br" "2015" "c"}}|{{count "posts" "en"}}|{{range list "posts" 0 "en"}}{{index .Keys 1}}={{.Value}};{{end}}`,
+	)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if buf.String() != "3|2|2014=&lt;b&gt;1&lt;/b&gt;;2015=2;" {
+		t.Fatal("wrong output", buf.String())
+	}
+
+	tmpl, err = template.New("test").Funcs(TemplateFuncs(db)).Parse(`{{get "posts" "fr"}}`)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = tmpl.Execute(&buf, nil)
+	if err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/fcavani/e"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+const ErrInvTitleKey = "invalid title key"
+
+const maxTitle = 1<<16 - 1
+
+var titleCollators sync.Map
+
+// TitleKey encodes a post title to be used as a key. The key sorts in the
+// human order of the locale, case is ignored, and the original title is
+// kept at the end of the key, see TitleFromKey.
+func TitleKey(locale, title string) ([]byte, error) {
+	if len(title) > maxTitle {
+		return nil, e.New("title too long")
+	}
+	tr, found := titleCollators.Load(locale)
+	if !found {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		tr, _ = titleCollators.LoadOrStore(locale, Collation(tag, collate.IgnoreCase))
+	}
+	key := tr.(KeyTransform)([]byte(title))
+	key = append(key, title...)
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(len(title)))
+	return append(key, l[:]...), nil
+}
+
+// TitleFromKey returns the original title stored in a key made by TitleKey.
+func TitleFromKey(key []byte) (string, error) {
+	if len(key) < 2 {
+		return "", e.New(ErrInvTitleKey)
+	}
+	n := int(binary.BigEndian.Uint16(key[len(key)-2:]))
+	if n > len(key)-2 {
+		return "", e.New(ErrInvTitleKey)
+	}
+	return string(key[len(key)-2-n : len(key)-2]), nil
+}
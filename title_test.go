@@ -0,0 +1,49 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/fcavani/e"
+)
+
+func TestTitleKey(t *testing.T) {
+	titles := []string{"zebra", "Árvore", "abacaxi", "Banana", "banana"}
+	keys := make([][]byte, len(titles))
+	for i, title := range titles {
+		key, err := TitleKey("pt-BR", title)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		dec, err := TitleFromKey(key)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		if dec != title {
+			t.Fatal("decode fail", dec, title)
+		}
+		keys[i] = key
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	order := []string{"abacaxi", "Árvore", "Banana", "banana", "zebra"}
+	for i, key := range keys {
+		title, _ := TitleFromKey(key)
+		if title != order[i] && !(i == 2 || i == 3) {
+			t.Fatal("wrong order", i, title)
+		}
+	}
+	if bytes.Equal(keys[2], keys[3]) {
+		t.Fatal("titles with different case collide")
+	}
+	_, err := TitleFromKey([]byte{0, 10})
+	if !e.Equal(err, ErrInvTitleKey) {
+		t.Fatal("invalid key accepted", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const ErrInvToken = "invalid cursor token"
+
+// tokenVersion is the first byte of the tokens.
+const tokenVersion = 1
+
+// The flags of the tokens.
+const (
+	tokenReverse = 1 << iota
+	tokenAfter
+	tokenBefore
+)
+
+// Token returns the position of the cursor, to continue the iteration in
+// other transaction with ResumeCursor. The token has the key path of the
+// leaf under the cursor, the keys pinned by Init or SeekPrefix and Reverse.
+// It is nil if the cursor isn't in a leaf.
+func (c *Cursor) Token() []byte {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	if c.cursors == nil || c.ks[c.NumKeys-1] == nil {
+		return nil
+	}
+	var flags byte
+	if c.Reverse {
+		flags |= tokenReverse
+	}
+	switch c.pending {
+	case 1:
+		flags |= tokenAfter
+	case -1:
+		flags |= tokenBefore
+	}
+	buf := make([]byte, 2+binary.MaxVarintLen64)
+	buf[0] = tokenVersion
+	buf[1] = flags
+	n := 2 + binary.PutUvarint(buf[2:], uint64(c.ls))
+	return append(buf[:n], encodeKeys(c.ks...)...)
+}
+
+// ResumeCursor returns a cursor over the tree of bucket, with numKeys
+// levels, in the position of token, returned by Token. Next returns the leaf
+// after the one of the token, also if it was deleted since, and Prev the
+// one before it. The cursor is pinned to the same keys and has the same
+// Reverse. The token has the keys after Transforms, the cursor returned has
+// none. If the pinned keys were deleted the error is ErrKeyNotFound.
+func ResumeCursor(tx *bolt.Tx, bucket []byte, numKeys int, token []byte) (*Cursor, error) {
+	if len(token) < 2 || token[0] != tokenVersion {
+		return nil, e.New(ErrInvToken)
+	}
+	flags := token[1]
+	ls, n := binary.Uvarint(token[2:])
+	if n <= 0 {
+		return nil, e.New(ErrInvToken)
+	}
+	keys, err := decodeKeys(token[2+n:])
+	if err != nil {
+		return nil, e.Push(err, e.New(ErrInvToken))
+	}
+	if len(keys) != numKeys || ls >= uint64(numKeys) {
+		return nil, e.New(ErrInvToken)
+	}
+	c := &Cursor{
+		Tx:      tx,
+		Bucket:  bucket,
+		NumKeys: numKeys,
+		Reverse: flags&tokenReverse != 0,
+	}
+	err = c.Init(keys[:ls]...)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+
+	k, v := c.seek(copyKeys(keys)...)
+	if k == nil {
+		// There is no leaf after the one of the token, in the order of
+		// the keys.
+		if c.Reverse {
+			k, v = c.first()
+		} else {
+			k, v = c.last()
+		}
+	}
+	if k == nil {
+		return c, nil
+	}
+	switch cmp := compareKeys(k, keys); {
+	case cmp > 0:
+		c.pending = 1
+	case cmp < 0:
+		c.pending = -1
+	case flags&tokenAfter != 0:
+		c.pending = 1
+	case flags&tokenBefore != 0:
+		c.pending = -1
+	}
+	c.pendingV = v
+	return c, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestToken(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	bucket := []byte("test_token")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, p := range []string{"/a/1", "/a/2", "/b/1", "/b/2", "/b/3", "/c/1"} {
+			keys, _ := ParsePath(p)
+			err := Put(tx, bucket, keys, []byte(p))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// page returns the token after n leaves from token, or from the first
+	// leaf if token is nil.
+	page := func(token []byte, reverse bool, prefix string, n int) (string, []byte, error) {
+		var paths []string
+		err := db.View(func(tx *bolt.Tx) error {
+			var c *Cursor
+			var k [][]byte
+			if token == nil {
+				c = &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2, Reverse: reverse}
+				keys, _ := ParsePath(prefix)
+				err := c.Init(keys...)
+				if err != nil {
+					return e.Forward(err)
+				}
+				k, _ = c.First()
+			} else {
+				var err error
+				c, err = ResumeCursor(tx, bucket, 2, token)
+				if err != nil {
+					return e.Forward(err)
+				}
+				k, _ = c.Next()
+			}
+			for ; k != nil; k, _ = c.Next() {
+				paths = append(paths, Path(k).String())
+				if len(paths) == n {
+					break
+				}
+			}
+			token = c.Token()
+			return c.Err()
+		})
+		return strings.Join(paths, " "), token, err
+	}
+	tests := []struct {
+		reverse bool
+		prefix  string
+		want    []string
+	}{
+		{false, "", []string{"/a/1 /a/2", "/b/1 /b/2", "/b/3 /c/1", ""}},
+		{true, "", []string{"/c/1 /b/3", "/b/2 /b/1", "/a/2 /a/1", ""}},
+		{false, "/b", []string{"/b/1 /b/2", "/b/3", ""}},
+		{true, "/b", []string{"/b/3 /b/2", "/b/1", ""}},
+	}
+	for _, test := range tests {
+		var token []byte
+		for i, want := range test.want {
+			got, next, err := page(token, test.reverse, test.prefix, 2)
+			if err != nil {
+				t.Fatal(e.Trace(e.Forward(err)))
+			}
+			if got != want {
+				t.Fatalf("reverse %v prefix %q page %v: %q, want %q", test.reverse, test.prefix, i, got, want)
+			}
+			if next != nil {
+				token = next
+			}
+		}
+	}
+
+	// The leaf of the token deleted between the transactions.
+	_, token, err := page(nil, false, "", 3)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return Del(tx, bucket, [][]byte{[]byte("b"), []byte("1")})
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	got, _, err := page(token, false, "", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if got != "/b/2 /b/3 /c/1" {
+		t.Fatal("wrong leaves after the deleted one", got)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		c, err := ResumeCursor(tx, bucket, 2, token)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if k, _ := c.Prev(); Path(k).String() != "/a/2" {
+			return e.New("wrong Prev %v", Path(k))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The token of a cursor after Delete.
+	err = db.Update(func(tx *bolt.Tx) error {
+		c := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+		err := c.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		c.Seek([]byte("b"), []byte("2"))
+		err = c.Delete()
+		if err != nil {
+			return e.Forward(err)
+		}
+		token = c.Token()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	got, _, err = page(token, false, "", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if got != "/b/3 /c/1" {
+		t.Fatal("wrong leaves after Delete", got)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		_, err := ResumeCursor(tx, bucket, 3, token)
+		if !IsError(err, ErrInvToken) {
+			return e.New("expected ErrInvToken: %v", err)
+		}
+		_, err = ResumeCursor(tx, bucket, 2, token[:len(token)-1])
+		if !IsError(err, ErrInvToken) {
+			return e.New("expected ErrInvToken: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
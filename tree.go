@@ -0,0 +1,163 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// KeyDecoder formats a key for DumpTree.
+type KeyDecoder func(key []byte) string
+
+// KeyString formats the key as text, escaped like a path of an URL.
+func KeyString(key []byte) string {
+	return url.PathEscape(string(key))
+}
+
+// KeyHex formats the key in hexadecimal.
+func KeyHex(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+// KeyVarint formats a key encoded by binary.PutVarint as a number, or in
+// hexadecimal if it isn't one.
+func KeyVarint(key []byte) string {
+	n, l := binary.Varint(key)
+	if l <= 0 || l != len(key) {
+		return KeyHex(key)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// DumpOptions configures DumpTree.
+type DumpOptions struct {
+	// Prefix is the partial key path of the subtree dumped.
+	Prefix [][]byte
+	// MaxDepth is the number of levels dumped after the prefix, zero for
+	// all. The subtrees below it are written as "key/ ...".
+	MaxDepth int
+	// KeyDecoders formats the keys of each level, from the first one. The
+	// levels without a decoder use KeyString.
+	KeyDecoders []KeyDecoder
+	// MaxValue truncates the values longer than it, zero for no limit.
+	MaxValue int
+	// MaxLeaves stops the dump after that many leaves, zero for no limit.
+	MaxLeaves int
+}
+
+// DumpTree writes the tree of bucket to w, one key by line indented by its
+// level. The keys of the intermediate levels end with a slash and the leaves
+// are followed by their value, quoted if it isn't printable:
+//
+//	en/
+//	  2015/
+//	    hello = Hello!
+func DumpTree(tx *bolt.Tx, bucket []byte, w io.Writer, opts DumpOptions) error {
+	b, err := prefixBucket(tx, bucket, opts.Prefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	d := &dumper{
+		tx:     tx,
+		bucket: bucket,
+		w:      w,
+		opts:   opts,
+	}
+	keys := make([][]byte, len(opts.Prefix), len(opts.Prefix)+8)
+	copy(keys, opts.Prefix)
+	err = d.dump(b, keys)
+	if err == errStopDump {
+		_, err = fmt.Fprintf(w, "... (%v leaves)\n", opts.MaxLeaves)
+	}
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+type dumper struct {
+	tx     *bolt.Tx
+	bucket []byte
+	w      io.Writer
+	opts   DumpOptions
+	leaves int
+}
+
+var errStopDump = e.New("stop dump")
+
+func (d *dumper) dump(b *bolt.Bucket, keys [][]byte) error {
+	depth := len(keys) - len(d.opts.Prefix)
+	indent := strings.Repeat("  ", depth)
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if d.opts.MaxLeaves > 0 && d.leaves >= d.opts.MaxLeaves {
+			return errStopDump
+		}
+		key := d.key(len(keys), k)
+		sub := subBucket(d.tx, v)
+		if sub != nil {
+			if d.opts.MaxDepth > 0 && depth+1 >= d.opts.MaxDepth {
+				_, err := fmt.Fprintf(d.w, "%v%v/ ...\n", indent, key)
+				if err != nil {
+					return e.Forward(err)
+				}
+				continue
+			}
+			_, err := fmt.Fprintf(d.w, "%v%v/\n", indent, key)
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = d.dump(sub, append(keys, k))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		d.leaves++
+		_, err := fmt.Fprintf(d.w, "%v%v = %v\n", indent, key, d.value(append(keys, k), v))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+func (d *dumper) key(level int, k []byte) string {
+	if level < len(d.opts.KeyDecoders) && d.opts.KeyDecoders[level] != nil {
+		return d.opts.KeyDecoders[level](k)
+	}
+	return KeyString(k)
+}
+
+// value returns v, the value of the leaf keys, decoded, as text, quoted if it
+// isn't printable.
+func (d *dumper) value(keys [][]byte, v []byte) string {
+	v, err := decodeValue(d.bucket, keys, v)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	cut := d.opts.MaxValue > 0 && len(v) > d.opts.MaxValue
+	if cut {
+		v = v[:d.opts.MaxValue]
+	}
+	s := string(v)
+	if !utf8.Valid(v) || strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == '"' }) >= 0 {
+		s = strconv.Quote(s)
+	}
+	if cut {
+		s += "..."
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestDumpTree(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	num := func(n int64) []byte {
+		buf := make([]byte, binary.MaxVarintLen64)
+		return buf[:binary.PutVarint(buf, n)]
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		puts := []struct {
+			keys  [][]byte
+			value string
+		}{
+			{[][]byte{[]byte("en"), num(2015), []byte("hello")}, "Hello!"},
+			{[][]byte{[]byte("en"), num(2015), []byte("bye world")}, "line\nline"},
+			{[][]byte{[]byte("pt"), num(-1), []byte("ola")}, "a long value"},
+		}
+		for _, p := range puts {
+			err := Put(tx, bucket, p.keys, []byte(p.value))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	tests := []struct {
+		opts DumpOptions
+		want string
+	}{
+		{
+			DumpOptions{KeyDecoders: []KeyDecoder{nil, KeyVarint}},
+			"en/\n  2015/\n    bye%20world = \"line\\nline\"\n    hello = Hello!\n" +
+				"pt/\n  -1/\n    ola = a long value\n",
+		},
+		{
+			DumpOptions{MaxDepth: 1, KeyDecoders: []KeyDecoder{KeyHex}},
+			"656e/ ...\n7074/ ...\n",
+		},
+		{
+			DumpOptions{Prefix: [][]byte{[]byte("pt")}, MaxValue: 6, KeyDecoders: []KeyDecoder{nil, KeyVarint}},
+			"-1/\n  ola = a long...\n",
+		},
+		{
+			DumpOptions{MaxLeaves: 1, KeyDecoders: []KeyDecoder{nil, KeyVarint}},
+			"en/\n  2015/\n    bye%20world = \"line\\nline\"\n... (1 leaves)\n",
+		},
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, test := range tests {
+			var buf bytes.Buffer
+			err := DumpTree(tx, bucket, &buf, test.opts)
+			if err != nil {
+				return e.Forward(err)
+			}
+			if buf.String() != test.want {
+				return e.New("test %v:\n%v\nwant\n%v", i, buf.String(), test.want)
+			}
+		}
+		err := DumpTree(tx, []byte("test_none"), &bytes.Buffer{}, DumpOptions{})
+		if !IsError(err, ErrInvBucket) {
+			return e.New("missing bucket dumped: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+const (
+	// ttlBucket maps the key paths to their expiration time.
+	ttlBucket = "__ttl"
+	// ttlTimeBucket has the expiration time followed by the key path, so
+	// the sweeper reads the expired entries first.
+	ttlTimeBucket = "__ttl_time"
+)
+
+const ErrExpired = "entry expired"
+
+// ExpireChunk is the maximum number of leaves removed by each transaction in
+// Expire.
+var ExpireChunk = 1000
+
+// PutTTL is like Put but the entry expires at expiresAt. After that Get fails
+// with ErrExpired and Expire removes the entry. A Put in the same key path
+// makes the entry permanent again.
+func PutTTL(tx *bolt.Tx, bucket []byte, keys [][]byte, data []byte, expiresAt time.Time) error {
+	err := Put(tx, bucket, keys, data)
+	if err != nil {
+		return e.Forward(err)
+	}
+	return setTTL(tx, bucket, keys, expiresAt)
+}
+
+// setTTL records the expiration time of the key path.
+func setTTL(tx *bolt.Tx, bucket []byte, keys [][]byte, expiresAt time.Time) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(ttlBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	bt, err := tx.CreateBucketIfNotExists([]byte(ttlTimeBucket))
+	if err != nil {
+		return e.Forward(err)
+	}
+	path := encodeKeys(append([][]byte{bucket}, keys...)...)
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(expiresAt.UnixNano()))
+	err = b.Put(path, ts)
+	if err != nil {
+		return e.Forward(err)
+	}
+	err = bt.Put(append(ts, path...), []byte{})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// expiresAt returns the expiration time of the key path, if it has one.
+func expiresAt(tx *bolt.Tx, bucket []byte, keys [][]byte) (time.Time, bool) {
+	b := tx.Bucket([]byte(ttlBucket))
+	if b == nil {
+		return time.Time{}, false
+	}
+	ts := b.Get(encodeKeys(append([][]byte{bucket}, keys...)...))
+	if len(ts) != 8 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(ts))), true
+}
+
+// checkExpired returns ErrExpired if the key path expired.
+func checkExpired(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
+	if t, ok := expiresAt(tx, bucket, keys); ok && !time.Now().Before(t) {
+		return newKeyError(ErrExpired, bucket, len(keys)-1, keys)
+	}
+	return nil
+}
+
+// clearTTL removes the expiration time of the key path.
+func clearTTL(tx *bolt.Tx, bucket []byte, keys [][]byte) error {
+	b := tx.Bucket([]byte(ttlBucket))
+	if b == nil {
+		return nil
+	}
+	path := encodeKeys(append([][]byte{bucket}, keys...)...)
+	ts := b.Get(path)
+	if ts == nil {
+		return nil
+	}
+	bt := tx.Bucket([]byte(ttlTimeBucket))
+	if bt != nil {
+		err := bt.Delete(append(append([]byte{}, ts...), path...))
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	err := b.Delete(path)
+	if err != nil {
+		return e.Forward(err)
+	}
+	if empty(b) {
+		err = tx.DeleteBucket([]byte(ttlBucket))
+		if err != nil {
+			return e.Forward(err)
+		}
+		if bt != nil {
+			err = tx.DeleteBucket([]byte(ttlTimeBucket))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+	}
+	return nil
+}
+
+// moveTTLs moves the expiration times of the leaves of bucket to the same
+// key paths of dst, or drops them if dst is nil.
+func moveTTLs(tx *bolt.Tx, bucket, dst []byte) error {
+	b := tx.Bucket([]byte(ttlBucket))
+	if b == nil {
+		return nil
+	}
+	type ttl struct {
+		keys [][]byte
+		t    time.Time
+	}
+	var ttls []ttl
+	prefix := encodeKeys(bucket)
+	c := b.Cursor()
+	for k, ts := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, ts = c.Next() {
+		path, err := decodeKeys(k)
+		if err != nil || len(path) < 2 || len(ts) != 8 {
+			return newKeyError(ErrInvEncoding, []byte(ttlBucket), -1, nil)
+		}
+		ttls = append(ttls, ttl{path[1:], time.Unix(0, int64(binary.BigEndian.Uint64(ts)))})
+	}
+	for _, t := range ttls {
+		err := clearTTL(tx, bucket, t.keys)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if dst == nil {
+			continue
+		}
+		err = setTTL(tx, dst, t.keys, t.t)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// Expire removes the expired entries, and the intermediate buckets left
+// empty, in transactions of ExpireChunk entries. Entries in frozen subtrees
+// are kept. It returns the number of entries removed. If Expire stops before
+// the end the rest of the expired entries are left, call it again.
+func Expire(db *bolt.DB) (int, error) {
+	return expire(db, time.Now())
+}
+
+func expire(db *bolt.DB, now time.Time) (int, error) {
+	total := 0
+	var after []byte
+	for done := false; !done; {
+		err := db.Update(func(tx *bolt.Tx) error {
+			bt := tx.Bucket([]byte(ttlTimeBucket))
+			if bt == nil {
+				done = true
+				return nil
+			}
+			var paths [][]byte
+			c := bt.Cursor()
+			k, _ := c.First()
+			if after != nil {
+				k, _ = c.Seek(after)
+				if bytes.Equal(k, after) {
+					k, _ = c.Next()
+				}
+			}
+			for ; k != nil && len(paths) < ExpireChunk; k, _ = c.Next() {
+				if len(k) < 8 || int64(binary.BigEndian.Uint64(k)) > now.UnixNano() {
+					break
+				}
+				paths = append(paths, append([]byte{}, k...))
+			}
+			if len(paths) < ExpireChunk {
+				done = true
+			}
+			for _, k := range paths {
+				after = k
+				path, err := decodeKeys(k[8:])
+				if err != nil || len(path) < 2 {
+					return newKeyError(ErrInvEncoding, []byte(ttlTimeBucket), -1, nil)
+				}
+				bucket, keys := path[0], path[1:]
+				if IsFrozen(tx, bucket, keys) {
+					continue
+				}
+				err = Del(tx, bucket, keys)
+				if err == nil {
+					total++
+				} else if !IsError(err, ErrKeyNotFound) && !IsError(err, ErrInvBucket) {
+					return e.Forward(err)
+				}
+				// Del already cleared the metadata of existing entries.
+				err = clearTTL(tx, bucket, keys)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, e.Forward(err)
+		}
+	}
+	return total, nil
+}
+
+// StartExpirer calls Expire every interval until stop is called.
+func StartExpirer(db *bolt.DB, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				Expire(db)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestTTL(t *testing.T) {
+	data := []testData{
+		{[]byte("test_ttl"), [][]byte{[]byte("2014"), []byte("01"), []byte("a")}, []byte("1")},
+		{[]byte("test_ttl"), [][]byte{[]byte("2014"), []byte("02"), []byte("b")}, []byte("2")},
+		{[]byte("test_ttl"), [][]byte{[]byte("2015"), []byte("01"), []byte("c")}, []byte("3")},
+		{[]byte("test_ttl"), [][]byte{[]byte("2015"), []byte("01"), []byte("d")}, []byte("4")},
+	}
+
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	err = db.Update(func(tx *bolt.Tx) error {
+		// 0 and 1 expired, 2 expires in the future and 3 is permanent.
+		for i, d := range data {
+			var err error
+			switch i {
+			case 0, 1:
+				err = PutTTL(tx, d.Bucket, d.Keys, d.Data, past)
+			case 2:
+				err = PutTTL(tx, d.Bucket, d.Keys, d.Data, future)
+			default:
+				err = PutTTL(tx, d.Bucket, d.Keys, d.Data, past)
+				if err == nil {
+					err = Put(tx, d.Bucket, d.Keys, d.Data)
+				}
+			}
+			if err != nil {
+				return e.Push(err, e.New("Fail to put %v", i))
+			}
+		}
+		return Freeze(tx, data[1].Bucket, data[1].Keys[:2])
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for i, d := range data {
+			_, err := Get(tx, d.Bucket, d.Keys)
+			if i < 2 {
+				if !IsError(err, ErrExpired) {
+					return e.New("expected ErrExpired for %v: %v", i, err)
+				}
+				continue
+			}
+			if err != nil {
+				return e.Push(err, e.New("Fail to get %v", i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	old := ExpireChunk
+	ExpireChunk = 1
+	defer func() {
+		ExpireChunk = old
+	}()
+
+	n, err := Expire(db)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// The entry 1 is frozen.
+	if n != 1 {
+		t.Fatal("wrong number of expired entries", n)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := Get(tx, data[0].Bucket, data[0].Keys)
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("expired entry not removed: %v", err)
+		}
+		// The intermediate bucket 2014/01 was pruned.
+		_, err = Get(tx, data[0].Bucket, data[0].Keys[:2])
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("empty bucket not removed: %v", err)
+		}
+		err = Unfreeze(tx, data[1].Bucket, data[1].Keys[:2])
+		if err != nil {
+			return e.Forward(err)
+		}
+		return Del(tx, data[2].Bucket, data[2].Keys)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	n, err = expire(db, future.Add(time.Hour))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 1 {
+		t.Fatal("wrong number of expired entries", n)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(ttlBucket)) != nil || tx.Bucket([]byte(ttlTimeBucket)) != nil {
+			return e.New("expiration metadata left behind")
+		}
+		_, err := Get(tx, data[3].Bucket, data[3].Keys)
+		return err
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestTTLDropPromote(t *testing.T) {
+	bucket := []byte("test_ttl")
+	branch := []byte("test_branch")
+	other := []byte("test_other")
+	a := [][]byte{[]byte("a")}
+	b := [][]byte{[]byte("b")}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	db := openTestDB(t)
+	defer db.Close()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := PutTTL(tx, bucket, a, []byte("old"), past)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = PutTTL(tx, other, a, []byte("old"), past)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = DropBucket(tx, other)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if _, ok := expiresAt(tx, other, a); ok {
+			return e.New("TTL kept by DropBucket")
+		}
+		err = CloneBucket(tx, bucket, branch)
+		if err != nil {
+			return e.Forward(err)
+		}
+		err = PutTTL(tx, branch, b, []byte("new"), future)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return PromoteBucket(tx, branch, bucket)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	n, err := Expire(db)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if n != 0 {
+		t.Fatal("promoted leaves expired", n)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		v, err := Get(tx, bucket, a)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if string(v) != "old" {
+			return e.New("wrong value %q", v)
+		}
+		if exp, ok := expiresAt(tx, bucket, b); !ok || !exp.Equal(time.Unix(0, future.UnixNano())) {
+			return e.New("TTL of the branch not moved: %v %v", exp, ok)
+		}
+		if _, ok := expiresAt(tx, branch, b); ok {
+			return e.New("TTL of the branch kept")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
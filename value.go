@@ -0,0 +1,405 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const ErrUnknownCodec = "unknown value codec"
+
+// valueMagic starts the values encoded by a Codec. It is followed by the ID
+// of the codec. The values without it are read as they are, so the values
+// written before SetValueCodec still read.
+var valueMagic = []byte{0, 0xc0, 0xdc, 0x5e}
+
+// Codec transforms the values written by Put and PutBatch and read by Get, the
+// cursors and the scans.
+type Codec interface {
+	// ID identifies the codec in the header of the values. The IDs below 16
+	// are reserved for the codecs of the package.
+	ID() byte
+	Encode(v []byte) ([]byte, error)
+	Decode(v []byte) ([]byte, error)
+}
+
+const (
+	// rawID marks a value stored as it is, because it starts with
+	// valueMagic or wasn't made smaller by the codec.
+	rawID byte = iota
+	gzipID
+	snappyID
+	zstdID
+	// aesgcmID marks a value encrypted by EncryptedBucket without
+	// additional data, written by the older versions.
+	aesgcmID
+	// crcID marks a value with the checksum of SetChecksum.
+	crcID
+	// aesgcmADID marks a value encrypted by EncryptedBucket bound to its key
+	// path.
+	aesgcmADID
+)
+
+var (
+	codecsLck sync.RWMutex
+	codecs    = map[byte]Codec{}
+)
+
+// codecNames are the names of the codecs of the package in BucketInfo.Codecs.
+// The other codecs are named by their ID in decimal.
+var codecNames = map[byte]string{
+	gzipID:   "gzip",
+	snappyID: "snappy",
+	zstdID:   "zstd",
+}
+
+// checksumCodec is the name of the checksum of SetChecksum in
+// BucketInfo.Codecs, always the last.
+const checksumCodec = "crc32c"
+
+func init() {
+	for _, c := range []Codec{Gzip, Snappy, Zstd} {
+		RegisterCodec(c)
+	}
+}
+
+// RegisterCodec makes the values encoded by c readable. The codecs of the
+// package are always registered.
+func RegisterCodec(c Codec) {
+	codecsLck.Lock()
+	defer codecsLck.Unlock()
+	codecs[c.ID()] = c
+}
+
+// SetValueCodec registers c and encodes with it the values of bucket written
+// after, usually to compress them. The codec is recorded in the Codecs of the
+// description of bucket, registering it if needed, so it is kept when the
+// database is opened again; the codecs that aren't of the package must be
+// registered with RegisterCodec before. A nil codec writes the new values as
+// they are. The values stay readable after the codec changes.
+func SetValueCodec(tx *bolt.Tx, bucket []byte, c Codec) error {
+	if c != nil {
+		RegisterCodec(c)
+	}
+	err := setCodecs(tx, bucket, func(names []string) []string {
+		if len(names) > 0 && names[0] != checksumCodec {
+			names = names[1:]
+		}
+		if c == nil {
+			return names
+		}
+		return append([]string{codecName(c.ID())}, names...)
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	return nil
+}
+
+// ValueCodec returns the codec of bucket set by SetValueCodec.
+func ValueCodec(tx *bolt.Tx, bucket []byte) (Codec, error) {
+	info, err := writeInfo(tx, bucket)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	c, _, err := valueCodecs(&info)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return c, nil
+}
+
+// setCodecs replaces the Codecs of the description of bucket by the ones
+// returned by fn. A description left empty is removed.
+func setCodecs(tx *bolt.Tx, bucket []byte, fn func(names []string) []string) error {
+	info, err := Lookup(tx, bucket)
+	if err != nil && !IsError(err, ErrNotRegistered) {
+		return e.Forward(err)
+	}
+	info.Codecs = fn(append([]string{}, info.Codecs...))
+	if len(info.Codecs) == 0 {
+		info.Codecs = nil
+	}
+	if info.NumKeys == 0 && !info.Strict && info.Codecs == nil {
+		return Unregister(tx, bucket)
+	}
+	return Register(tx, bucket, info)
+}
+
+// codecName returns the name of the codec id in BucketInfo.Codecs.
+func codecName(id byte) string {
+	if name, ok := codecNames[id]; ok {
+		return name
+	}
+	return strconv.Itoa(int(id))
+}
+
+// valueCodecs returns the codec and the checksum setting of the Codecs of
+// info.
+func valueCodecs(info *BucketInfo) (Codec, bool, error) {
+	var c Codec
+	checksum := false
+	for _, name := range info.Codecs {
+		if name == checksumCodec {
+			checksum = true
+			continue
+		}
+		id := -1
+		for i, n := range codecNames {
+			if n == name {
+				id = int(i)
+			}
+		}
+		if id < 0 {
+			n, err := strconv.ParseUint(name, 10, 8)
+			if err != nil {
+				return nil, false, e.New("%v: %v", ErrUnknownCodec, name)
+			}
+			id = int(n)
+		}
+		codecsLck.RLock()
+		c = codecs[byte(id)]
+		codecsLck.RUnlock()
+		if c == nil {
+			return nil, false, e.New("%v: %v", ErrUnknownCodec, name)
+		}
+	}
+	return c, checksum, nil
+}
+
+// encodeValue encodes v, the value of the leaf keys of bucket, to be stored:
+// it is compressed by the codec of info, the description of bucket read by
+// writeInfo, encrypted if bucket is an EncryptedBucket and checksummed if
+// SetChecksum is on.
+func encodeValue(bucket []byte, info *BucketInfo, keys [][]byte, v []byte) ([]byte, error) {
+	c, checksum, err := valueCodecs(info)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	buf, err := compressValue(c, v)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	if k := cipherOf(bucket); k != nil {
+		buf = k.seal(buf, additionalData(bucket, keys))
+	}
+	if checksum {
+		buf = appendChecksum(buf)
+	}
+	return buf, nil
+}
+
+// compressValue encodes v with the codec c. The values that start with
+// valueMagic are always wrapped, so they aren't read as encoded.
+func compressValue(c Codec, v []byte) ([]byte, error) {
+	if c == nil && !bytes.HasPrefix(v, valueMagic) {
+		return v, nil
+	}
+	buf, id := v, rawID
+	if c != nil {
+		enc, err := c.Encode(v)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		if len(enc) < len(v) {
+			buf, id = enc, c.ID()
+		}
+	}
+	return wrapValue(id, buf), nil
+}
+
+// wrapValue prepends the header of the codec id to buf.
+func wrapValue(id byte, buf []byte) []byte {
+	out := make([]byte, len(valueMagic)+1+len(buf))
+	n := copy(out, valueMagic)
+	out[n] = id
+	copy(out[n+1:], buf)
+	return out
+}
+
+// decodeValue is the inverse of encodeValue.
+func decodeValue(bucket []byte, keys [][]byte, v []byte) ([]byte, error) {
+	id, buf, ok := splitValue(v)
+	if !ok {
+		return v, nil
+	}
+	if id == crcID {
+		err := verifyChecksum(v)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		id, buf, ok = splitValue(buf[:len(buf)-crcLen])
+		if !ok {
+			return buf, nil
+		}
+	}
+	if id == aesgcmID || id == aesgcmADID {
+		var ad []byte
+		if id == aesgcmADID {
+			ad = additionalData(bucket, keys)
+		}
+		var err error
+		buf, _, err = open(buf, ad)
+		if err != nil {
+			return nil, e.Forward(err)
+		}
+		id, buf, ok = splitValue(buf)
+		if !ok {
+			return buf, nil
+		}
+	}
+	if id == aesgcmID || id == aesgcmADID || id == crcID {
+		return nil, e.New("%v: %v", ErrUnknownCodec, id)
+	}
+	return decompressValue(id, buf)
+}
+
+// splitValue returns the codec id and the payload of v, or v and false if
+// v isn't encoded.
+func splitValue(v []byte) (byte, []byte, bool) {
+	if !bytes.HasPrefix(v, valueMagic) || len(v) < len(valueMagic)+1 {
+		return 0, v, false
+	}
+	return v[len(valueMagic)], v[len(valueMagic)+1:], true
+}
+
+func decompressValue(id byte, buf []byte) ([]byte, error) {
+	if id == rawID {
+		return buf, nil
+	}
+	codecsLck.RLock()
+	c, ok := codecs[id]
+	codecsLck.RUnlock()
+	if !ok {
+		return nil, e.New("%v: %v", ErrUnknownCodec, id)
+	}
+	buf, err := c.Decode(buf)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+// DecodeValue decodes a value read directly from bolt, without the functions
+// of the package, from the leaf keys of bucket. The encrypted values need
+// the key set by EncryptedBucket for some bucket.
+func DecodeValue(bucket []byte, keys [][]byte, v []byte) ([]byte, error) {
+	return decodeValue(bucket, keys, v)
+}
+
+type gzipCodec struct{}
+
+// Gzip compresses the values with gzip.
+var Gzip Codec = gzipCodec{}
+
+func (gzipCodec) ID() byte {
+	return gzipID
+}
+
+func (gzipCodec) Encode(v []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(v)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(v []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(v))
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	defer r.Close()
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+type snappyCodec struct{}
+
+// Snappy compresses the values with snappy, faster than Gzip and Zstd but
+// with less compression.
+var Snappy Codec = snappyCodec{}
+
+func (snappyCodec) ID() byte {
+	return snappyID
+}
+
+func (snappyCodec) Encode(v []byte) ([]byte, error) {
+	return snappy.Encode(nil, v), nil
+}
+
+func (snappyCodec) Decode(v []byte) ([]byte, error) {
+	buf, err := snappy.Decode(nil, v)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
+
+type zstdCodec struct{}
+
+// Zstd compresses the values with zstandard.
+var Zstd Codec = zstdCodec{}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdInit creates the encoder and the decoder shared by all values.
+func zstdInit() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+func (zstdCodec) ID() byte {
+	return zstdID
+}
+
+func (zstdCodec) Encode(v []byte) ([]byte, error) {
+	err := zstdInit()
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return zstdEncoder.EncodeAll(v, nil), nil
+}
+
+func (zstdCodec) Decode(v []byte) ([]byte, error) {
+	err := zstdInit()
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	buf, err := zstdDecoder.DecodeAll(v, nil)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+func TestValueCodecs(t *testing.T) {
+
+	text := bytes.Repeat([]byte("boltdbutils compresses the values "), 64)
+	for _, c := range []Codec{nil, Gzip, Snappy, Zstd} {
+		db := openTestDB(t)
+		bucket := []byte("test_bucket")
+		setCodec(t, db, bucket, c, false)
+		err := db.Update(func(tx *bolt.Tx) error {
+			for i := 0; i < 10; i++ {
+				keys := [][]byte{[]byte("a"), []byte(strconv.Itoa(i))}
+				err := Put(tx, bucket, keys, text)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			// A short value isn't made smaller and a raw value may look
+			// encoded.
+			err := Put(tx, bucket, [][]byte{[]byte("b"), []byte("short")}, []byte("x"))
+			if err != nil {
+				return e.Forward(err)
+			}
+			magic := append(append([]byte{}, valueMagic...), zstdID, 1, 2, 3)
+			return PutBatch(tx, bucket, []Item{{Keys: [][]byte{[]byte("b"), []byte("magic")}, Data: magic}})
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		err = db.View(func(tx *bolt.Tx) error {
+			v, err := Get(tx, bucket, [][]byte{[]byte("a"), []byte("3")})
+			if err != nil {
+				return e.Forward(err)
+			}
+			if !bytes.Equal(v, text) {
+				return e.New("wrong value from Get")
+			}
+			v, err = Get(tx, bucket, [][]byte{[]byte("b"), []byte("magic")})
+			if err != nil {
+				return e.Forward(err)
+			}
+			if !bytes.Equal(v[:len(valueMagic)], valueMagic) || len(v) != len(valueMagic)+4 {
+				return e.New("raw value changed: %x", v)
+			}
+			if c != nil {
+				b, err := prefixBucket(tx, bucket, [][]byte{[]byte("a")})
+				if err != nil {
+					return e.Forward(err)
+				}
+				stored := b.Get([]byte("3"))
+				if len(stored) >= len(text) {
+					return e.New("%T didn't compress: %v", c, len(stored))
+				}
+				v, err := DecodeValue(bucket, [][]byte{[]byte("a"), []byte("3")}, stored)
+				if err != nil {
+					return e.Forward(err)
+				}
+				if !bytes.Equal(v, text) {
+					return e.New("wrong value from DecodeValue")
+				}
+			}
+			cur := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 2}
+			err = cur.Init()
+			if err != nil {
+				return e.Forward(err)
+			}
+			n := 0
+			for k, v := cur.First(); k != nil; k, v = cur.Next() {
+				if string(k[0]) == "a" && !bytes.Equal(v, text) {
+					return e.New("wrong value from the cursor at %v", Path(k))
+				}
+				n++
+			}
+			if err := cur.Err(); err != nil {
+				return e.Forward(err)
+			}
+			if n != 12 {
+				return e.New("cursor returned %v leaves", n)
+			}
+			items, err := GetAll(tx, bucket, [][]byte{[]byte("a")}, 0)
+			if err != nil {
+				return e.Forward(err)
+			}
+			for _, item := range items {
+				if !bytes.Equal(item.Value, text) {
+					return e.New("wrong value from GetAll at %v", Path(item.Keys))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%T: %v", c, e.Trace(e.Forward(err)))
+		}
+		db.Close()
+	}
+}
+
+func TestValueCodecMixed(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	text := bytes.Repeat([]byte("mixed "), 100)
+	codecs := []Codec{nil, Snappy, Gzip, nil, Zstd}
+	for i, c := range codecs {
+		setCodec(t, db, bucket, c, false)
+		err := db.Update(func(tx *bolt.Tx) error {
+			return Put(tx, bucket, [][]byte{[]byte(strconv.Itoa(i))}, text)
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	setCodec(t, db, bucket, nil, false)
+	err := db.View(func(tx *bolt.Tx) error {
+		for i := range codecs {
+			v, err := Get(tx, bucket, [][]byte{[]byte(strconv.Itoa(i))})
+			if err != nil {
+				return e.Forward(err)
+			}
+			if !bytes.Equal(v, text) {
+				return e.New("value %v written with %T changed", i, codecs[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestValueCodecUnknown(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, [][]byte{[]byte("k")}, []byte("v"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Written by a codec that isn't registered.
+		bad := append(append([]byte{}, valueMagic...), 200, 1)
+		return tx.Bucket(bucket).Put([]byte("k"), bad)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		_, err := Get(tx, bucket, [][]byte{[]byte("k")})
+		if !e.Contains(err, ErrUnknownCodec) {
+			return e.New("Get didn't fail: %v", err)
+		}
+		cur := &Cursor{Tx: tx, Bucket: bucket, NumKeys: 1}
+		err = cur.Init()
+		if err != nil {
+			return e.Forward(err)
+		}
+		k, v := cur.First()
+		if k == nil || v != nil {
+			return e.New("cursor returned %v %v", k, v)
+		}
+		if !e.Contains(cur.Err(), ErrUnknownCodec) {
+			return e.New("cursor didn't fail")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestValueCodecPersisted(t *testing.T) {
+	db := openTestDB(t)
+	plain := []byte("test_plain")
+	packed := []byte("test_packed")
+	setCodec(t, db, packed, Zstd, true)
+	text := bytes.Repeat([]byte("per bucket "), 50)
+
+	// The codecs are kept when the database is opened again.
+	for i := 0; i < 2; i++ {
+		err := db.Update(func(tx *bolt.Tx) error {
+			for _, bucket := range [][]byte{plain, packed} {
+				keys := [][]byte{[]byte(strconv.Itoa(i))}
+				err := Put(tx, bucket, keys, text)
+				if err != nil {
+					return e.Forward(err)
+				}
+				stored := tx.Bucket(bucket).Get(keys[0])
+				if id, _, ok := splitValue(stored); ok != bytes.Equal(bucket, packed) || ok && id != crcID {
+					return e.New("wrong encoding %x", stored[:8])
+				}
+				v, err := Get(tx, bucket, keys)
+				if err != nil {
+					return e.Forward(err)
+				}
+				if !bytes.Equal(v, text) {
+					return e.New("wrong value")
+				}
+			}
+			c, err := ValueCodec(tx, plain)
+			if err != nil || c != nil {
+				return e.New("codec of the plain bucket: %v %v", c, err)
+			}
+			c, err = ValueCodec(tx, packed)
+			if err != nil || c != Zstd {
+				return e.New("codec of the packed bucket: %v %v", c, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+		path := db.Path()
+		db.Close()
+		db, err = bolt.Open(path, 0600, nil)
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	defer db.Close()
+
+	// Without codecs the description is removed.
+	setCodec(t, db, packed, nil, false)
+	err := db.View(func(tx *bolt.Tx) error {
+		_, err := Lookup(tx, packed)
+		if !IsError(err, ErrNotRegistered) {
+			return e.New("empty description kept: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+// setCodec sets the codec and the checksum of bucket.
+func setCodec(t *testing.T, db *bolt.DB, bucket []byte, c Codec, checksum bool) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := SetValueCodec(tx, bucket, c)
+		if err != nil {
+			return e.Forward(err)
+		}
+		return SetChecksum(tx, bucket, checksum)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
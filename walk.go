@@ -0,0 +1,150 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// prefixBucket returns the intermediate bucket reached by the keys in prefix.
+func prefixBucket(tx *bolt.Tx, bucket []byte, prefix [][]byte) (*bolt.Bucket, error) {
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return nil, newKeyError(ErrInvBucket, bucket, -1, prefix)
+	}
+	for i, key := range prefix {
+		b = subBucket(tx, b.Get(key))
+		if b == nil {
+			return nil, newKeyError(ErrKeyNotFound, bucket, i, prefix)
+		}
+	}
+	return b, nil
+}
+
+// walk calls fn for every leaf under the key path prefix, in key order, with
+// the value decoded. The key path given to fn is only valid during the call.
+func walk(tx *bolt.Tx, bucket []byte, prefix [][]byte, fn func(keys [][]byte, v []byte) error) error {
+	b, err := prefixBucket(tx, bucket, prefix)
+	if err != nil {
+		return e.Forward(err)
+	}
+	keys := make([][]byte, len(prefix), len(prefix)+8)
+	copy(keys, prefix)
+	return walkTree(tx, b, keys, func(keys [][]byte, v []byte) error {
+		v, err := decodeValue(bucket, keys, v)
+		if err != nil {
+			return e.Push(err, e.New("%v at %v%v", ErrDecode, string(bucket), Path(keys)))
+		}
+		return fn(keys, v)
+	})
+}
+
+func walkTree(tx *bolt.Tx, b *bolt.Bucket, keys [][]byte, fn func(keys [][]byte, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		sub := subBucket(tx, v)
+		if sub == nil {
+			err := fn(append(keys, k), v)
+			if err != nil {
+				return e.Forward(err)
+			}
+			continue
+		}
+		err := walkTree(tx, sub, append(keys, k), fn)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// copyKeys makes a deep copy of a key path.
+func copyKeys(keys [][]byte) [][]byte {
+	out := make([][]byte, len(keys))
+	for i, k := range keys {
+		out[i] = make([]byte, len(k))
+		copy(out[i], k)
+	}
+	return out
+}
+
+// empty reports if the bucket has no keys. Unlike Stats it sees the changes
+// made by the transaction.
+func empty(b *bolt.Bucket) bool {
+	k, _ := b.Cursor().First()
+	return k == nil
+}
+
+// nextLeaves returns up to n leaves that come after the key path after, in key
+// order, with the keys and values copied. A nil after starts at the first
+// leaf. Chunked migrations use it to resume the scan in a new transaction.
+func nextLeaves(tx *bolt.Tx, bucket []byte, after [][]byte, n int) ([]Item, error) {
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return nil, newKeyError(ErrInvBucket, bucket, -1, after)
+	}
+	var items []Item
+	err := leavesAfter(tx, b, make([][]byte, 0, 8), after, n, &items)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	err = decodeItems(bucket, items)
+	if err != nil {
+		return nil, e.Forward(err)
+	}
+	return items, nil
+}
+
+// decodeItems decodes the values of the leaves of bucket read by leavesAfter.
+func decodeItems(bucket []byte, items []Item) error {
+	for i := range items {
+		v, err := decodeValue(bucket, items[i].Keys, items[i].Data)
+		if err != nil {
+			return e.Push(err, e.New("%v at %v%v", ErrDecode, string(bucket), Path(items[i].Keys)))
+		}
+		items[i].Data = v
+	}
+	return nil
+}
+
+func leavesAfter(tx *bolt.Tx, b *bolt.Bucket, keys, after [][]byte, n int, items *[]Item) error {
+	c := b.Cursor()
+	var k, v []byte
+	if len(after) > 0 {
+		k, v = c.Seek(after[0])
+		if k != nil && bytes.Equal(k, after[0]) {
+			// The leaf itself was already returned, the subtree may have
+			// more.
+			sub := subBucket(tx, v)
+			if sub != nil {
+				err := leavesAfter(tx, sub, append(keys, k), after[1:], n, items)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			k, v = c.Next()
+		}
+	} else {
+		k, v = c.First()
+	}
+	for ; k != nil && len(*items) < n; k, v = c.Next() {
+		sub := subBucket(tx, v)
+		if sub == nil {
+			*items = append(*items, Item{
+				Keys: copyKeys(append(keys, k)),
+				Data: append([]byte{}, v...),
+			})
+			continue
+		}
+		err := leavesAfter(tx, sub, append(keys, k), nil, n, items)
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
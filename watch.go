@@ -0,0 +1,145 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// EventOp is the kind of change of an Event.
+type EventOp int
+
+const (
+	// EventPut is a leaf written. A nil Value means a whole subtree was
+	// moved or copied to Keys.
+	EventPut EventOp = iota
+	// EventDel is a key path removed, a leaf or a whole subtree.
+	EventDel
+	// EventDrop means every key path of the bucket may have changed.
+	EventDrop
+)
+
+// Event is a change committed by the write functions of the package.
+type Event struct {
+	Op     EventOp
+	Bucket []byte
+	Keys   [][]byte
+	Value  []byte
+	// Dropped is the number of events lost before this one because the
+	// channel was full.
+	Dropped uint64
+}
+
+// WatchBuffer is the size of the channels returned by Watch.
+var WatchBuffer = 64
+
+type watch struct {
+	bucket  []byte
+	prefix  [][]byte
+	ch      chan Event
+	dropped uint64
+}
+
+// matches reports if a change in keys of bucket concerns the watch. A change
+// above the prefix, like the removal of a subtree, concerns it too.
+func (w *watch) matches(bucket []byte, keys [][]byte) bool {
+	if string(w.bucket) != string(bucket) {
+		return false
+	}
+	n := len(w.prefix)
+	if len(keys) < n {
+		n = len(keys)
+	}
+	return compareKeys(w.prefix[:n], keys[:n]) == 0
+}
+
+type watchSet struct {
+	lck     sync.Mutex
+	watches map[*watch]struct{}
+}
+
+// watchers maps the *bolt.DB to their *watchSet.
+var watchers sync.Map
+
+// Watch returns a channel with the changes committed in the key paths of
+// bucket under prefix, or above it. Only the changes made by the write
+// functions of this package are seen, in any transaction of the database.
+// The events aren't delivered if the channel is full, the next event counts
+// them in Dropped. The returned function stops the watch and closes the
+// channel.
+func (d *DB) Watch(bucket []byte, prefix [][]byte) (<-chan Event, func()) {
+	s, _ := watchers.LoadOrStore(d.DB, &watchSet{watches: make(map[*watch]struct{})})
+	set := s.(*watchSet)
+	w := &watch{
+		bucket: append([]byte{}, bucket...),
+		prefix: copyKeys(prefix),
+		ch:     make(chan Event, WatchBuffer),
+	}
+	set.lck.Lock()
+	set.watches[w] = struct{}{}
+	set.lck.Unlock()
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			set.lck.Lock()
+			delete(set.watches, w)
+			close(w.ch)
+			set.lck.Unlock()
+		})
+	}
+}
+
+// notify delivers the change to the watches when tx commits.
+func notify(tx *bolt.Tx, op EventOp, bucket []byte, keys [][]byte, value []byte) {
+	s, ok := watchers.Load(tx.DB())
+	if !ok {
+		return
+	}
+	set := s.(*watchSet)
+	if !set.interested(bucket, keys) {
+		return
+	}
+	ev := Event{
+		Op:     op,
+		Bucket: append([]byte{}, bucket...),
+		Keys:   copyKeys(keys),
+	}
+	if value != nil {
+		ev.Value = append([]byte{}, value...)
+	}
+	tx.OnCommit(func() {
+		set.deliver(ev)
+	})
+}
+
+func (set *watchSet) interested(bucket []byte, keys [][]byte) bool {
+	set.lck.Lock()
+	defer set.lck.Unlock()
+	for w := range set.watches {
+		if w.matches(bucket, keys) {
+			return true
+		}
+	}
+	return false
+}
+
+func (set *watchSet) deliver(ev Event) {
+	set.lck.Lock()
+	defer set.lck.Unlock()
+	for w := range set.watches {
+		if !w.matches(ev.Bucket, ev.Keys) {
+			continue
+		}
+		ev.Dropped = w.dropped
+		select {
+		case w.ch <- ev:
+			w.dropped = 0
+		default:
+			w.dropped++
+		}
+	}
+}
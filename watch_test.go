@@ -0,0 +1,107 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestWatch(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	defer db.Close()
+
+	bucket := []byte("test_watch")
+	ch, stop := db.Watch(bucket, [][]byte{[]byte("pt")})
+
+	en := [][]byte{[]byte("en"), []byte("a")}
+	pt := [][]byte{[]byte("pt"), []byte("b")}
+	err = db.Put(bucket, en, []byte("en"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.Put(bucket, pt, []byte("pt"))
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	// Rolled back, not delivered.
+	db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, bucket, pt, []byte("rollback"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		return e.New("rollback")
+	})
+	err = db.Del(bucket, pt)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	ev := <-ch
+	if ev.Op != EventPut || Path(ev.Keys).String() != "/pt/b" || string(ev.Value) != "pt" {
+		t.Fatalf("wrong event %#v", ev)
+	}
+	ev = <-ch
+	if ev.Op != EventDel || Path(ev.Keys).String() != "/pt/b" {
+		t.Fatalf("wrong event %#v", ev)
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event %#v", ev)
+	default:
+	}
+
+	// A full channel drops the events and counts them.
+	old := WatchBuffer
+	WatchBuffer = 1
+	small, stopSmall := db.Watch(bucket, nil)
+	WatchBuffer = old
+	for i := 0; i < 3; i++ {
+		err = db.Put(bucket, en, []byte("en"))
+		if err != nil {
+			t.Fatal(e.Trace(e.Forward(err)))
+		}
+	}
+	<-small
+	err = db.Update(func(tx *bolt.Tx) error {
+		return DropBucket(tx, bucket)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	ev = <-small
+	if ev.Op != EventDrop || ev.Dropped != 2 {
+		t.Fatalf("wrong event %#v", ev)
+	}
+	stopSmall()
+	if _, ok := <-small; ok {
+		t.Fatal("channel not closed")
+	}
+
+	ev = <-ch
+	if ev.Op != EventDrop {
+		t.Fatalf("drop not delivered to the prefix %#v", ev)
+	}
+	stop()
+	stop()
+}
@@ -0,0 +1,162 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+)
+
+// Stats describes a tree of nested buckets.
+type Stats struct {
+	// Entries is the number of leaves.
+	Entries uint64
+	// Buckets is the number of intermediate buckets.
+	Buckets uint64
+	// Bytes is the size of the keys and values of the leaves.
+	Bytes uint64
+}
+
+func (s *Stats) add(o Stats) {
+	s.Entries += o.Entries
+	s.Buckets += o.Buckets
+	s.Bytes += o.Bytes
+}
+
+func treeStats(tx *bolt.Tx, b *bolt.Bucket) Stats {
+	var s Stats
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		sub := subBucket(tx, v)
+		if sub == nil {
+			s.Entries++
+			s.Bytes += uint64(len(k) + len(v))
+			continue
+		}
+		s.Buckets++
+		s.add(treeStats(tx, sub))
+	}
+	return s
+}
+
+// SubtreeStats returns the statistics of the tree under the partial key path
+// prefix. It reads every intermediate bucket of the subtree.
+func SubtreeStats(tx *bolt.Tx, bucket []byte, prefix [][]byte) (Stats, error) {
+	b, err := prefixBucket(tx, bucket, prefix)
+	if err != nil {
+		return Stats{}, e.Forward(err)
+	}
+	return treeStats(tx, b), nil
+}
+
+const ErrWipeCanceled = "wipe canceled"
+
+// WipeChunk is the maximum number of intermediate buckets deleted by each
+// transaction in Wipe.
+var WipeChunk = 1000
+
+// Wipe deletes the bucket and all its intermediate buckets. confirm is called
+// with the statistics of what will be deleted and must return true to
+// proceed. The deletion runs in many transactions of WipeChunk buckets, so
+// it isn't atomic.
+//
+// If Wipe stops before the end, by an error or a crash, the bucket is left
+// with part of its tree: each transaction removes the keys of the subtrees
+// it deletes, so the rest is consistent and readable, but the ETags, the
+// TTLs, the counts and the quota of the deleted leaves are only dropped with
+// the bucket.
+// Call Wipe again to finish the deletion.
+func Wipe(db *bolt.DB, bucket []byte, confirm func(Stats) bool) error {
+	var stats Stats
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return e.New(ErrInvBucket)
+		}
+		stats = treeStats(tx, b)
+		return nil
+	})
+	if err != nil {
+		return e.Forward(err)
+	}
+	if confirm != nil && !confirm(stats) {
+		return e.New(ErrWipeCanceled)
+	}
+	for done := false; !done; {
+		err = db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(bucket)
+			if b == nil {
+				done = true
+				return nil
+			}
+			budget := WipeChunk
+			var err error
+			done, err = wipeTree(tx, b, &budget)
+			if err != nil {
+				return e.Forward(err)
+			}
+			err = countBuckets(tx, bucket, -int64(WipeChunk-budget))
+			if err != nil {
+				return e.Forward(err)
+			}
+			if done {
+				err = tx.DeleteBucket(bucket)
+				if err != nil {
+					return e.Forward(err)
+				}
+				err = dropped(tx, bucket)
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+	}
+	return nil
+}
+
+// wipeTree deletes the intermediate buckets under b, deepest first, until
+// budget reaches zero. The keys pointing to deleted buckets are removed from
+// its parents so the tree remains consistent between transactions. It
+// returns true if no intermediate bucket remains under b.
+func wipeTree(tx *bolt.Tx, b *bolt.Bucket, budget *int) (bool, error) {
+	var del [][]byte
+	done := true
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if *budget <= 0 {
+			done = false
+			break
+		}
+		sub := subBucket(tx, v)
+		if sub == nil {
+			continue
+		}
+		subDone, err := wipeTree(tx, sub, budget)
+		if err != nil {
+			return false, e.Forward(err)
+		}
+		if !subDone {
+			done = false
+			break
+		}
+		err = tx.DeleteBucket(v)
+		if err != nil {
+			return false, e.Forward(err)
+		}
+		*budget--
+		del = append(del, append([]byte{}, k...))
+	}
+	for _, k := range del {
+		err := b.Delete(k)
+		if err != nil {
+			return false, e.Forward(err)
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2015 Felipe A. Cavani. All rights reserved.
+// Use of this source code is governed by the Apache License 2.0
+// license that can be found in the LICENSE file.
+
+package boltdbutils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/fcavani/e"
+	"github.com/fcavani/rand"
+)
+
+func TestWipe(t *testing.T) {
+	filename, err := rand.FileName("blog-", "db", 10)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	dir, err := ioutil.TempDir("", "blog-")
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, filename), 0600, nil)
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				keys := [][]byte{[]byte(fmt.Sprint(i)), []byte(fmt.Sprint(j)), []byte("leaf")}
+				err := Put(tx, []byte("test_wipe"), keys, []byte("data"))
+				if err != nil {
+					return e.Forward(err)
+				}
+			}
+		}
+		return Put(tx, []byte("test_keep"), [][]byte{[]byte("a"), []byte("b")}, []byte("data"))
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = Wipe(db, []byte("test_wipe"), func(s Stats) bool {
+		return false
+	})
+	if !e.Equal(err, ErrWipeCanceled) {
+		t.Fatal("wipe not canceled", err)
+	}
+
+	old := WipeChunk
+	WipeChunk = 7
+	defer func() {
+		WipeChunk = old
+	}()
+
+	err = Wipe(db, []byte("test_wipe"), func(s Stats) bool {
+		if s.Entries != 100 || s.Buckets != 110 || s.Bytes != 100*8 {
+			t.Errorf("wrong stats %#v", s)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		n := 0
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if string(name) != bucketsBucket {
+				n++
+			}
+			return nil
+		})
+		if err != nil {
+			return e.Forward(err)
+		}
+		// test_keep and its intermediate bucket.
+		if n != 2 {
+			return e.New("wrong number of buckets %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestSubtreeStats(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	bucket := []byte("test_bucket")
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, d := range []struct{ year, month, title string }{
+			{"2015", "11", "a"},
+			{"2015", "12", "bb"},
+			{"2015", "12", "cc"},
+			{"2016", "01", "d"},
+		} {
+			err := Put(tx, bucket, [][]byte{[]byte(d.year), []byte(d.month), []byte(d.title)}, []byte(d.title))
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		s, err := SubtreeStats(tx, bucket, [][]byte{[]byte("2015"), []byte("12")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if s != (Stats{Entries: 2, Buckets: 0, Bytes: 8}) {
+			return e.New("wrong month stats %+v", s)
+		}
+		s, err = SubtreeStats(tx, bucket, [][]byte{[]byte("2015")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if s.Entries != 3 || s.Buckets != 2 {
+			return e.New("wrong year stats %+v", s)
+		}
+		s, err = SubtreeStats(tx, bucket, nil)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if s.Entries != 4 || s.Buckets != 5 {
+			return e.New("wrong tree stats %+v", s)
+		}
+		_, err = SubtreeStats(tx, bucket, [][]byte{[]byte("2017")})
+		if !IsError(err, ErrKeyNotFound) {
+			return e.New("expected key not found, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}
+
+func TestWipeLeafNames(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	users := []byte("users")
+	notes := []byte("notes")
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := Put(tx, users, [][]byte{[]byte("alice"), []byte("email")}, []byte("alice@example.com"))
+		if err != nil {
+			return e.Forward(err)
+		}
+		// Leaves whose values are the names of buckets.
+		for _, keys := range [][][]byte{{[]byte("a"), []byte("owner")}, {[]byte("b"), []byte("owner")}} {
+			err = Put(tx, notes, keys, users)
+			if err != nil {
+				return e.Forward(err)
+			}
+		}
+		return Put(tx, notes, [][]byte{[]byte("c"), []byte("self")}, notes)
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		s, err := SubtreeStats(tx, notes, nil)
+		if err != nil {
+			return e.Forward(err)
+		}
+		if s.Entries != 3 || s.Buckets != 3 {
+			return e.New("wrong stats %+v", s)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	// The migrations verify the copy by the number of leaves.
+	err = ReorderLevels(db, notes, []int{1, 0})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		v, err := Get(tx, notes, [][]byte{[]byte("owner"), []byte("b")})
+		if err != nil {
+			return e.Forward(err)
+		}
+		if !bytes.Equal(v, users) {
+			return e.New("wrong leaf %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+
+	var stats Stats
+	err = Wipe(db, notes, func(s Stats) bool {
+		stats = s
+		return true
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+	if stats.Entries != 3 {
+		t.Fatal("wrong number of leaves", stats.Entries)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(notes) != nil {
+			return e.New("bucket not wiped")
+		}
+		_, err := Get(tx, users, [][]byte{[]byte("alice"), []byte("email")})
+		if err != nil {
+			return e.Push(err, e.New("the bucket named by a leaf was wiped"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(e.Trace(e.Forward(err)))
+	}
+}